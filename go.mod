@@ -7,7 +7,9 @@ require (
 	k8s.io/api v0.28.4
 	k8s.io/apimachinery v0.28.4
 	k8s.io/client-go v0.28.4
+	k8s.io/metrics v0.28.4
 	github.com/prometheus/client_golang v1.17.0
+	github.com/prometheus/common v0.44.0
 )
 
 require (
@@ -18,4 +20,5 @@ require (
 	github.com/Azure/azure-sdk-for-go/sdk/azcore v1.8.0
 	github.com/Azure/azure-sdk-for-go/sdk/azidentity v1.4.0
 	github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/monitor v0.11.0
+	google.golang.org/api v0.128.0
 )
\ No newline at end of file
@@ -4,172 +4,413 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
-	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 	corev1 "k8s.io/api/core/v1"
 )
 
-// CarbonCalculator handles CO2 emission calculations for Kubernetes resources
+// CarbonCalculator handles CO2 emission calculations for Kubernetes resources.
+// Every method returns one Metrics point per step of window, holding the energy and emissions of that step.
 type CarbonCalculator interface {
-	CalculateClusterCarbon(ctx context.Context, nodes []*corev1.Node, pods []*corev1.Pod) ([]*Metrics, error)
-	CalculateNamespaceCarbon(ctx context.Context, namespace *corev1.Namespace, pods []*corev1.Pod) ([]*Metrics, error)
-	CalculateNodeCarbon(ctx context.Context, node *corev1.Node, pods []*corev1.Pod) ([]*Metrics, error)
-	CalculatePodCarbon(ctx context.Context, pod *corev1.Pod) ([]*Metrics, error)
+	CalculateClusterCarbon(ctx context.Context, nodes []*corev1.Node, pods []*corev1.Pod, window TimeWindow) ([]*Metrics, error)
+	CalculateNamespaceCarbon(ctx context.Context, namespace *corev1.Namespace, nodes []*corev1.Node, pods []*corev1.Pod, attributions *NodeAttributions, window TimeWindow) ([]*Metrics, error)
+	CalculateWorkloadCarbon(ctx context.Context, workload *Workload, nodes []*corev1.Node, attributions *NodeAttributions, window TimeWindow) ([]*Metrics, error)
+	CalculateNodeCarbon(ctx context.Context, node *corev1.Node, pods []*corev1.Pod, window TimeWindow) ([]*Metrics, error)
+	CalculatePodCarbon(ctx context.Context, pod *corev1.Pod, node *corev1.Node, attributions *NodeAttributions, window TimeWindow) ([]*Metrics, error)
+	CalculateIdleCarbon(ctx context.Context, node *corev1.Node, attributions *NodeAttributions, window TimeWindow) ([]*Metrics, error)
+	AttributeNodes(ctx context.Context, nodes []*corev1.Node, pods []*corev1.Pod, window TimeWindow) *NodeAttributions
 }
 
 // carbonCalculator implements the CarbonCalculator interface
 type carbonCalculator struct {
-	config         *CarbonConfig
-	gridIntensity  GridIntensityProvider
-	instanceSpecs  InstanceSpecProvider
-	energyModels   EnergyModelProvider
+	config        *CarbonConfig
+	gridIntensity GridIntensityProvider
+	gridZones     *gridZoneResolver
+	instanceSpecs InstanceSpecProvider
+	energyModel   EnergyModelProvider
+	utilization   UtilizationSource
+	energySource  EnergySource
 }
 
 // CarbonConfig holds configuration for carbon calculations
 type CarbonConfig struct {
-	GridIntensityAPIKey    string  `json:"gridIntensityApiKey"`
-	ElectricityMapsAPIKey  string  `json:"electricityMapsApiKey"`
-	DefaultGridIntensity   float64 `json:"defaultGridIntensity"`   // gCO2/kWh
-	PUE                    float64 `json:"pue"`                    // Power Usage Effectiveness
-	EnableNetworkAccounting bool   `json:"enableNetworkAccounting"`
-	EnableStorageAccounting bool   `json:"enableStorageAccounting"`
+	GridIntensityAPIKey     string            `json:"-"`                     // secure JSON only, used by providers that need a key
+	ElectricityMapsAPIKey   string            `json:"-"`                     // secure JSON only
+	DefaultGridIntensity    float64           `json:"defaultGridIntensity"`  // gCO2/kWh
+	GridIntensityProvider   string            `json:"gridIntensityProvider"` // "static", "electricitymaps", "watttime", "ukcarbonintensity", "file"
+	GridIntensityFile       string            `json:"gridIntensityFile"`     // CSV or JSON intensity file used by the "file" provider
+	GridZone                string            `json:"gridZone"`              // grid zone for nodes without a known region, e.g. "DE"
+	GridZoneOverrides       map[string]string `json:"gridZoneOverrides"`     // cloud region -> grid zone, wins over the embedded mapping
+	IntensityBasis          string            `json:"intensityBasis"`        // "average" (default) or "marginal"
+	EnergyModel             string            `json:"energyModel"`           // "ccf" (default) or "specpower"
+	AllocationMode          string            `json:"allocationMode"`        // "requests" (default), "usage" or "max"
+	UtilizationSource       string            `json:"utilizationSource"`     // "metrics-server" (default) or "prometheus"
+	EnergySource            string            `json:"energySource"`          // "model" (default) or "kepler"
+	IdleAttribution         string            `json:"idleAttribution"`       // "none" (default), "proportional" or "namespace"
+	TeamSources             []TeamSource      `json:"teamSources"`           // fallback chain deriving the owning team, first match wins
+	PrometheusURL           string            `json:"prometheusUrl"`
+	RequestsQuery           string            `json:"requestsQuery"` // PromQL request rate carbon per request divides by
+	PrometheusToken         string            `json:"-"`             // secure JSON only
+	WattTimeUsername        string            `json:"wattTimeUsername"`
+	WattTimePassword        string            `json:"-"`           // secure JSON only
+	PUE                     float64           `json:"pue"`         // Power Usage Effectiveness
+	EnergyPrice             float64           `json:"energyPrice"` // price of a kWh the cost metric is charged at
+	Currency                string            `json:"currency"`    // ISO 4217 code of the energy price, "USD" by default
+	EnableNetworkAccounting bool              `json:"enableNetworkAccounting"`
+	EnableStorageAccounting bool              `json:"enableStorageAccounting"`
 }
 
 // Metrics represents carbon footprint metrics for a resource
 type Metrics struct {
-	Timestamp        time.Time         `json:"timestamp"`
-	ResourceType     string           `json:"resourceType"`
-	ResourceName     string           `json:"resourceName"`
-	Namespace        string           `json:"namespace,omitempty"`
-	NodeName         string           `json:"nodeName,omitempty"`
-	CO2Emissions     float64          `json:"co2Emissions"`     // grams CO2
-	EnergyConsumption float64         `json:"energyConsumption"` // kWh
-	GridIntensity    float64          `json:"gridIntensity"`    // gCO2/kWh
-	Source           string           `json:"source"`           // "calculated", "estimated"
-	Labels           map[string]string `json:"labels,omitempty"`
-	
+	Timestamp         time.Time         `json:"timestamp"`
+	ResourceType      string            `json:"resourceType"`
+	ResourceName      string            `json:"resourceName"`
+	Namespace         string            `json:"namespace,omitempty"`
+	NodeName          string            `json:"nodeName,omitempty"`
+	Team              string            `json:"team,omitempty"`
+	CO2Emissions      float64           `json:"co2Emissions"`      // grams CO2
+	EnergyConsumption float64           `json:"energyConsumption"` // kWh
+	Cost              float64           `json:"cost,omitempty"`    // energy at the configured energy price
+	GridIntensity     float64           `json:"gridIntensity"`     // gCO2/kWh
+	IntensityBasis    string            `json:"intensityBasis"`    // "average", "marginal"
+	Source            string            `json:"source"`            // "calculated", "measured" or "mixed"
+	Labels            map[string]string `json:"labels,omitempty"`
+
 	// Resource-specific metrics
-	CPUUsage      float64 `json:"cpuUsage,omitempty"`      // millicores
-	MemoryUsage   float64 `json:"memoryUsage,omitempty"`   // bytes
-	StorageUsage  float64 `json:"storageUsage,omitempty"`  // bytes
+	CPUUsage       float64 `json:"cpuUsage,omitempty"`       // millicores
+	MemoryUsage    float64 `json:"memoryUsage,omitempty"`    // bytes
+	StorageUsage   float64 `json:"storageUsage,omitempty"`   // bytes
 	NetworkTraffic float64 `json:"networkTraffic,omitempty"` // bytes
 }
 
 // Query represents a carbon footprint query
 type Query struct {
 	RefID        string                 `json:"refId"`
-	QueryType    string                 `json:"queryType"`    // "timeseries", "table", "single-value"
-	ResourceType string                 `json:"resourceType"` // "cluster", "namespace", "node", "pod"
-	Aggregation  string                 `json:"aggregation"`  // "sum", "avg", "max", "min"
-	GroupBy      []string               `json:"groupBy"`
-	Filters      map[string]interface{} `json:"filters"`
+	QueryType    string                 `json:"queryType"`    // "timeseries", "table", "single-value", "topk", "comparison"
+	ResourceType string                 `json:"resourceType"` // "cluster", "namespace", "node", "pod", "deployment", "statefulset", "daemonset", "job", "cronjob", "workload", "team"
+	Aggregation  string                 `json:"aggregation"`  // "sum", "avg", "max", "min", "p50", "p95", "count"
+	GroupBy      []string               `json:"groupBy"`      // metric fields such as "namespace", "zone" or "team", or label keys
+	Filters      map[string]interface{} `json:"filters"`      // see the Filter* keys
 	TimeRange    struct {
 		From string `json:"from"`
 		To   string `json:"to"`
 	} `json:"timeRange"`
+
+	// Table options: the columns to return, every column when empty, and how rows are ordered
+	Columns       []string `json:"columns"`       // metric columns, "labels" or "labels.<key>"
+	FlattenLabels bool     `json:"flattenLabels"` // expand "labels" to one column per label key
+	SortBy        string   `json:"sortBy"`        // column to order rows by
+	SortDesc      bool     `json:"sortDesc"`
+	Limit         int      `json:"limit"` // maximum number of rows, all when zero
+
+	// Single-value options: every metric is returned with every aggregation as "<metric>_<aggregation>".
+	// Without either list the query returns one "value" field, the Aggregation of CO2.
+	Metrics      []string `json:"metrics"` // "co2", "energy", "intensity", "cpu", "memory", "cost", "carbon_per_request"
+	Aggregations []string `json:"aggregations"`
+
+	// Ranking options: the top, or with Bottom the bottom, TopN resources by RankBy, a numeric table column.
+	// IncludeOther adds a row summing the remaining resources.
+	RankBy       string `json:"rankBy"` // "co2_emissions" by default
+	TopN         int    `json:"topN"`   // 10 by default
+	Bottom       bool   `json:"bottom"`
+	IncludeOther bool   `json:"includeOther"`
+
+	// Comparison options: the period the time range is compared to
+	CompareTo string `json:"compareTo"` // "previous" (default), "week" or "month"
+
+	// requests served in each step keyed by its Unix start time, counted by the datasource for carbon per request
+	requests map[int64]float64
+
+	// previous holds the metrics of the comparison period, collected by the datasource
+	previous []*Metrics
+
+	// costUnit is the field unit of cost, the configured currency set by the datasource
+	costUnit string
+}
+
+// CalculatorOption customizes a carbon calculator
+type CalculatorOption func(*carbonCalculator)
+
+// WithGridIntensityProvider overrides the provider selected from the configuration
+func WithGridIntensityProvider(provider GridIntensityProvider) CalculatorOption {
+	return func(c *carbonCalculator) {
+		c.gridIntensity = provider
+	}
+}
+
+// WithEnergyModelProvider overrides the energy model selected from the configuration
+func WithEnergyModelProvider(model EnergyModelProvider) CalculatorOption {
+	return func(c *carbonCalculator) {
+		c.energyModel = model
+	}
+}
+
+// WithUtilizationSource sets where measured usage comes from. It is reported in every allocation
+// mode and drives energy in the "usage" and "max" modes.
+func WithUtilizationSource(source UtilizationSource) CalculatorOption {
+	return func(c *carbonCalculator) {
+		c.utilization = source
+	}
+}
+
+// WithEnergySource sets the source of measured energy, which wins over the energy model where it has data
+func WithEnergySource(source EnergySource) CalculatorOption {
+	return func(c *carbonCalculator) {
+		c.energySource = source
+	}
 }
 
 // NewCarbonCalculator creates a new carbon calculator instance
-func NewCarbonCalculator(config *CarbonConfig) CarbonCalculator {
-	return &carbonCalculator{
+func NewCarbonCalculator(config *CarbonConfig, opts ...CalculatorOption) CarbonCalculator {
+	c := &carbonCalculator{
 		config:        config,
 		gridIntensity: NewGridIntensityProvider(config),
 		instanceSpecs: NewInstanceSpecProvider(),
-		energyModels:  NewEnergyModelProvider(),
+		energyModel:   NewEnergyModelProvider(config),
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	c.gridZones = newGridZoneResolver(config, c.gridIntensity.Name())
+	return c
 }
 
-// CalculateClusterCarbon calculates carbon footprint for the entire cluster
-func (c *carbonCalculator) CalculateClusterCarbon(ctx context.Context, nodes []*corev1.Node, pods []*corev1.Pod) ([]*Metrics, error) {
-	now := time.Now()
-	var totalCO2 float64
-	var totalEnergy float64
-	
-	// Calculate emissions for each node
+// getGridIntensity looks up the intensity for zone, falling back to the configured default.
+// Fallbacks are reported to the query as a notice.
+func (c *carbonCalculator) getGridIntensity(ctx context.Context, zone string, at time.Time) *IntensityDataPoint {
+	point, err := c.gridIntensity.GetGridIntensity(ctx, zone, at)
+	if err != nil || point == nil {
+		addQueryNotice(ctx, data.NoticeSeverityWarning, "Grid intensity of zone %q is unavailable from %s, the default of %g gCO2/kWh is used instead",
+			zone, c.gridIntensity.Name(), c.config.DefaultGridIntensity)
+		return &IntensityDataPoint{
+			Timestamp: at,
+			Zone:      zone,
+			Intensity: c.config.DefaultGridIntensity,
+			Basis:     IntensityBasisAverage,
+			Source:    GridProviderStatic,
+		}
+	}
+	return point
+}
+
+// loadIntensityRanges loads the grid intensity history of window for the zone of every node, and the
+// default zone, when the provider fetches ranges in bulk. Zones that fail to load fall back in getGridIntensity.
+func (c *carbonCalculator) loadIntensityRanges(ctx context.Context, nodes []*corev1.Node, window TimeWindow) {
+	loader, ok := c.gridIntensity.(GridIntensityRangeLoader)
+	if !ok {
+		return
+	}
+
+	zones := map[string]bool{c.gridZones.Resolve(""): true}
 	for _, node := range nodes {
-		nodeEnergy, err := c.calculateNodeEnergyConsumption(ctx, node, pods)
+		zones[c.gridZones.Resolve(nodeRegion(node))] = true
+	}
+	for zone := range zones {
+		if zone != "" {
+			loader.LoadRange(ctx, zone, window.From, window.To)
+		}
+	}
+}
+
+// sumRegionalEmissions applies PUE and each node's regional grid intensity to per-node energy.
+// The returned intensity is the energy-weighted average across all nodes.
+func (c *carbonCalculator) sumRegionalEmissions(ctx context.Context, energyByNode map[string]float64, nodesByName map[string]*corev1.Node, at time.Time) (float64, float64, *IntensityDataPoint) {
+	var totalEnergy, totalCO2 float64
+	var basis string
+
+	for nodeName, energy := range energyByNode {
+		zone := c.gridZones.Resolve(nodeRegion(nodesByName[nodeName]))
+		intensity := c.getGridIntensity(ctx, zone, at)
+
+		energy *= c.config.PUE
+		totalEnergy += energy
+		totalCO2 += energy * intensity.Intensity
+
+		if basis == "" {
+			basis = intensity.Basis
+		} else if basis != intensity.Basis {
+			basis = intensityBasisMixed
+		}
+	}
+
+	if totalEnergy == 0 {
+		return 0, 0, c.getGridIntensity(ctx, c.config.GridZone, at)
+	}
+
+	return totalEnergy, totalCO2, &IntensityDataPoint{
+		Timestamp: at,
+		Intensity: totalCO2 / totalEnergy,
+		Basis:     basis,
+	}
+}
+
+// indexNodes returns nodes keyed by name
+func indexNodes(nodes []*corev1.Node) map[string]*corev1.Node {
+	byName := make(map[string]*corev1.Node, len(nodes))
+	for _, node := range nodes {
+		byName[node.Name] = node
+	}
+	return byName
+}
+
+// CalculateClusterCarbon calculates carbon footprint for the entire cluster, one point per step of window
+func (c *carbonCalculator) CalculateClusterCarbon(ctx context.Context, nodes []*corev1.Node, pods []*corev1.Pod, window TimeWindow) ([]*Metrics, error) {
+	steps := window.Steps()
+	c.loadIntensityRanges(ctx, nodes, window)
+
+	// Calculate energy for each node and step
+	energyByNode := newStepEnergyByNode(len(steps))
+	usage := make([]ResourceUsage, len(steps))
+	sources := make([]string, len(steps))
+	for _, node := range nodes {
+		nodeSteps, err := c.calculateNodeEnergyConsumption(ctx, node, pods, window)
 		if err != nil {
 			continue // Skip nodes with calculation errors
 		}
-		
-		totalEnergy += nodeEnergy
+
+		for i, step := range nodeSteps {
+			energyByNode[i][node.Name] += step.energy
+			usage[i].add(step.usage)
+			sources[i] = combineSources(sources[i], step.source())
+		}
 	}
-	
-	// Get grid intensity for the cluster region
-	gridIntensity, err := c.gridIntensity.GetGridIntensity(ctx, c.config.DefaultGridIntensity)
-	if err != nil {
-		gridIntensity = c.config.DefaultGridIntensity
-	}
-	
-	// Apply PUE (Power Usage Effectiveness) to account for datacenter overhead
-	totalEnergy *= c.config.PUE
-	
-	// Calculate CO2 emissions
-	totalCO2 = totalEnergy * gridIntensity
-	
-	return []*Metrics{{
-		Timestamp:         now,
-		ResourceType:      "cluster",
-		ResourceName:      "cluster",
-		CO2Emissions:      totalCO2,
-		EnergyConsumption: totalEnergy,
-		GridIntensity:     gridIntensity,
-		Source:           "calculated",
-	}}, nil
-}
-
-// CalculateNamespaceCarbon calculates carbon footprint for a namespace
-func (c *carbonCalculator) CalculateNamespaceCarbon(ctx context.Context, namespace *corev1.Namespace, pods []*corev1.Pod) ([]*Metrics, error) {
-	now := time.Now()
-	var totalCO2 float64
-	var totalEnergy float64
-	
-	// Filter pods in this namespace
-	namespacePods := make([]*corev1.Pod, 0)
+
+	nodesByName := indexNodes(nodes)
+	metrics := make([]*Metrics, 0, len(steps))
+	for i, at := range steps {
+		// Each node's energy is priced at its own region's grid intensity
+		totalEnergy, totalCO2, gridIntensity := c.sumRegionalEmissions(ctx, energyByNode[i], nodesByName, at)
+
+		metrics = append(metrics, &Metrics{
+			Timestamp:         at,
+			ResourceType:      "cluster",
+			ResourceName:      "cluster",
+			CO2Emissions:      totalCO2,
+			EnergyConsumption: totalEnergy,
+			GridIntensity:     gridIntensity.Intensity,
+			IntensityBasis:    gridIntensity.Basis,
+			Source:            rollupSource(sources[i]),
+			CPUUsage:          usage[i].CPUMillicores,
+			MemoryUsage:       usage[i].MemoryBytes,
+		})
+	}
+
+	return metrics, nil
+}
+
+// CalculateNamespaceCarbon calculates carbon footprint for a namespace, one point per step of window.
+// attributions split idle energy of the nodes across all tenants, see AttributeNodes.
+// IdleNamespace yields the idle energy, nothing when it is not attributed.
+func (c *carbonCalculator) CalculateNamespaceCarbon(ctx context.Context, namespace *corev1.Namespace, nodes []*corev1.Node, pods []*corev1.Pod, attributions *NodeAttributions, window TimeWindow) ([]*Metrics, error) {
+	if namespace.Name == IdleNamespace && !c.attributesIdle() {
+		return nil, nil
+	}
+	c.loadIntensityRanges(ctx, nodes, window)
+
+	members := make([]*corev1.Pod, 0)
 	for _, pod := range pods {
 		if pod.Namespace == namespace.Name {
-			namespacePods = append(namespacePods, pod)
+			members = append(members, pod)
 		}
 	}
-	
-	// Calculate energy consumption for all pods in namespace
-	for _, pod := range namespacePods {
-		podEnergy, err := c.calculatePodEnergyConsumption(ctx, pod)
+
+	// Calculate energy consumption for all pods in namespace, grouped by the node they run on
+	nodesByName := indexNodes(nodes)
+	group := c.sumPodEnergy(ctx, members, nodesByName, attributions, window)
+	if namespace.Name == IdleNamespace {
+		for _, node := range nodes {
+			for i, attribution := range attributions.of(node.Name) {
+				group.energyByNode[i][node.Name] += attribution.idle
+				group.sources[i] = combineSources(group.sources[i], attribution.idleSource)
+			}
+		}
+	}
+
+	return c.rollupMetrics(ctx, group, nodesByName, window, Metrics{
+		ResourceType: "namespace",
+		ResourceName: namespace.Name,
+		Namespace:    namespace.Name,
+		Labels:       namespace.Labels,
+	}), nil
+}
+
+// CalculateWorkloadCarbon calculates carbon footprint for the pods of a workload, one point per step of window.
+// attributions split idle energy of the nodes across all tenants, see AttributeNodes.
+func (c *carbonCalculator) CalculateWorkloadCarbon(ctx context.Context, workload *Workload, nodes []*corev1.Node, attributions *NodeAttributions, window TimeWindow) ([]*Metrics, error) {
+	c.loadIntensityRanges(ctx, nodes, window)
+	nodesByName := indexNodes(nodes)
+	group := c.sumPodEnergy(ctx, workload.Pods, nodesByName, attributions, window)
+
+	return c.rollupMetrics(ctx, group, nodesByName, window, Metrics{
+		ResourceType: strings.ToLower(workload.Kind),
+		ResourceName: workload.Name,
+		Namespace:    workload.Namespace,
+		Labels:       map[string]string{"kind": workload.Kind},
+	}), nil
+}
+
+// podGroupEnergy is the energy of a group of pods in each step of a window
+type podGroupEnergy struct {
+	energyByNode []map[string]float64 // kWh before PUE, by the node the pods run on
+	usage        []ResourceUsage
+	sources      []string
+}
+
+// sumPodEnergy sums the energy of members in each step of window, including their share of idle energy
+func (c *carbonCalculator) sumPodEnergy(ctx context.Context, members []*corev1.Pod, nodesByName map[string]*corev1.Node, attributions *NodeAttributions, window TimeWindow) *podGroupEnergy {
+	steps := len(window.Steps())
+	group := &podGroupEnergy{
+		energyByNode: newStepEnergyByNode(steps),
+		usage:        make([]ResourceUsage, steps),
+		sources:      make([]string, steps),
+	}
+
+	for _, pod := range members {
+		podSteps, err := c.calculatePodEnergyConsumption(ctx, pod, nodesByName[pod.Spec.NodeName], window)
 		if err != nil {
 			continue
 		}
-		totalEnergy += podEnergy
+		attribution := attributions.of(pod.Spec.NodeName)
+		for i, step := range podSteps {
+			group.energyByNode[i][pod.Spec.NodeName] += step.energy * scaleAt(attribution, i)
+			group.usage[i].add(step.usage)
+			group.sources[i] = combineSources(group.sources[i], step.source())
+		}
 	}
-	
-	// Get grid intensity
-	gridIntensity, err := c.gridIntensity.GetGridIntensity(ctx, c.config.DefaultGridIntensity)
-	if err != nil {
-		gridIntensity = c.config.DefaultGridIntensity
-	}
-	
-	// Apply PUE
-	totalEnergy *= c.config.PUE
-	totalCO2 = totalEnergy * gridIntensity
-	
-	return []*Metrics{{
-		Timestamp:         now,
-		ResourceType:      "namespace",
-		ResourceName:      namespace.Name,
-		Namespace:         namespace.Name,
-		CO2Emissions:      totalCO2,
-		EnergyConsumption: totalEnergy,
-		GridIntensity:     gridIntensity,
-		Source:           "calculated",
-		Labels:           namespace.Labels,
-	}}, nil
-}
-
-// CalculateNodeCarbon calculates carbon footprint for a node
-func (c *carbonCalculator) CalculateNodeCarbon(ctx context.Context, node *corev1.Node, pods []*corev1.Pod) ([]*Metrics, error) {
-	now := time.Now()
-	
+
+	return group
+}
+
+// rollupMetrics prices the energy of group at the regional grid intensity of each step.
+// The resource fields of the returned metrics are copied from resource.
+func (c *carbonCalculator) rollupMetrics(ctx context.Context, group *podGroupEnergy, nodesByName map[string]*corev1.Node, window TimeWindow, resource Metrics) []*Metrics {
+	steps := window.Steps()
+	metrics := make([]*Metrics, 0, len(steps))
+	for i, at := range steps {
+		totalEnergy, totalCO2, gridIntensity := c.sumRegionalEmissions(ctx, group.energyByNode[i], nodesByName, at)
+
+		metric := resource
+		metric.Timestamp = at
+		metric.CO2Emissions = totalCO2
+		metric.EnergyConsumption = totalEnergy
+		metric.GridIntensity = gridIntensity.Intensity
+		metric.IntensityBasis = gridIntensity.Basis
+		metric.Source = rollupSource(group.sources[i])
+		metric.CPUUsage = group.usage[i].CPUMillicores
+		metric.MemoryUsage = group.usage[i].MemoryBytes
+		metrics = append(metrics, &metric)
+	}
+
+	return metrics
+}
+
+// CalculateNodeCarbon calculates carbon footprint for a node, one point per step of window
+func (c *carbonCalculator) CalculateNodeCarbon(ctx context.Context, node *corev1.Node, pods []*corev1.Pod, window TimeWindow) ([]*Metrics, error) {
+	c.loadIntensityRanges(ctx, []*corev1.Node{node}, window)
+
 	// Filter pods on this node
 	nodePods := make([]*corev1.Pod, 0)
 	for _, pod := range pods {
@@ -177,182 +418,330 @@ func (c *carbonCalculator) CalculateNodeCarbon(ctx context.Context, node *corev1
 			nodePods = append(nodePods, pod)
 		}
 	}
-	
-	nodeEnergy, err := c.calculateNodeEnergyConsumption(ctx, node, nodePods)
+
+	nodeSteps, err := c.calculateNodeEnergyConsumption(ctx, node, nodePods, window)
 	if err != nil {
 		return nil, err
 	}
-	
-	gridIntensity, err := c.gridIntensity.GetGridIntensity(ctx, c.config.DefaultGridIntensity)
-	if err != nil {
-		gridIntensity = c.config.DefaultGridIntensity
-	}
-	
-	// Apply PUE
-	nodeEnergy *= c.config.PUE
-	co2Emissions := nodeEnergy * gridIntensity
-	
-	// Get instance type from node labels
-	instanceType := "unknown"
-	if it, ok := node.Labels["beta.kubernetes.io/instance-type"]; ok {
-		instanceType = it
-	} else if it, ok := node.Labels["node.kubernetes.io/instance-type"]; ok {
-		instanceType = it
-	}
-	
+
+	// Use the intensity of the grid the node's region draws power from
+	region := nodeRegion(node)
+	gridZone := c.gridZones.Resolve(region)
+
 	labels := make(map[string]string)
-	labels["instance-type"] = instanceType
-	labels["zone"] = node.Labels["topology.kubernetes.io/zone"]
-	
-	return []*Metrics{{
-		Timestamp:         now,
-		ResourceType:      "node",
-		ResourceName:      node.Name,
-		NodeName:          node.Name,
-		CO2Emissions:      co2Emissions,
-		EnergyConsumption: nodeEnergy,
-		GridIntensity:     gridIntensity,
-		Source:           "calculated",
-		Labels:           labels,
-	}}, nil
-}
-
-// CalculatePodCarbon calculates carbon footprint for a pod
-func (c *carbonCalculator) CalculatePodCarbon(ctx context.Context, pod *corev1.Pod) ([]*Metrics, error) {
-	now := time.Now()
-	
-	podEnergy, err := c.calculatePodEnergyConsumption(ctx, pod)
+	labels["instance-type"] = nodeInstanceType(node)
+	labels["zone"] = node.Labels[labelTopologyZone]
+	labels["region"] = region
+	labels["grid-zone"] = gridZone
+
+	metrics := make([]*Metrics, 0, len(nodeSteps))
+	for i, at := range window.Steps() {
+		gridIntensity := c.getGridIntensity(ctx, gridZone, at)
+
+		// Apply PUE
+		nodeEnergy := nodeSteps[i].energy * c.config.PUE
+		co2Emissions := nodeEnergy * gridIntensity.Intensity
+
+		var usage ResourceUsage
+		usage.add(nodeSteps[i].usage)
+
+		metrics = append(metrics, &Metrics{
+			Timestamp:         at,
+			ResourceType:      "node",
+			ResourceName:      node.Name,
+			NodeName:          node.Name,
+			CO2Emissions:      co2Emissions,
+			EnergyConsumption: nodeEnergy,
+			GridIntensity:     gridIntensity.Intensity,
+			IntensityBasis:    gridIntensity.Basis,
+			Source:            nodeSteps[i].source(),
+			Labels:            labels,
+			CPUUsage:          usage.CPUMillicores,
+			MemoryUsage:       usage.MemoryBytes,
+		})
+	}
+
+	return metrics, nil
+}
+
+// CalculatePodCarbon calculates carbon footprint for a pod running on node, one point per step of window.
+// node may be nil, the configured default grid zone is used then. attributions give the pod its share
+// of idle energy of the node; nil leaves idle energy unattributed.
+func (c *carbonCalculator) CalculatePodCarbon(ctx context.Context, pod *corev1.Pod, node *corev1.Node, attributions *NodeAttributions, window TimeWindow) ([]*Metrics, error) {
+	c.loadIntensityRanges(ctx, []*corev1.Node{node}, window)
+
+	podSteps, err := c.calculatePodEnergyConsumption(ctx, pod, node, window)
 	if err != nil {
 		return nil, err
 	}
-	
-	gridIntensity, err := c.gridIntensity.GetGridIntensity(ctx, c.config.DefaultGridIntensity)
-	if err != nil {
-		gridIntensity = c.config.DefaultGridIntensity
-	}
-	
-	// Apply PUE
-	podEnergy *= c.config.PUE
-	co2Emissions := podEnergy * gridIntensity
-	
-	return []*Metrics{{
-		Timestamp:         now,
-		ResourceType:      "pod",
-		ResourceName:      pod.Name,
-		Namespace:         pod.Namespace,
-		NodeName:          pod.Spec.NodeName,
-		CO2Emissions:      co2Emissions,
-		EnergyConsumption: podEnergy,
-		GridIntensity:     gridIntensity,
-		Source:           "calculated",
-		Labels:           pod.Labels,
-	}}, nil
-}
-
-// calculateNodeEnergyConsumption calculates energy consumption for a node
-func (c *carbonCalculator) calculateNodeEnergyConsumption(ctx context.Context, node *corev1.Node, pods []*corev1.Pod) (float64, error) {
-	// Get instance specifications
-	instanceType := "unknown"
-	if it, ok := node.Labels["beta.kubernetes.io/instance-type"]; ok {
-		instanceType = it
-	} else if it, ok := node.Labels["node.kubernetes.io/instance-type"]; ok {
-		instanceType = it
-	}
-	
-	specs, err := c.instanceSpecs.GetInstanceSpecs(instanceType)
-	if err != nil {
-		// Fallback to default estimation
-		specs = &InstanceSpecs{
-			InstanceType: instanceType,
-			VCPUs:        2,
-			MemoryGB:     4,
-			TDP:          100, // watts
+
+	var attribution []nodeAttribution
+	if node != nil {
+		attribution = attributions.of(node.Name)
+	}
+	gridZone := c.gridZones.Resolve(nodeRegion(node))
+
+	metrics := make([]*Metrics, 0, len(podSteps))
+	for i, at := range window.Steps() {
+		gridIntensity := c.getGridIntensity(ctx, gridZone, at)
+
+		// Apply the pod's share of idle energy and PUE
+		podEnergy := podSteps[i].energy * scaleAt(attribution, i) * c.config.PUE
+		co2Emissions := podEnergy * gridIntensity.Intensity
+
+		var usage ResourceUsage
+		usage.add(podSteps[i].usage)
+
+		metrics = append(metrics, &Metrics{
+			Timestamp:         at,
+			ResourceType:      "pod",
+			ResourceName:      pod.Name,
+			Namespace:         pod.Namespace,
+			NodeName:          pod.Spec.NodeName,
+			CO2Emissions:      co2Emissions,
+			EnergyConsumption: podEnergy,
+			GridIntensity:     gridIntensity.Intensity,
+			IntensityBasis:    gridIntensity.Basis,
+			Source:            podSteps[i].source(),
+			Labels:            pod.Labels,
+			CPUUsage:          usage.CPUMillicores,
+			MemoryUsage:       usage.MemoryBytes,
+		})
+	}
+
+	return metrics, nil
+}
+
+// CalculateIdleCarbon calculates the idle energy of node charged to IdleNamespace, one point per step
+// of window, as split by attributions. Nothing is returned when idle energy is not attributed.
+func (c *carbonCalculator) CalculateIdleCarbon(ctx context.Context, node *corev1.Node, attributions *NodeAttributions, window TimeWindow) ([]*Metrics, error) {
+	if !c.attributesIdle() {
+		return nil, nil
+	}
+	c.loadIntensityRanges(ctx, []*corev1.Node{node}, window)
+
+	attribution := attributions.of(node.Name)
+	if attribution == nil {
+		return nil, fmt.Errorf("no idle attribution for node %s", node.Name)
+	}
+
+	gridZone := c.gridZones.Resolve(nodeRegion(node))
+
+	metrics := make([]*Metrics, 0, len(attribution))
+	for i, at := range window.Steps() {
+		gridIntensity := c.getGridIntensity(ctx, gridZone, at)
+
+		// Apply PUE
+		idleEnergy := attribution[i].idle * c.config.PUE
+		co2Emissions := idleEnergy * gridIntensity.Intensity
+
+		metrics = append(metrics, &Metrics{
+			Timestamp:         at,
+			ResourceType:      "pod",
+			ResourceName:      IdleNamespace,
+			Namespace:         IdleNamespace,
+			NodeName:          node.Name,
+			CO2Emissions:      co2Emissions,
+			EnergyConsumption: idleEnergy,
+			GridIntensity:     gridIntensity.Intensity,
+			IntensityBasis:    gridIntensity.Basis,
+			Source:            attribution[i].idleSource,
+		})
+	}
+
+	return metrics, nil
+}
+
+// nodeSpecs returns the catalog specs of a node's instance type. Unlisted instance types,
+// e.g. on-premises nodes, are sized from the capacity the node reports.
+func (c *carbonCalculator) nodeSpecs(node *corev1.Node) *InstanceSpecs {
+	instanceType := unknownInstanceType
+	if node != nil {
+		instanceType = nodeInstanceType(node)
+	}
+	if specs, err := c.instanceSpecs.GetInstanceSpecs(instanceType); err == nil {
+		return specs
+	}
+
+	specs := &InstanceSpecs{
+		InstanceType: instanceType,
+		VCPUs:        defaultNodeVCPUs,
+		MemoryGB:     defaultNodeMemoryGB,
+	}
+	if node != nil {
+		if cpu := node.Status.Capacity.Cpu(); !cpu.IsZero() {
+			specs.VCPUs = int(cpu.Value())
+		}
+		if memory := node.Status.Capacity.Memory(); !memory.IsZero() {
+			specs.MemoryGB = float64(memory.Value()) / bytesPerGiB
 		}
 	}
-	
-	// Calculate base energy consumption from TDP
-	baseEnergyWatts := float64(specs.TDP)
-	
-	// Calculate utilization-based energy consumption
-	totalCPURequests := float64(0)
-	totalMemoryRequests := float64(0)
-	
+	return specs
+}
+
+// stepEnergy is the energy consumed during one step of a window
+type stepEnergy struct {
+	energy   float64        // kWh, before PUE
+	usage    *ResourceUsage // measured usage, nil when not measured
+	measured bool           // energy comes from the energy source rather than the model
+}
+
+// source returns the Metrics.Source of the step
+func (s stepEnergy) source() string {
+	if s.measured {
+		return SourceMeasured
+	}
+	return SourceCalculated
+}
+
+// rollupSource returns the Metrics.Source of a rollup, calculated when nothing contributed
+func rollupSource(source string) string {
+	if source == "" {
+		return SourceCalculated
+	}
+	return source
+}
+
+// newStepEnergyByNode returns one node name to energy map per step
+func newStepEnergyByNode(steps int) []map[string]float64 {
+	energyByNode := make([]map[string]float64, steps)
+	for i := range energyByNode {
+		energyByNode[i] = make(map[string]float64)
+	}
+	return energyByNode
+}
+
+// activeHours returns how long within the step starting at start an object created at created
+// existed. ended is when the object stopped running, zero while it still runs.
+func activeHours(window TimeWindow, start time.Time, created time.Time, ended time.Time) float64 {
+	end := start.Add(window.stepDuration(start))
+	if created.After(start) {
+		start = created
+	}
+	if !ended.IsZero() && ended.Before(end) {
+		end = ended
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return end.Sub(start).Hours()
+}
+
+// stepRequests sums the requests of pods during the step starting at start, each weighted by
+// the share of the step it ran for, as calculatePodEnergyConsumption does for a single pod
+func stepRequests(window TimeWindow, start time.Time, pods []*corev1.Pod) ResourceUsage {
+	var requests ResourceUsage
+	hours := window.stepDuration(start).Hours()
+	if hours <= 0 {
+		return requests
+	}
 	for _, pod := range pods {
-		if pod.Spec.NodeName != node.Name {
+		share := activeHours(window, start, pod.CreationTimestamp.Time, podEndTime(pod)) / hours
+		if share == 0 {
 			continue
 		}
-		
-		for _, container := range pod.Spec.Containers {
-			if cpu := container.Resources.Requests.Cpu(); cpu != nil {
-				totalCPURequests += float64(cpu.MilliValue())
-			}
-			if memory := container.Resources.Requests.Memory(); memory != nil {
-				totalMemoryRequests += float64(memory.Value())
-			}
+		podReq := podRequests(pod)
+		requests.CPUMillicores += podReq.CPUMillicores * share
+		requests.MemoryBytes += podReq.MemoryBytes * share
+	}
+	return requests
+}
+
+// calculateNodeEnergyConsumption calculates the energy a node consumes in each step of window.
+// Measured energy is used where the energy source has it, the model estimates the other steps.
+// Modelled steps before the node was created consume nothing.
+func (c *carbonCalculator) calculateNodeEnergyConsumption(ctx context.Context, node *corev1.Node, pods []*corev1.Pod, window TimeWindow) ([]stepEnergy, error) {
+	specs := c.nodeSpecs(node)
+
+	var nodePods []*corev1.Pod
+	for _, pod := range pods {
+		if pod.Spec.NodeName == node.Name {
+			nodePods = append(nodePods, pod)
 		}
 	}
-	
-	// Calculate CPU utilization ratio
+
+	// Node usage also covers system daemons running outside pods
+	measured := c.measureNode(ctx, node, window)
+	measuredEnergy := c.measureNodeEnergy(ctx, node, window)
 	nodeCPUCapacity := float64(node.Status.Capacity.Cpu().MilliValue())
-	cpuUtilization := totalCPURequests / nodeCPUCapacity
-	if cpuUtilization > 1.0 {
-		cpuUtilization = 1.0
-	}
-	
-	// Calculate memory utilization ratio
-	nodeMemoryCapacity := float64(node.Status.Capacity.Memory().Value())
-	memoryUtilization := totalMemoryRequests / nodeMemoryCapacity
-	if memoryUtilization > 1.0 {
-		memoryUtilization = 1.0
-	}
-	
-	// Use linear scaling based on CPU utilization (simplified model)
-	// More sophisticated models would consider CPU scaling factors
-	utilizationFactor := 0.3 + (0.7 * cpuUtilization) // Min 30% of TDP, scales to 100%
-	energyWatts := baseEnergyWatts * utilizationFactor
-	
-	// Convert to kWh (assuming 1 hour measurement period)
-	energyKWh := energyWatts / 1000.0
-	
-	return energyKWh, nil
-}
-
-// calculatePodEnergyConsumption calculates energy consumption for a pod
-func (c *carbonCalculator) calculatePodEnergyConsumption(ctx context.Context, pod *corev1.Pod) (float64, error) {
-	// Get the node this pod is running on to understand the instance type
+
+	steps := window.Steps()
+	energy := make([]stepEnergy, len(steps))
+	for i, start := range steps {
+		sample := stepUsage(measured, i)
+		if kWh := stepEnergyOf(measuredEnergy, i); kWh != nil {
+			energy[i] = stepEnergy{energy: *kWh, usage: sample, measured: true}
+			continue
+		}
+
+		allocated := allocate(c.config.AllocationMode, stepRequests(window, start, nodePods), sample)
+
+		cpuUtilization := 0.0
+		if nodeCPUCapacity > 0 {
+			cpuUtilization = allocated.CPUMillicores / nodeCPUCapacity
+		}
+
+		energyWatts := c.energyModel.NodePower(specs, cpuUtilization)
+		energy[i] = stepEnergy{
+			energy: energyWatts * activeHours(window, start, node.CreationTimestamp.Time, time.Time{}) / 1000.0,
+			usage:  sample,
+		}
+	}
+
+	return energy, nil
+}
+
+// calculatePodEnergyConsumption calculates the energy a pod running on node, which may be nil,
+// consumes in each step of window. Measured energy is used where the energy source has it, the
+// model estimates the other steps. Modelled steps before the pod was created or after it completed
+// consume nothing.
+func (c *carbonCalculator) calculatePodEnergyConsumption(ctx context.Context, pod *corev1.Pod, node *corev1.Node, window TimeWindow) ([]stepEnergy, error) {
 	if pod.Spec.NodeName == "" {
-		return 0, fmt.Errorf("pod %s/%s is not scheduled to a node", pod.Namespace, pod.Name)
-	}
-	
-	// Calculate resource requests for the pod
-	totalCPURequests := float64(0)
-	totalMemoryRequests := float64(0)
-	
-	for _, container := range pod.Spec.Containers {
-		if cpu := container.Resources.Requests.Cpu(); cpu != nil {
-			totalCPURequests += float64(cpu.MilliValue())
+		return nil, fmt.Errorf("pod %s/%s is not scheduled to a node", pod.Namespace, pod.Name)
+	}
+
+	specs := c.nodeSpecs(node)
+	requests := podRequests(pod)
+	measured := c.measurePod(ctx, pod, window)
+	measuredEnergy := c.measurePodEnergy(ctx, pod, window)
+	ended := podEndTime(pod)
+
+	steps := window.Steps()
+	energy := make([]stepEnergy, len(steps))
+	for i, start := range steps {
+		sample := stepUsage(measured, i)
+		if kWh := stepEnergyOf(measuredEnergy, i); kWh != nil {
+			energy[i] = stepEnergy{energy: *kWh, usage: sample, measured: true}
+			continue
 		}
-		if memory := container.Resources.Requests.Memory(); memory != nil {
-			totalMemoryRequests += float64(memory.Value())
+
+		// Requests, usage or the larger of both depending on the allocation mode
+		allocated := allocate(c.config.AllocationMode, requests, sample)
+
+		// Estimate energy priced by the hardware of the pod's node
+		energyWatts := c.energyModel.WorkloadPower(specs, allocated.CPUMillicores/1000.0, allocated.MemoryBytes/bytesPerGiB)
+		energy[i] = stepEnergy{
+			energy: energyWatts * activeHours(window, start, pod.CreationTimestamp.Time, ended) / 1000.0,
+			usage:  sample,
 		}
 	}
-	
-	// Estimate energy based on resource requests
-	// This is a simplified model - production systems would use actual utilization metrics
-	
-	// CPU energy estimation: ~2.5W per 1000 millicores at 100% utilization
-	cpuEnergyWatts := (totalCPURequests / 1000.0) * 2.5
-	
-	// Memory energy estimation: ~0.375W per GB
-	memoryEnergyWatts := (totalMemoryRequests / (1024 * 1024 * 1024)) * 0.375
-	
-	// Total energy consumption
-	totalEnergyWatts := cpuEnergyWatts + memoryEnergyWatts
-	
-	// Convert to kWh (assuming 1 hour measurement period)
-	energyKWh := totalEnergyWatts / 1000.0
-	
-	return energyKWh, nil
+
+	return energy, nil
+}
+
+// podEndTime returns when the last container of a completed pod finished, zero while the pod runs
+func podEndTime(pod *corev1.Pod) time.Time {
+	if pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+		return time.Time{}
+	}
+
+	var ended time.Time
+	for _, status := range pod.Status.ContainerStatuses {
+		if terminated := status.State.Terminated; terminated != nil && terminated.FinishedAt.After(ended) {
+			ended = terminated.FinishedAt.Time
+		}
+	}
+	return ended
 }
 
 // ParseQuery parses a JSON query into a Query struct
@@ -365,11 +754,11 @@ func ParseQuery(queryJSON []byte) (*Query, error) {
 }
 
 // ConvertToDataFrames converts carbon metrics to Grafana data frames
-func ConvertToDataFrames(metrics []*Metrics, query *Query) ([]*backend.DataFrame, error) {
-	if len(metrics) == 0 {
-		return []*backend.DataFrame{}, nil
+func ConvertToDataFrames(metrics []*Metrics, query *Query) (data.Frames, error) {
+	if len(metrics) == 0 && len(query.previous) == 0 {
+		return data.Frames{}, nil
 	}
-	
+
 	// Create data frame based on query type
 	switch query.QueryType {
 	case "timeseries":
@@ -378,111 +767,198 @@ func ConvertToDataFrames(metrics []*Metrics, query *Query) ([]*backend.DataFrame
 		return convertToTableFrames(metrics, query)
 	case "single-value":
 		return convertToSingleValueFrames(metrics, query)
+	case "topk":
+		return convertToTopKFrames(metrics, query)
+	case "comparison":
+		return convertToComparisonFrames(metrics, query)
 	default:
 		return convertToTimeSeriesFrames(metrics, query)
 	}
 }
 
-// convertToTimeSeriesFrames converts metrics to time series data frames
-func convertToTimeSeriesFrames(metrics []*Metrics, query *Query) ([]*backend.DataFrame, error) {
-	frame := data.NewFrame(query.RefID)
-	
-	// Add time field
-	timeField := data.NewField("time", nil, make([]time.Time, len(metrics)))
-	co2Field := data.NewField("co2_emissions", nil, make([]float64, len(metrics)))
-	energyField := data.NewField("energy_consumption", nil, make([]float64, len(metrics)))
-	gridIntensityField := data.NewField("grid_intensity", nil, make([]float64, len(metrics)))
-	
-	// Set units
-	co2Field.Config = &data.FieldConfig{Unit: "gCO2"}
-	energyField.Config = &data.FieldConfig{Unit: "kWh"}
-	gridIntensityField.Config = &data.FieldConfig{Unit: "gCO2/kWh"}
-	
-	// Fill data
-	for i, metric := range metrics {
-		timeField.Set(i, metric.Timestamp)
-		co2Field.Set(i, metric.CO2Emissions)
-		energyField.Set(i, metric.EnergyConsumption)
-		gridIntensityField.Set(i, metric.GridIntensity)
-	}
-	
-	frame.Fields = append(frame.Fields, timeField, co2Field, energyField, gridIntensityField)
-	
-	return []*backend.DataFrame{frame.SetMeta(&data.FrameMeta{
-		Type: data.FrameTypeTimeSeriesMulti,
-	})}, nil
+// timeSeriesValues are the values of each time series, every one gets a frame of its own
+var timeSeriesValues = []struct {
+	name  string
+	unit  string
+	value func(*Metrics) float64
+}{
+	{"co2_emissions", "gCO2", func(m *Metrics) float64 { return m.CO2Emissions }},
+	{"energy_consumption", "kWh", func(m *Metrics) float64 { return m.EnergyConsumption }},
+	{"grid_intensity", "gCO2/kWh", func(m *Metrics) float64 { return m.GridIntensity }},
+}
+
+// convertToTimeSeriesFrames converts metrics to multi time series frames: one frame per resource or group
+// and value, holding a time field and a single value field. The value field is labelled with the resource,
+// its namespace, node and team and the group-by values, so that Grafana legends and overrides tell the
+// series apart.
+func convertToTimeSeriesFrames(metrics []*Metrics, query *Query) (data.Frames, error) {
+	frames := make(data.Frames, 0)
+	for _, series := range splitSeries(metrics) {
+		labels := seriesLabels(series[0], query)
+
+		for _, value := range timeSeriesValues {
+			timeField := data.NewField("time", nil, make([]time.Time, len(series)))
+			valueField := data.NewField(value.name, labels, make([]float64, len(series)))
+			valueField.Config = &data.FieldConfig{Unit: value.unit}
+
+			for i, metric := range series {
+				timeField.Set(i, metric.Timestamp)
+				valueField.Set(i, value.value(metric))
+			}
+
+			frame := data.NewFrame(query.RefID, timeField, valueField)
+			frames = append(frames, frame.SetMeta(&data.FrameMeta{
+				Type: data.FrameTypeTimeSeriesMulti,
+			}))
+		}
+	}
+
+	return frames, nil
+}
+
+// splitSeries splits metrics into one series per resource in the order they first appear,
+// each sorted by time
+func splitSeries(metrics []*Metrics) [][]*Metrics {
+	index := make(map[string]int)
+	var series [][]*Metrics
+	for _, m := range metrics {
+		key := seriesKey(m)
+		i, ok := index[key]
+		if !ok {
+			i = len(series)
+			index[key] = i
+			series = append(series, nil)
+		}
+		series[i] = append(series[i], m)
+	}
+
+	for _, s := range series {
+		sort.SliceStable(s, func(i, j int) bool {
+			return s[i].Timestamp.Before(s[j].Timestamp)
+		})
+	}
+	return series
+}
+
+// seriesKey identifies the resource a metric belongs to across the steps of a window
+func seriesKey(m *Metrics) string {
+	return m.ResourceType + "/" + m.Namespace + "/" + m.NodeName + "/" + m.ResourceName
 }
 
-// convertToTableFrames converts metrics to table data frames
-func convertToTableFrames(metrics []*Metrics, query *Query) ([]*backend.DataFrame, error) {
+// seriesLabels returns the frame labels of the series m belongs to
+func seriesLabels(m *Metrics, query *Query) data.Labels {
+	labels := data.Labels{"resource": m.ResourceName}
+	if m.Namespace != "" {
+		labels["namespace"] = m.Namespace
+	}
+	if m.NodeName != "" {
+		labels["node"] = m.NodeName
+	}
+	if m.Team != "" {
+		labels["team"] = m.Team
+	}
+
+	// Grouped metrics carry their group values as labels
+	for _, key := range query.GroupBy {
+		labels[key] = m.Labels[key]
+	}
+	return labels
+}
+
+// convertToTableFrames converts metrics to a table data frame with one row per resource summed
+// over the time range, optionally sorted and limited to the first rows
+func convertToTableFrames(metrics []*Metrics, query *Query) (data.Frames, error) {
+	rows := summarizeSeries(metrics)
+
+	if query.SortBy != "" {
+		if err := sortRows(rows, query.SortBy, query.SortDesc); err != nil {
+			return nil, err
+		}
+	}
+	if query.Limit > 0 && len(rows) > query.Limit {
+		rows = rows[:query.Limit]
+	}
+
+	columns, err := selectColumns(rows, query)
+	if err != nil {
+		return nil, err
+	}
+
 	frame := data.NewFrame(query.RefID)
-	
-	// Add fields for table view
-	resourceField := data.NewField("resource", nil, make([]string, len(metrics)))
-	namespaceField := data.NewField("namespace", nil, make([]string, len(metrics)))
-	co2Field := data.NewField("co2_emissions", nil, make([]float64, len(metrics)))
-	energyField := data.NewField("energy_consumption", nil, make([]float64, len(metrics)))
-	
-	co2Field.Config = &data.FieldConfig{Unit: "gCO2"}
-	energyField.Config = &data.FieldConfig{Unit: "kWh"}
-	
-	for i, metric := range metrics {
-		resourceField.Set(i, metric.ResourceName)
-		namespaceField.Set(i, metric.Namespace)
-		co2Field.Set(i, metric.CO2Emissions)
-		energyField.Set(i, metric.EnergyConsumption)
-	}
-	
-	frame.Fields = append(frame.Fields, resourceField, namespaceField, co2Field, energyField)
-	
-	return []*backend.DataFrame{frame.SetMeta(&data.FrameMeta{
+	for _, column := range columns {
+		frame.Fields = append(frame.Fields, newColumnField(column, rows))
+	}
+
+	return data.Frames{frame.SetMeta(&data.FrameMeta{
 		Type: data.FrameTypeTable,
 	})}, nil
 }
 
-// convertToSingleValueFrames converts metrics to single value data frames
-func convertToSingleValueFrames(metrics []*Metrics, query *Query) ([]*backend.DataFrame, error) {
-	// Aggregate metrics based on the specified aggregation method
-	var value float64
-	
-	switch query.Aggregation {
-	case "sum":
-		for _, metric := range metrics {
-			value += metric.CO2Emissions
+// convertToSingleValueFrames converts metrics to a single-value data frame with a field per metric and aggregation
+func convertToSingleValueFrames(metrics []*Metrics, query *Query) (data.Frames, error) {
+	frame := data.NewFrame(query.RefID)
+
+	// The original form of the query: one aggregation of CO2, defaulting to the sum
+	if len(query.Metrics) == 0 && len(query.Aggregations) == 0 {
+		aggregation := query.Aggregation
+		if aggregation == "" {
+			aggregation = AggregationSum
 		}
-	case "avg":
-		for _, metric := range metrics {
-			value += metric.CO2Emissions
+		values, weights, err := singleValueSamples(ValueCO2, metrics, query)
+		if err != nil {
+			return nil, err
 		}
-		if len(metrics) > 0 {
-			value /= float64(len(metrics))
+		value, err := aggregate(aggregation, values, weights)
+		if err != nil {
+			return nil, err
 		}
-	case "max":
-		for i, metric := range metrics {
-			if i == 0 || metric.CO2Emissions > value {
-				value = metric.CO2Emissions
-			}
+
+		valueField := data.NewField("value", nil, []float64{value})
+		valueField.Config = &data.FieldConfig{Unit: valueUnits[ValueCO2]}
+		frame.Fields = append(frame.Fields, valueField)
+
+		return data.Frames{frame.SetMeta(&data.FrameMeta{
+			Type: data.FrameTypeNumericWide,
+		})}, nil
+	}
+
+	selected := query.Metrics
+	if len(selected) == 0 {
+		selected = []string{ValueCO2}
+	}
+	aggregations := query.Aggregations
+	if len(aggregations) == 0 {
+		aggregations = []string{query.Aggregation}
+		if query.Aggregation == "" {
+			aggregations = []string{AggregationSum}
 		}
-	case "min":
-		for i, metric := range metrics {
-			if i == 0 || metric.CO2Emissions < value {
-				value = metric.CO2Emissions
-			}
+	}
+
+	for _, metric := range selected {
+		values, weights, err := singleValueSamples(metric, metrics, query)
+		if err != nil {
+			return nil, err
 		}
-	default:
-		// Default to sum
-		for _, metric := range metrics {
-			value += metric.CO2Emissions
+
+		for _, aggregation := range aggregations {
+			value, err := aggregate(aggregation, values, weights)
+			if err != nil {
+				return nil, err
+			}
+
+			field := data.NewField(metric+"_"+aggregation, nil, []float64{value})
+			unit := valueUnits[metric]
+			if metric == ValueCost {
+				unit = query.costUnit
+			}
+			if aggregation != AggregationCount && unit != "" {
+				field.Config = &data.FieldConfig{Unit: unit}
+			}
+			frame.Fields = append(frame.Fields, field)
 		}
 	}
-	
-	frame := data.NewFrame(query.RefID)
-	valueField := data.NewField("value", nil, []float64{value})
-	valueField.Config = &data.FieldConfig{Unit: "gCO2"}
-	
-	frame.Fields = append(frame.Fields, valueField)
-	
-	return []*backend.DataFrame{frame.SetMeta(&data.FrameMeta{
-		Type: data.FrameTypeSingleValue,
+
+	return data.Frames{frame.SetMeta(&data.FrameMeta{
+		Type: data.FrameTypeNumericWide,
 	})}, nil
-}
\ No newline at end of file
+}
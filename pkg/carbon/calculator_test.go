@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/grafana/grafana-plugin-sdk-go/data"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -13,7 +14,7 @@ import (
 func TestCarbonCalculator(t *testing.T) {
 	config := &CarbonConfig{
 		DefaultGridIntensity:    500.0, // gCO2/kWh
-		PUE:                    1.5,
+		PUE:                     1.5,
 		EnableNetworkAccounting: true,
 		EnableStorageAccounting: true,
 	}
@@ -25,7 +26,7 @@ func TestCarbonCalculator(t *testing.T) {
 		nodes := createTestNodes()
 		pods := createTestPods()
 
-		metrics, err := calculator.CalculateClusterCarbon(ctx, nodes, pods)
+		metrics, err := calculator.CalculateClusterCarbon(ctx, nodes, pods, defaultWindow(time.Now()))
 		if err != nil {
 			t.Fatalf("CalculateClusterCarbon failed: %v", err)
 		}
@@ -63,7 +64,7 @@ func TestCarbonCalculator(t *testing.T) {
 		}
 		pods := createTestPods()
 
-		metrics, err := calculator.CalculateNamespaceCarbon(ctx, namespace, pods)
+		metrics, err := calculator.CalculateNamespaceCarbon(ctx, namespace, createTestNodes(), pods, nil, defaultWindow(time.Now()))
 		if err != nil {
 			t.Fatalf("CalculateNamespaceCarbon failed: %v", err)
 		}
@@ -90,7 +91,7 @@ func TestCarbonCalculator(t *testing.T) {
 		node := createTestNodes()[0]
 		pods := createTestPods()
 
-		metrics, err := calculator.CalculateNodeCarbon(ctx, node, pods)
+		metrics, err := calculator.CalculateNodeCarbon(ctx, node, pods, defaultWindow(time.Now()))
 		if err != nil {
 			t.Fatalf("CalculateNodeCarbon failed: %v", err)
 		}
@@ -116,7 +117,7 @@ func TestCarbonCalculator(t *testing.T) {
 	t.Run("CalculatePodCarbon", func(t *testing.T) {
 		pod := createTestPods()[0]
 
-		metrics, err := calculator.CalculatePodCarbon(ctx, pod)
+		metrics, err := calculator.CalculatePodCarbon(ctx, pod, nil, nil, defaultWindow(time.Now()))
 		if err != nil {
 			t.Fatalf("CalculatePodCarbon failed: %v", err)
 		}
@@ -150,12 +151,12 @@ func TestCarbonCalculator(t *testing.T) {
 			createPodWithResources("low-cpu-pod", "test", "100m", "256Mi"),
 		}
 
-		highCPUMetrics, err := calculator.CalculatePodCarbon(ctx, pods[0])
+		highCPUMetrics, err := calculator.CalculatePodCarbon(ctx, pods[0], nil, nil, defaultWindow(time.Now()))
 		if err != nil {
 			t.Fatalf("Failed to calculate high CPU pod carbon: %v", err)
 		}
 
-		lowCPUMetrics, err := calculator.CalculatePodCarbon(ctx, pods[1])
+		lowCPUMetrics, err := calculator.CalculatePodCarbon(ctx, pods[1], nil, nil, defaultWindow(time.Now()))
 		if err != nil {
 			t.Fatalf("Failed to calculate low CPU pod carbon: %v", err)
 		}
@@ -176,9 +177,9 @@ func TestCarbonCalculator(t *testing.T) {
 	t.Run("PUEApplication", func(t *testing.T) {
 		// Test that PUE is correctly applied
 		pod := createTestPods()[0]
-		
+
 		// Calculate with default PUE (1.5)
-		metrics, err := calculator.CalculatePodCarbon(ctx, pod)
+		metrics, err := calculator.CalculatePodCarbon(ctx, pod, nil, nil, defaultWindow(time.Now()))
 		if err != nil {
 			t.Fatalf("Failed to calculate pod carbon: %v", err)
 		}
@@ -188,11 +189,11 @@ func TestCarbonCalculator(t *testing.T) {
 		// Create calculator with different PUE
 		configNoPUE := &CarbonConfig{
 			DefaultGridIntensity: 500.0,
-			PUE:                 1.0, // No datacenter overhead
+			PUE:                  1.0, // No datacenter overhead
 		}
 		calculatorNoPUE := NewCarbonCalculator(configNoPUE)
 
-		metricsNoPUE, err := calculatorNoPUE.CalculatePodCarbon(ctx, pod)
+		metricsNoPUE, err := calculatorNoPUE.CalculatePodCarbon(ctx, pod, nil, nil, defaultWindow(time.Now()))
 		if err != nil {
 			t.Fatalf("Failed to calculate pod carbon with no PUE: %v", err)
 		}
@@ -224,7 +225,7 @@ func TestCarbonCalculator(t *testing.T) {
 			},
 		}
 
-		_, err := calculator.CalculatePodCarbon(ctx, unscheduledPod)
+		_, err := calculator.CalculatePodCarbon(ctx, unscheduledPod, nil, nil, defaultWindow(time.Now()))
 		if err == nil {
 			t.Error("Expected error for unscheduled pod, got nil")
 		}
@@ -233,13 +234,13 @@ func TestCarbonCalculator(t *testing.T) {
 	t.Run("ThreadSafety", func(t *testing.T) {
 		// Test concurrent access to calculator
 		pod := createTestPods()[0]
-		
+
 		done := make(chan bool, 10)
 		for i := 0; i < 10; i++ {
 			go func() {
 				defer func() { done <- true }()
-				
-				_, err := calculator.CalculatePodCarbon(ctx, pod)
+
+				_, err := calculator.CalculatePodCarbon(ctx, pod, nil, nil, defaultWindow(time.Now()))
 				if err != nil {
 					t.Errorf("Concurrent calculation failed: %v", err)
 				}
@@ -253,6 +254,125 @@ func TestCarbonCalculator(t *testing.T) {
 	})
 }
 
+func TestCalculatorTimeSeries(t *testing.T) {
+	ctx := context.Background()
+	calculator := NewCarbonCalculator(&CarbonConfig{DefaultGridIntensity: 400, PUE: 1.0})
+	pod := createTestPods()[0]
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	hourly, err := calculator.CalculatePodCarbon(ctx, pod, nil, nil, TimeWindow{From: from, To: from.Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("CalculatePodCarbon failed: %v", err)
+	}
+
+	t.Run("OnePointPerStep", func(t *testing.T) {
+		window := TimeWindow{From: from, To: from.Add(time.Hour), Step: 15 * time.Minute}
+		metrics, err := calculator.CalculatePodCarbon(ctx, pod, nil, nil, window)
+		if err != nil {
+			t.Fatalf("CalculatePodCarbon failed: %v", err)
+		}
+		if len(metrics) != 4 {
+			t.Fatalf("Expected 4 points, got %d", len(metrics))
+		}
+		for i, metric := range metrics {
+			if want := from.Add(time.Duration(i) * 15 * time.Minute); !metric.Timestamp.Equal(want) {
+				t.Errorf("Expected point %d at %s, got %s", i, want, metric.Timestamp)
+			}
+			// Energy scales with the step length
+			if abs(metric.EnergyConsumption-hourly[0].EnergyConsumption/4) > 1e-12 {
+				t.Errorf("Expected a quarter of the hourly energy, got %f of %f", metric.EnergyConsumption, hourly[0].EnergyConsumption)
+			}
+		}
+	})
+
+	t.Run("CreatedMidStep", func(t *testing.T) {
+		created := createPodWithResources("new-pod", "production", "500m", "1Gi")
+		created.CreationTimestamp = metav1.NewTime(from.Add(45 * time.Minute))
+
+		window := TimeWindow{From: from, To: from.Add(time.Hour), Step: 30 * time.Minute}
+		metrics, err := calculator.CalculatePodCarbon(ctx, created, nil, nil, window)
+		if err != nil {
+			t.Fatalf("CalculatePodCarbon failed: %v", err)
+		}
+		if metrics[0].EnergyConsumption != 0 {
+			t.Errorf("Expected no energy before the pod existed, got %f", metrics[0].EnergyConsumption)
+		}
+		if abs(metrics[1].EnergyConsumption-hourly[0].EnergyConsumption/4) > 1e-12 {
+			t.Errorf("Expected energy for the last 15 minutes only, got %f", metrics[1].EnergyConsumption)
+		}
+	})
+
+	t.Run("CompletedMidStep", func(t *testing.T) {
+		completed := createPodWithResources("job-pod", "production", "500m", "1Gi")
+		completed.Status.Phase = corev1.PodSucceeded
+		completed.Status.ContainerStatuses = []corev1.ContainerStatus{
+			{Name: "main", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{FinishedAt: metav1.NewTime(from.Add(15 * time.Minute))}}},
+			{Name: "sidecar", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{FinishedAt: metav1.NewTime(from.Add(5 * time.Minute))}}},
+		}
+
+		window := TimeWindow{From: from, To: from.Add(time.Hour), Step: 30 * time.Minute}
+		metrics, err := calculator.CalculatePodCarbon(ctx, completed, nil, nil, window)
+		if err != nil {
+			t.Fatalf("CalculatePodCarbon failed: %v", err)
+		}
+		// The pod ran until its last container finished
+		if abs(metrics[0].EnergyConsumption-hourly[0].EnergyConsumption/4) > 1e-12 {
+			t.Errorf("Expected energy for the first 15 minutes only, got %f", metrics[0].EnergyConsumption)
+		}
+		if metrics[1].EnergyConsumption != 0 {
+			t.Errorf("Expected no energy after the pod completed, got %f", metrics[1].EnergyConsumption)
+		}
+	})
+
+	t.Run("NodeWithCompletedPod", func(t *testing.T) {
+		node := createTestNodes()[0]
+		completed := createPodWithResources("job-pod", "production", "1", "0")
+		completed.Status.Phase = corev1.PodSucceeded
+		completed.Status.ContainerStatuses = []corev1.ContainerStatus{
+			{Name: "main", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{FinishedAt: metav1.NewTime(from.Add(15 * time.Minute))}}},
+		}
+		// Half the CPU for half of the first step requests as much as a quarter of it for the whole step
+		running := createPodWithResources("web-pod", "production", "500m", "0")
+
+		window := TimeWindow{From: from, To: from.Add(time.Hour), Step: 30 * time.Minute}
+		withCompleted, err := calculator.CalculateNodeCarbon(ctx, node, []*corev1.Pod{completed}, window)
+		if err != nil {
+			t.Fatalf("CalculateNodeCarbon failed: %v", err)
+		}
+		withRunning, err := calculator.CalculateNodeCarbon(ctx, node, []*corev1.Pod{running}, window)
+		if err != nil {
+			t.Fatalf("CalculateNodeCarbon failed: %v", err)
+		}
+		idle, err := calculator.CalculateNodeCarbon(ctx, node, nil, window)
+		if err != nil {
+			t.Fatalf("CalculateNodeCarbon failed: %v", err)
+		}
+
+		if abs(withCompleted[0].EnergyConsumption-withRunning[0].EnergyConsumption) > 1e-12 {
+			t.Errorf("Expected the completed pod's requests for 15 minutes only, got %f want %f",
+				withCompleted[0].EnergyConsumption, withRunning[0].EnergyConsumption)
+		}
+		if abs(withCompleted[1].EnergyConsumption-idle[1].EnergyConsumption) > 1e-12 {
+			t.Errorf("Expected an idle node after the pod completed, got %f want %f",
+				withCompleted[1].EnergyConsumption, idle[1].EnergyConsumption)
+		}
+	})
+
+	t.Run("ClusterSeries", func(t *testing.T) {
+		window := TimeWindow{From: from, To: from.Add(2 * time.Hour), Step: time.Hour}
+		metrics, err := calculator.CalculateClusterCarbon(ctx, createTestNodes(), createTestPods(), window)
+		if err != nil {
+			t.Fatalf("CalculateClusterCarbon failed: %v", err)
+		}
+		if len(metrics) != 2 {
+			t.Fatalf("Expected 2 points, got %d", len(metrics))
+		}
+		if metrics[0].CO2Emissions != metrics[1].CO2Emissions {
+			t.Errorf("Expected equal emissions for equal steps, got %f and %f", metrics[0].CO2Emissions, metrics[1].CO2Emissions)
+		}
+	})
+}
+
 func TestParseQuery(t *testing.T) {
 	t.Run("ValidQuery", func(t *testing.T) {
 		queryJSON := []byte(`{
@@ -319,7 +439,7 @@ func TestConvertToDataFrames(t *testing.T) {
 			CO2Emissions:      100.5,
 			EnergyConsumption: 0.2,
 			GridIntensity:     500,
-			Source:           "calculated",
+			Source:            "calculated",
 		},
 		{
 			Timestamp:         now.Add(-1 * time.Minute),
@@ -329,7 +449,7 @@ func TestConvertToDataFrames(t *testing.T) {
 			CO2Emissions:      105.2,
 			EnergyConsumption: 0.21,
 			GridIntensity:     502,
-			Source:           "calculated",
+			Source:            "calculated",
 		},
 	}
 
@@ -344,26 +464,69 @@ func TestConvertToDataFrames(t *testing.T) {
 			t.Fatalf("ConvertToDataFrames failed: %v", err)
 		}
 
-		if len(frames) != 1 {
-			t.Errorf("Expected 1 frame, got %d", len(frames))
+		// One frame per value, each a time field and a single labelled value field
+		expectedValues := []string{"co2_emissions", "energy_consumption", "grid_intensity"}
+		if len(frames) != len(expectedValues) {
+			t.Fatalf("Expected %d frames, got %d", len(expectedValues), len(frames))
+		}
+		for i, frame := range frames {
+			if len(frame.Fields) != 2 {
+				t.Fatalf("Expected 2 fields, got %d", len(frame.Fields))
+			}
+			if frame.Meta.Type != data.FrameTypeTimeSeriesMulti {
+				t.Errorf("Expected multi time series frames, got %s", frame.Meta.Type)
+			}
+			if frame.Fields[0].Name != "time" || frame.Fields[0].Type() != data.FieldTypeTime {
+				t.Errorf("Expected a time field first, got %s of %s", frame.Fields[0].Name, frame.Fields[0].Type())
+			}
+			value := frame.Fields[1]
+			if value.Name != expectedValues[i] || value.Type() != data.FieldTypeFloat64 {
+				t.Errorf("Expected value field %s, got %s of %s", expectedValues[i], value.Name, value.Type())
+			}
+			if value.Labels["resource"] != "test-pod-1" {
+				t.Errorf("Expected the value field to be labelled with test-pod-1, got %v", value.Labels)
+			}
+			if value.Len() != 2 {
+				t.Errorf("Expected 2 data points, got %d", value.Len())
+			}
 		}
+	})
 
-		frame := frames[0]
-		if len(frame.Fields) != 4 {
-			t.Errorf("Expected 4 fields, got %d", len(frame.Fields))
+	t.Run("TimeSeriesFramePerResource", func(t *testing.T) {
+		query := &Query{
+			RefID:     "A",
+			QueryType: "timeseries",
+			GroupBy:   []string{"zone"},
+		}
+		other := &Metrics{
+			Timestamp:    now.Add(-2 * time.Minute),
+			ResourceType: "pod",
+			ResourceName: "test-pod-2",
+			Namespace:    "production",
+			NodeName:     "test-node-1",
+			CO2Emissions: 50,
+			Labels:       map[string]string{"zone": "us-west-2a"},
 		}
 
-		// Check field names
-		expectedFields := []string{"time", "co2_emissions", "energy_consumption", "grid_intensity"}
-		for i, field := range frame.Fields {
-			if field.Name != expectedFields[i] {
-				t.Errorf("Expected field name %s, got %s", expectedFields[i], field.Name)
-			}
+		frames, err := ConvertToDataFrames(append([]*Metrics{other}, metrics...), query)
+		if err != nil {
+			t.Fatalf("ConvertToDataFrames failed: %v", err)
+		}
+
+		// Three values of two resources
+		if len(frames) != 6 {
+			t.Fatalf("Expected 6 frames, got %d", len(frames))
 		}
 
-		// Check data length
-		if frame.Fields[0].Len() != 2 {
-			t.Errorf("Expected 2 data points, got %d", frame.Fields[0].Len())
+		labels := frames[0].Fields[1].Labels
+		if labels["resource"] != "test-pod-2" || labels["namespace"] != "production" || labels["node"] != "test-node-1" || labels["zone"] != "us-west-2a" {
+			t.Errorf("Expected labels of test-pod-2, got %v", labels)
+		}
+		if labels := frames[3].Fields[1].Labels; labels["resource"] != "test-pod-1" {
+			t.Errorf("Expected labels of test-pod-1, got %v", labels)
+		}
+		if frames[0].Rows() != 1 || frames[3].Rows() != 2 {
+			t.Errorf("Expected 1 and 2 points, got %d and %d", frames[0].Rows(), frames[3].Rows())
 		}
 	})
 
@@ -371,6 +534,7 @@ func TestConvertToDataFrames(t *testing.T) {
 		query := &Query{
 			RefID:     "B",
 			QueryType: "table",
+			costUnit:  currencyUnit("EUR"),
 		}
 
 		frames, err := ConvertToDataFrames(metrics, query)
@@ -383,17 +547,79 @@ func TestConvertToDataFrames(t *testing.T) {
 		}
 
 		frame := frames[0]
-		if len(frame.Fields) != 4 {
-			t.Errorf("Expected 4 fields, got %d", len(frame.Fields))
+		expectedFields := []string{
+			"resource", "resource_type", "namespace", "node", "team",
+			"co2_emissions", "energy_consumption", "cost", "grid_intensity", "intensity_basis", "source",
+			"cpu_usage", "memory_usage", "storage_usage", "network_traffic", "labels",
+		}
+		if len(frame.Fields) != len(expectedFields) {
+			t.Fatalf("Expected %d fields, got %d", len(expectedFields), len(frame.Fields))
 		}
 
 		// Check field names for table
-		expectedFields := []string{"resource", "namespace", "co2_emissions", "energy_consumption"}
 		for i, field := range frame.Fields {
 			if field.Name != expectedFields[i] {
 				t.Errorf("Expected field name %s, got %s", expectedFields[i], field.Name)
 			}
 		}
+
+		// Both steps of the pod sum into one row
+		if frame.Rows() != 1 {
+			t.Errorf("Expected 1 row, got %d", frame.Rows())
+		}
+		if co2 := frame.Fields[5].At(0).(float64); abs(co2-(100.5+105.2)) > 1e-9 {
+			t.Errorf("Expected %f gCO2, got %f", 100.5+105.2, co2)
+		}
+		if unit := frame.Fields[8].Config.Unit; unit != "gCO2/kWh" {
+			t.Errorf("Expected unit gCO2/kWh, got %s", unit)
+		}
+		if frame.Fields[7].Config == nil || frame.Fields[7].Config.Unit != "currencyEUR" {
+			t.Errorf("Expected the cost column in the configured currency, got %+v", frame.Fields[7].Config)
+		}
+	})
+
+	t.Run("TableColumnsSortAndLimit", func(t *testing.T) {
+		rows := []*Metrics{
+			{Timestamp: now, ResourceType: "pod", ResourceName: "small", CO2Emissions: 1, Labels: map[string]string{"app": "web"}},
+			{Timestamp: now, ResourceType: "pod", ResourceName: "large", CO2Emissions: 30, Labels: map[string]string{"app": "db", "tier": "data"}},
+			{Timestamp: now, ResourceType: "pod", ResourceName: "medium", CO2Emissions: 20},
+		}
+		query := &Query{
+			RefID:         "B",
+			QueryType:     "table",
+			Columns:       []string{"resource", "co2_emissions", "labels"},
+			FlattenLabels: true,
+			SortBy:        "co2_emissions",
+			SortDesc:      true,
+			Limit:         2,
+		}
+
+		frames, err := ConvertToDataFrames(rows, query)
+		if err != nil {
+			t.Fatalf("ConvertToDataFrames failed: %v", err)
+		}
+
+		frame := frames[0]
+		expectedFields := []string{"resource", "co2_emissions", "labels.app", "labels.tier"}
+		if len(frame.Fields) != len(expectedFields) {
+			t.Fatalf("Expected %d fields, got %d", len(expectedFields), len(frame.Fields))
+		}
+		for i, field := range frame.Fields {
+			if field.Name != expectedFields[i] {
+				t.Errorf("Expected field name %s, got %s", expectedFields[i], field.Name)
+			}
+		}
+		if frame.Rows() != 2 || frame.Fields[0].At(0) != "large" || frame.Fields[0].At(1) != "medium" {
+			t.Errorf("Expected large and medium, got %d rows starting with %v", frame.Rows(), frame.Fields[0].At(0))
+		}
+		if frame.Fields[2].At(0) != "db" {
+			t.Errorf("Expected label app=db on the first row, got %v", frame.Fields[2].At(0))
+		}
+
+		query.Columns = []string{"owner"}
+		if _, err := ConvertToDataFrames(rows, query); err == nil {
+			t.Error("Expected error for unknown column, got nil")
+		}
 	})
 
 	t.Run("SingleValueFrames", func(t *testing.T) {
@@ -431,7 +657,7 @@ func TestConvertToDataFrames(t *testing.T) {
 
 	t.Run("EmptyMetrics", func(t *testing.T) {
 		query := &Query{RefID: "D", QueryType: "timeseries"}
-		
+
 		frames, err := ConvertToDataFrames([]*Metrics{}, query)
 		if err != nil {
 			t.Fatalf("ConvertToDataFrames failed: %v", err)
@@ -452,7 +678,7 @@ func createTestNodes() []*corev1.Node {
 				Name: "test-node-1",
 				Labels: map[string]string{
 					"beta.kubernetes.io/instance-type": "m5.large",
-					"topology.kubernetes.io/zone":       "us-west-2a",
+					"topology.kubernetes.io/zone":      "us-west-2a",
 				},
 			},
 			Status: corev1.NodeStatus{
@@ -467,7 +693,7 @@ func createTestNodes() []*corev1.Node {
 				Name: "test-node-2",
 				Labels: map[string]string{
 					"beta.kubernetes.io/instance-type": "m5.xlarge",
-					"topology.kubernetes.io/zone":       "us-west-2b",
+					"topology.kubernetes.io/zone":      "us-west-2b",
 				},
 			},
 			Status: corev1.NodeStatus{
@@ -519,4 +745,4 @@ func abs(x float64) float64 {
 		return -x
 	}
 	return x
-}
\ No newline at end of file
+}
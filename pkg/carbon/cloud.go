@@ -0,0 +1,210 @@
+package carbon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// Cloud providers
+const (
+	CloudProviderNone  = ""
+	CloudProviderAWS   = "aws"
+	CloudProviderAzure = "azure"
+	CloudProviderGCP   = "gcp"
+)
+
+// azureManagementScope is the token scope for Azure Resource Manager
+const azureManagementScope = "https://management.azure.com/.default"
+
+// CloudClient provides access to the cloud provider hosting the cluster
+type CloudClient interface {
+	TestConnection(ctx context.Context) error
+	Close() error
+}
+
+// CloudConfig holds configuration for the cloud provider hosting the cluster
+type CloudConfig struct {
+	Provider           string `json:"cloudProvider"` // "aws", "azure", "gcp" or empty
+	Region             string `json:"region"`
+	ClusterName        string `json:"clusterName"`
+	EnableCloudMetrics bool   `json:"enableCloudMetrics"`
+
+	AzureSubscriptionID string `json:"azureSubscriptionId"`
+	GCPProjectID        string `json:"gcpProjectId"`
+
+	// Secrets, only populated from secure JSON data
+	AWSAccessKey         string `json:"-"`
+	AWSSecretKey         string `json:"-"`
+	AzureTenantID        string `json:"-"`
+	AzureClientID        string `json:"-"`
+	AzureClientSecret    string `json:"-"`
+	GCPServiceAccountKey string `json:"-"` // JSON encoded
+}
+
+// NewCloudClient creates a client for the configured cloud provider.
+// Without a provider, or with cloud metrics disabled, a no-op client is returned.
+func NewCloudClient(config *CloudConfig) (CloudClient, error) {
+	if config == nil || config.Provider == CloudProviderNone || !config.EnableCloudMetrics {
+		return noopCloudClient{}, nil
+	}
+
+	switch config.Provider {
+	case CloudProviderAWS:
+		return newAWSCloudClient(config)
+	case CloudProviderAzure:
+		return newAzureCloudClient(config)
+	case CloudProviderGCP:
+		return newGCPCloudClient(config)
+	default:
+		return nil, fmt.Errorf("unsupported cloud provider: %s", config.Provider)
+	}
+}
+
+// noopCloudClient is used when no cloud provider integration is configured
+type noopCloudClient struct{}
+
+// TestConnection always succeeds
+func (noopCloudClient) TestConnection(ctx context.Context) error { return nil }
+
+// Close does nothing
+func (noopCloudClient) Close() error { return nil }
+
+// awsCloudClient talks to AWS CloudWatch
+type awsCloudClient struct {
+	cloudwatch *cloudwatch.Client
+}
+
+// newAWSCloudClient uses static credentials when configured, otherwise the default AWS chain
+func newAWSCloudClient(config *CloudConfig) (*awsCloudClient, error) {
+	opts := []func(*awsconfig.LoadOptions) error{
+		awsconfig.WithRegion(config.Region),
+	}
+	if config.AWSAccessKey != "" {
+		creds := aws.Credentials{
+			AccessKeyID:     config.AWSAccessKey,
+			SecretAccessKey: config.AWSSecretKey,
+			Source:          "k8scarbonfootprint",
+		}
+		opts = append(opts, awsconfig.WithCredentialsProvider(aws.CredentialsProviderFunc(
+			func(ctx context.Context) (aws.Credentials, error) { return creds, nil },
+		)))
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	return &awsCloudClient{cloudwatch: cloudwatch.NewFromConfig(cfg)}, nil
+}
+
+// TestConnection verifies the credentials can list CloudWatch metrics
+func (a *awsCloudClient) TestConnection(ctx context.Context) error {
+	if _, err := a.cloudwatch.ListMetrics(ctx, &cloudwatch.ListMetricsInput{}); err != nil {
+		return fmt.Errorf("failed to reach AWS CloudWatch: %w", err)
+	}
+	return nil
+}
+
+// Close releases nothing, the AWS SDK holds no long-lived resources
+func (a *awsCloudClient) Close() error {
+	return nil
+}
+
+// azureCloudClient authenticates against Azure Resource Manager
+type azureCloudClient struct {
+	credential azcore.TokenCredential
+}
+
+// newAzureCloudClient uses a service principal when configured, otherwise the default Azure chain
+func newAzureCloudClient(config *CloudConfig) (*azureCloudClient, error) {
+	var credential azcore.TokenCredential
+	var err error
+
+	if config.AzureClientSecret != "" {
+		credential, err = azidentity.NewClientSecretCredential(config.AzureTenantID, config.AzureClientID, config.AzureClientSecret, nil)
+	} else {
+		credential, err = azidentity.NewDefaultAzureCredential(nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure credential: %w", err)
+	}
+
+	return &azureCloudClient{credential: credential}, nil
+}
+
+// TestConnection verifies a management token can be acquired
+func (a *azureCloudClient) TestConnection(ctx context.Context) error {
+	if _, err := a.credential.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{azureManagementScope}}); err != nil {
+		return fmt.Errorf("failed to authenticate with Azure: %w", err)
+	}
+	return nil
+}
+
+// Close releases nothing, Azure credentials hold no long-lived resources
+func (a *azureCloudClient) Close() error {
+	return nil
+}
+
+// gcpCloudClient talks to the Compute Engine API of the project the cluster runs in
+type gcpCloudClient struct {
+	projectID string
+	zones     *compute.ZonesClient
+}
+
+// newGCPCloudClient uses the service account key when configured, otherwise application default
+// credentials. The project is read from the key when not set explicitly.
+func newGCPCloudClient(config *CloudConfig) (*gcpCloudClient, error) {
+	projectID := config.GCPProjectID
+	var opts []option.ClientOption
+
+	if config.GCPServiceAccountKey != "" {
+		var key struct {
+			ProjectID string `json:"project_id"`
+		}
+		if err := json.Unmarshal([]byte(config.GCPServiceAccountKey), &key); err != nil {
+			return nil, fmt.Errorf("failed to parse GCP service account key: %w", err)
+		}
+		if projectID == "" {
+			projectID = key.ProjectID
+		}
+		opts = append(opts, option.WithCredentialsJSON([]byte(config.GCPServiceAccountKey)))
+	}
+
+	zones, err := compute.NewZonesRESTClient(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCP compute client: %w", err)
+	}
+
+	return &gcpCloudClient{projectID: projectID, zones: zones}, nil
+}
+
+// TestConnection verifies the credentials can list the zones of the project
+func (g *gcpCloudClient) TestConnection(ctx context.Context) error {
+	if g.projectID == "" {
+		return fmt.Errorf("GCP project ID is not configured")
+	}
+
+	it := g.zones.List(ctx, &computepb.ListZonesRequest{Project: g.projectID})
+	if _, err := it.Next(); err != nil && err != iterator.Done {
+		return fmt.Errorf("failed to reach GCP Compute Engine: %w", err)
+	}
+	return nil
+}
+
+// Close releases the underlying HTTP connections
+func (g *gcpCloudClient) Close() error {
+	return g.zones.Close()
+}
@@ -0,0 +1,67 @@
+package carbon
+
+import (
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// convertToComparisonFrames converts the metrics of the selected range and of the period it is compared
+// to into a table with one row per resource: emissions of both periods, the change in grams and the
+// change in percent, empty when the resource emitted nothing before. Resources only present in one of
+// the periods count as zero in the other.
+func convertToComparisonFrames(metrics []*Metrics, query *Query) (data.Frames, error) {
+	current := summarizeSeries(metrics)
+	previous := summarizeSeries(query.previous)
+
+	previousByKey := make(map[string]*Metrics, len(previous))
+	for _, m := range previous {
+		previousByKey[seriesKey(m)] = m
+	}
+
+	// Resources of the selected range first, then those that have disappeared since
+	rows := make([]*Metrics, 0, len(current)+len(previous))
+	seen := make(map[string]bool, len(current))
+	for _, m := range current {
+		seen[seriesKey(m)] = true
+		rows = append(rows, m)
+	}
+	for _, m := range previous {
+		if !seen[seriesKey(m)] {
+			rows = append(rows, &Metrics{ResourceType: m.ResourceType, ResourceName: m.ResourceName, Namespace: m.Namespace, NodeName: m.NodeName})
+		}
+	}
+
+	resourceField := data.NewField("resource", nil, make([]string, len(rows)))
+	namespaceField := data.NewField("namespace", nil, make([]string, len(rows)))
+	co2Field := data.NewField("co2_emissions", nil, make([]float64, len(rows)))
+	previousField := data.NewField("previous_co2_emissions", nil, make([]float64, len(rows)))
+	deltaField := data.NewField("co2_delta", nil, make([]float64, len(rows)))
+	percentField := data.NewField("co2_delta_percent", nil, make([]*float64, len(rows)))
+
+	co2Field.Config = &data.FieldConfig{Unit: "gCO2"}
+	previousField.Config = &data.FieldConfig{Unit: "gCO2"}
+	deltaField.Config = &data.FieldConfig{Unit: "gCO2"}
+	percentField.Config = &data.FieldConfig{Unit: "percent"}
+
+	for i, m := range rows {
+		var before float64
+		if p, ok := previousByKey[seriesKey(m)]; ok {
+			before = p.CO2Emissions
+		}
+		delta := m.CO2Emissions - before
+
+		resourceField.Set(i, m.ResourceName)
+		namespaceField.Set(i, m.Namespace)
+		co2Field.Set(i, m.CO2Emissions)
+		previousField.Set(i, before)
+		deltaField.Set(i, delta)
+		if before != 0 {
+			percent := delta / before * 100
+			percentField.Set(i, &percent)
+		}
+	}
+
+	frame := data.NewFrame(query.RefID, resourceField, namespaceField, co2Field, previousField, deltaField, percentField)
+	return data.Frames{frame.SetMeta(&data.FrameMeta{
+		Type: data.FrameTypeTable,
+	})}, nil
+}
@@ -0,0 +1,182 @@
+package carbon
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestComparisonWindow(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	window := TimeWindow{From: from, To: from.AddDate(0, 1, 0), Step: 24 * time.Hour}
+
+	tests := []struct {
+		compareTo string
+		wantFrom  time.Time
+		wantTo    time.Time
+	}{
+		{"", from.Add(-31 * 24 * time.Hour), from},
+		{CompareToPrevious, from.Add(-31 * 24 * time.Hour), from},
+		{CompareToWeek, from.AddDate(0, 0, -7), from.AddDate(0, 1, -7)},
+		{CompareToMonth, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), from},
+	}
+
+	for _, tt := range tests {
+		t.Run("CompareTo"+tt.compareTo, func(t *testing.T) {
+			previous, err := comparisonWindow(window, tt.compareTo)
+			if err != nil {
+				t.Fatalf("comparisonWindow failed: %v", err)
+			}
+			if !previous.From.Equal(tt.wantFrom) || !previous.To.Equal(tt.wantTo) || previous.Step != window.Step {
+				t.Errorf("Expected %s to %s, got %s to %s", tt.wantFrom, tt.wantTo, previous.From, previous.To)
+			}
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		if _, err := comparisonWindow(window, "year"); err == nil {
+			t.Error("Expected error for unknown comparison period, got nil")
+		}
+	})
+}
+
+func TestComparisonFrames(t *testing.T) {
+	at := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	namespace := func(name string, co2 float64) *Metrics {
+		return &Metrics{Timestamp: at, ResourceType: "namespace", ResourceName: name, Namespace: name, CO2Emissions: co2}
+	}
+
+	query := &Query{
+		RefID:     "A",
+		QueryType: "comparison",
+		previous:  []*Metrics{namespace("production", 100), namespace("retired", 40)},
+	}
+	frames, err := ConvertToDataFrames([]*Metrics{namespace("production", 150), namespace("new", 20)}, query)
+	if err != nil {
+		t.Fatalf("ConvertToDataFrames failed: %v", err)
+	}
+
+	frame := frames[0]
+	expectedFields := []string{"resource", "namespace", "co2_emissions", "previous_co2_emissions", "co2_delta", "co2_delta_percent"}
+	for i, field := range frame.Fields {
+		if field.Name != expectedFields[i] {
+			t.Errorf("Expected field name %s, got %s", expectedFields[i], field.Name)
+		}
+	}
+	if frame.Rows() != 3 {
+		t.Fatalf("Expected production, new and retired, got %d rows", frame.Rows())
+	}
+
+	tests := []struct {
+		resource    string
+		wantDelta   float64
+		wantPercent *float64
+	}{
+		{"production", 50, floatPtr(50)},
+		{"new", 20, nil},
+		{"retired", -40, floatPtr(-100)},
+	}
+	for i, tt := range tests {
+		if name := frame.Fields[0].At(i); name != tt.resource {
+			t.Errorf("Expected row %d to be %s, got %v", i, tt.resource, name)
+			continue
+		}
+		if delta := frame.Fields[4].At(i).(float64); delta != tt.wantDelta {
+			t.Errorf("Expected %s to change by %f g, got %f", tt.resource, tt.wantDelta, delta)
+		}
+		percent := frame.Fields[5].At(i).(*float64)
+		if (percent == nil) != (tt.wantPercent == nil) || (percent != nil && abs(*percent-*tt.wantPercent) > 1e-9) {
+			t.Errorf("Expected %s to change by %v%%, got %v", tt.resource, tt.wantPercent, percent)
+		}
+	}
+}
+
+func TestDatasourceComparison(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDatasource()
+
+	t.Run("StaticIntensity", func(t *testing.T) {
+		res := d.query(ctx, backend.PluginContext{}, backend.DataQuery{
+			RefID: "A",
+			JSON:  []byte(`{"refId":"A","queryType":"comparison","resourceType":"namespace","compareTo":"week"}`),
+		})
+		if res.Error != nil {
+			t.Fatalf("query failed: %v", res.Error)
+		}
+
+		// Nothing changes between the periods with a static intensity and the same pods
+		frame := res.Frames[0]
+		for i := 0; i < frame.Rows(); i++ {
+			if delta := frame.Fields[4].At(i).(float64); abs(delta) > 1e-9 {
+				t.Errorf("Expected no change for %v, got %f g", frame.Fields[0].At(i), delta)
+			}
+		}
+		if frame.Meta != nil && len(frame.Meta.Notices) > 0 {
+			t.Errorf("Expected no notices for pods older than both periods, got %v", frame.Meta.Notices)
+		}
+	})
+
+	t.Run("PodCreatedInCurrentWindow", func(t *testing.T) {
+		d := newTestDatasource()
+		client := d.kubernetesClient.(*FakeKubernetesClient)
+		created := createPodWithResources("web-2", "production", "500m", "1Gi")
+		created.Spec.NodeName = "node-1"
+		created.CreationTimestamp = metav1.NewTime(time.Now().Add(-30 * time.Minute))
+		client.Pods = append(client.Pods, created)
+
+		res := d.query(ctx, backend.PluginContext{}, backend.DataQuery{
+			RefID: "A",
+			JSON:  []byte(`{"refId":"A","queryType":"comparison","resourceType":"pod","compareTo":"week"}`),
+		})
+		if res.Error != nil {
+			t.Fatalf("query failed: %v", res.Error)
+		}
+
+		frame := res.Frames[0]
+		for i := 0; i < frame.Rows(); i++ {
+			if frame.Fields[0].At(i) != "web-2" {
+				continue
+			}
+			if current, previous := frame.Fields[2].At(i).(float64), frame.Fields[3].At(i).(float64); current == 0 || previous != 0 {
+				t.Errorf("Expected web-2 to emit only in the current period, got %f and %f g", current, previous)
+			}
+		}
+		if frame.Meta == nil || len(frame.Meta.Notices) != 1 || !strings.Contains(frame.Meta.Notices[0].Text, "1 pods were created") {
+			t.Errorf("Expected a notice about the pod created since the previous period, got %v", frame.Meta)
+		}
+	})
+
+	t.Run("MetricsServerUsage", func(t *testing.T) {
+		d := newTestDatasource()
+		d.currentUsage = true
+
+		res := d.query(ctx, backend.PluginContext{}, backend.DataQuery{
+			RefID: "A",
+			JSON:  []byte(`{"refId":"A","queryType":"comparison","resourceType":"namespace","compareTo":"week"}`),
+		})
+		if res.Error != nil {
+			t.Fatalf("query failed: %v", res.Error)
+		}
+		if meta := res.Frames[0].Meta; meta == nil || len(meta.Notices) != 1 || !strings.Contains(meta.Notices[0].Text, "metrics-server") {
+			t.Errorf("Expected a notice about metrics-server usage, got %v", meta)
+		}
+	})
+
+	t.Run("InvalidPeriod", func(t *testing.T) {
+		res := d.query(ctx, backend.PluginContext{}, backend.DataQuery{
+			RefID: "A",
+			JSON:  []byte(`{"refId":"A","queryType":"comparison","resourceType":"namespace","compareTo":"year"}`),
+		})
+		if res.Status != backend.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", backend.StatusBadRequest, res.Status)
+		}
+	})
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
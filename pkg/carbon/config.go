@@ -0,0 +1,322 @@
+package carbon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	// DefaultPUE assumes no datacenter overhead when none is configured
+	DefaultPUE = 1.0
+
+	// DefaultGridIntensityValue is the IEA world average carbon intensity of electricity, in gCO2/kWh
+	DefaultGridIntensityValue = 475.0
+
+	// DefaultCurrency is the ISO 4217 code the energy price is assumed to be in
+	DefaultCurrency = "USD"
+
+	// maxPUE guards against obviously mistyped values, real facilities sit well below it
+	maxPUE = 3.0
+)
+
+// Secure JSON keys
+const (
+	secureKeyKubernetesToken       = "kubernetesToken"
+	secureKeyKubernetesCACert      = "kubernetesCaCert"
+	secureKeyGridIntensityAPIKey   = "gridIntensityApiKey"
+	secureKeyElectricityMapsAPIKey = "electricityMapsApiKey"
+	secureKeyWattTimePassword      = "wattTimePassword"
+	secureKeyPrometheusToken       = "prometheusToken"
+	secureKeyAWSAccessKey          = "awsAccessKey"
+	secureKeyAWSSecretKey          = "awsSecretKey"
+	secureKeyAzureTenantID         = "azureTenantId"
+	secureKeyAzureClientID         = "azureClientId"
+	secureKeyAzureClientSecret     = "azureClientSecret"
+	secureKeyGCPServiceAccountKey  = "gcpServiceAccountKey"
+)
+
+// DatasourceConfig is the parsed configuration of a datasource instance
+type DatasourceConfig struct {
+	KubernetesConfig *KubernetesConfig
+	CloudConfig      *CloudConfig
+	CarbonConfig     *CarbonConfig
+}
+
+// FieldError describes an invalid configuration field
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// ValidationErrors collects every invalid field so they can be reported together
+type ValidationErrors []FieldError
+
+// Error implements the error interface
+func (v ValidationErrors) Error() string {
+	return "invalid datasource configuration: " + v.join()
+}
+
+// join lists every invalid field with its message
+func (v ValidationErrors) join() string {
+	msgs := make([]string, 0, len(v))
+	for _, e := range v {
+		msgs = append(msgs, e.Field+": "+e.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// add records an invalid field
+func (v *ValidationErrors) add(field, format string, args ...interface{}) {
+	*v = append(*v, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// ParseDatasourceConfig parses datasource JSON data and decrypted secure JSON data.
+// Secrets are only ever read from secureData. All invalid fields are returned as ValidationErrors.
+func ParseDatasourceConfig(jsonData json.RawMessage, secureData map[string]string) (*DatasourceConfig, error) {
+	config := &DatasourceConfig{
+		KubernetesConfig: &KubernetesConfig{},
+		CloudConfig:      &CloudConfig{},
+		CarbonConfig:     &CarbonConfig{},
+	}
+
+	// Settings share one flat JSON object, each section picks its own keys
+	if len(jsonData) > 0 {
+		for _, section := range []interface{}{config.KubernetesConfig, config.CloudConfig, config.CarbonConfig} {
+			if err := json.Unmarshal(jsonData, section); err != nil {
+				return nil, fmt.Errorf("failed to parse datasource settings: %w", err)
+			}
+		}
+	}
+
+	config.applySecureData(secureData)
+	config.applyDefaults()
+
+	if errs := config.validate(); len(errs) > 0 {
+		return nil, errs
+	}
+
+	return config, nil
+}
+
+// applySecureData copies secrets from decrypted secure JSON data
+func (c *DatasourceConfig) applySecureData(secureData map[string]string) {
+	c.KubernetesConfig.BearerToken = secureData[secureKeyKubernetesToken]
+	c.KubernetesConfig.CACert = secureData[secureKeyKubernetesCACert]
+
+	c.CloudConfig.AWSAccessKey = secureData[secureKeyAWSAccessKey]
+	c.CloudConfig.AWSSecretKey = secureData[secureKeyAWSSecretKey]
+	c.CloudConfig.AzureTenantID = secureData[secureKeyAzureTenantID]
+	c.CloudConfig.AzureClientID = secureData[secureKeyAzureClientID]
+	c.CloudConfig.AzureClientSecret = secureData[secureKeyAzureClientSecret]
+	c.CloudConfig.GCPServiceAccountKey = secureData[secureKeyGCPServiceAccountKey]
+
+	c.CarbonConfig.GridIntensityAPIKey = secureData[secureKeyGridIntensityAPIKey]
+	c.CarbonConfig.ElectricityMapsAPIKey = secureData[secureKeyElectricityMapsAPIKey]
+	c.CarbonConfig.WattTimePassword = secureData[secureKeyWattTimePassword]
+	c.CarbonConfig.PrometheusToken = secureData[secureKeyPrometheusToken]
+}
+
+// applyDefaults fills in values left unset by the user
+func (c *DatasourceConfig) applyDefaults() {
+	if c.KubernetesConfig.AuthMode == "" {
+		c.KubernetesConfig.AuthMode = KubernetesAuthInCluster
+	}
+
+	// A zero PUE would wipe out every emission figure
+	if c.CarbonConfig.PUE == 0 {
+		c.CarbonConfig.PUE = DefaultPUE
+	}
+	if c.CarbonConfig.DefaultGridIntensity == 0 {
+		c.CarbonConfig.DefaultGridIntensity = DefaultGridIntensityValue
+	}
+	if c.CarbonConfig.IntensityBasis == "" {
+		c.CarbonConfig.IntensityBasis = IntensityBasisAverage
+	}
+	if c.CarbonConfig.Currency == "" {
+		c.CarbonConfig.Currency = DefaultCurrency
+	}
+	if c.CarbonConfig.EnergyModel == "" {
+		c.CarbonConfig.EnergyModel = EnergyModelCCF
+	}
+	if c.CarbonConfig.AllocationMode == "" {
+		c.CarbonConfig.AllocationMode = AllocationRequests
+	}
+	if c.CarbonConfig.UtilizationSource == "" {
+		c.CarbonConfig.UtilizationSource = UtilizationSourceMetricsServer
+	}
+	if c.CarbonConfig.EnergySource == "" {
+		c.CarbonConfig.EnergySource = EnergySourceModel
+	}
+	if c.CarbonConfig.IdleAttribution == "" {
+		c.CarbonConfig.IdleAttribution = IdleAttributionNone
+	}
+	if len(c.CarbonConfig.TeamSources) == 0 {
+		c.CarbonConfig.TeamSources = defaultTeamSources
+	}
+}
+
+// validate checks every section and returns all invalid fields
+func (c *DatasourceConfig) validate() ValidationErrors {
+	var errs ValidationErrors
+
+	k := c.KubernetesConfig
+	switch k.AuthMode {
+	case KubernetesAuthInCluster, KubernetesAuthKubeconfig:
+	case KubernetesAuthToken:
+		if k.APIServerURL == "" {
+			errs.add("apiServerUrl", "is required for token authentication")
+		}
+		if k.BearerToken == "" {
+			errs.add(secureKeyKubernetesToken, "is required for token authentication")
+		}
+	default:
+		errs.add("authMode", "must be one of %q, %q or %q", KubernetesAuthInCluster, KubernetesAuthKubeconfig, KubernetesAuthToken)
+	}
+	if k.ResyncPeriodSeconds < 0 {
+		errs.add("resyncPeriodSeconds", "must not be negative")
+	}
+
+	cloud := c.CloudConfig
+	switch cloud.Provider {
+	case CloudProviderNone:
+	case CloudProviderAWS:
+		if (cloud.AWSAccessKey == "") != (cloud.AWSSecretKey == "") {
+			errs.add(secureKeyAWSSecretKey, "access key and secret key must be set together")
+		}
+	case CloudProviderAzure:
+		if cloud.AzureClientSecret != "" && (cloud.AzureTenantID == "" || cloud.AzureClientID == "") {
+			errs.add(secureKeyAzureClientID, "tenant ID and client ID are required with a client secret")
+		}
+	case CloudProviderGCP:
+		if cloud.GCPServiceAccountKey != "" && !json.Valid([]byte(cloud.GCPServiceAccountKey)) {
+			errs.add(secureKeyGCPServiceAccountKey, "must be a JSON service account key")
+		}
+	default:
+		errs.add("cloudProvider", "must be one of %q, %q or %q", CloudProviderAWS, CloudProviderAzure, CloudProviderGCP)
+	}
+	if cloud.EnableCloudMetrics && cloud.Provider == CloudProviderNone {
+		errs.add("cloudProvider", "is required when cloud metrics are enabled")
+	}
+
+	carbonConfig := c.CarbonConfig
+	if carbonConfig.PUE < 1.0 || carbonConfig.PUE > maxPUE {
+		errs.add("pue", "must be between 1.0 and %.1f, got %g", maxPUE, carbonConfig.PUE)
+	}
+	if carbonConfig.EnergyPrice < 0 {
+		errs.add("energyPrice", "must not be negative, got %g", carbonConfig.EnergyPrice)
+	}
+	if !isCurrencyCode(carbonConfig.Currency) {
+		errs.add("currency", "must be an ISO 4217 code such as %q, got %q", DefaultCurrency, carbonConfig.Currency)
+	}
+	if carbonConfig.DefaultGridIntensity < 0 {
+		errs.add("defaultGridIntensity", "must not be negative, got %g", carbonConfig.DefaultGridIntensity)
+	}
+	for region, zone := range carbonConfig.GridZoneOverrides {
+		if strings.TrimSpace(region) == "" || strings.TrimSpace(zone) == "" {
+			errs.add("gridZoneOverrides", "region and zone must not be empty")
+			break
+		}
+	}
+
+	// Only providers that need a key ask for one, the UK Carbon Intensity API is keyless
+	switch carbonConfig.GridIntensityProvider {
+	case "", GridProviderStatic, GridProviderUKCarbonIntensity:
+	case GridProviderWattTime:
+		if carbonConfig.IntensityBasis != IntensityBasisMarginal {
+			errs.add("intensityBasis", "must be %q for the WattTime provider", IntensityBasisMarginal)
+		}
+	case GridProviderElectricityMaps:
+		if electricityMapsAPIKey(carbonConfig) == "" {
+			errs.add(secureKeyElectricityMapsAPIKey, "is required for the Electricity Maps provider")
+		}
+	case GridProviderFile:
+		if carbonConfig.GridIntensityFile == "" {
+			errs.add("gridIntensityFile", "is required for the file provider")
+		} else if _, err := readIntensityFile(carbonConfig.GridIntensityFile); err != nil {
+			errs.add("gridIntensityFile", "%v", err)
+		}
+	default:
+		errs.add("gridIntensityProvider", "must be one of %q, %q, %q, %q or %q",
+			GridProviderStatic, GridProviderElectricityMaps, GridProviderWattTime, GridProviderUKCarbonIntensity, GridProviderFile)
+	}
+	switch carbonConfig.IntensityBasis {
+	case IntensityBasisAverage:
+	case IntensityBasisMarginal:
+		// Only WattTime publishes marginal intensity
+		if provider := carbonConfig.GridIntensityProvider; provider != "" && provider != GridProviderWattTime {
+			errs.add("gridIntensityProvider", "must be %q for marginal intensity, got %q", GridProviderWattTime, provider)
+		}
+		if carbonConfig.WattTimeUsername == "" {
+			errs.add("wattTimeUsername", "is required for marginal intensity")
+		}
+		if carbonConfig.WattTimePassword == "" {
+			errs.add(secureKeyWattTimePassword, "is required for marginal intensity")
+		}
+	default:
+		errs.add("intensityBasis", "must be %q or %q", IntensityBasisAverage, IntensityBasisMarginal)
+	}
+	switch carbonConfig.EnergyModel {
+	case EnergyModelCCF, EnergyModelSPECpower:
+	default:
+		errs.add("energyModel", "must be %q or %q", EnergyModelCCF, EnergyModelSPECpower)
+	}
+	switch carbonConfig.AllocationMode {
+	case AllocationRequests, AllocationUsage, AllocationMax:
+	default:
+		errs.add("allocationMode", "must be one of %q, %q or %q", AllocationRequests, AllocationUsage, AllocationMax)
+	}
+	switch carbonConfig.IdleAttribution {
+	case IdleAttributionNone, IdleAttributionProportional, IdleAttributionNamespace:
+	default:
+		errs.add("idleAttribution", "must be one of %q, %q or %q", IdleAttributionNone, IdleAttributionProportional, IdleAttributionNamespace)
+	}
+
+	for _, source := range carbonConfig.TeamSources {
+		validObject := source.Object == TeamSourcePod || source.Object == TeamSourceNamespace
+		validField := source.Field == TeamFieldLabel || source.Field == TeamFieldAnnotation
+		if !validObject || !validField || strings.TrimSpace(source.Key) == "" {
+			errs.add("teamSources", "object must be %q or %q, field %q or %q and key must not be empty",
+				TeamSourcePod, TeamSourceNamespace, TeamFieldLabel, TeamFieldAnnotation)
+			break
+		}
+	}
+
+	// The Prometheus utilization source, Kepler and request counts read from prometheusUrl
+	needsPrometheus := carbonConfig.RequestsQuery != ""
+	switch carbonConfig.UtilizationSource {
+	case UtilizationSourceMetricsServer:
+	case UtilizationSourcePrometheus:
+		needsPrometheus = true
+	default:
+		errs.add("utilizationSource", "must be %q or %q", UtilizationSourceMetricsServer, UtilizationSourcePrometheus)
+	}
+	switch carbonConfig.EnergySource {
+	case EnergySourceModel:
+	case EnergySourceKepler:
+		needsPrometheus = true
+	default:
+		errs.add("energySource", "must be %q or %q", EnergySourceModel, EnergySourceKepler)
+	}
+	if needsPrometheus {
+		if u, err := url.Parse(carbonConfig.PrometheusURL); carbonConfig.PrometheusURL == "" || err != nil || u.Host == "" {
+			errs.add("prometheusUrl", "must be an absolute URL for the Prometheus utilization source, Kepler or requestsQuery")
+		}
+	}
+
+	return errs
+}
+
+// isCurrencyCode reports whether code looks like an ISO 4217 code, three upper case letters
+func isCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
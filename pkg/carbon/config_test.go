@@ -0,0 +1,148 @@
+package carbon
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseDatasourceConfig(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		config, err := ParseDatasourceConfig([]byte(`{}`), nil)
+		if err != nil {
+			t.Fatalf("ParseDatasourceConfig failed: %v", err)
+		}
+
+		if config.CarbonConfig.PUE != DefaultPUE {
+			t.Errorf("Expected default PUE %f, got %f", DefaultPUE, config.CarbonConfig.PUE)
+		}
+		if config.CarbonConfig.DefaultGridIntensity != DefaultGridIntensityValue {
+			t.Errorf("Expected default grid intensity %f, got %f", DefaultGridIntensityValue, config.CarbonConfig.DefaultGridIntensity)
+		}
+		if config.CarbonConfig.EnergyModel != EnergyModelCCF {
+			t.Errorf("Expected energy model %s, got %s", EnergyModelCCF, config.CarbonConfig.EnergyModel)
+		}
+		if config.CarbonConfig.Currency != DefaultCurrency {
+			t.Errorf("Expected currency %s, got %s", DefaultCurrency, config.CarbonConfig.Currency)
+		}
+		if config.KubernetesConfig.AuthMode != KubernetesAuthInCluster {
+			t.Errorf("Expected auth mode %s, got %s", KubernetesAuthInCluster, config.KubernetesConfig.AuthMode)
+		}
+	})
+
+	t.Run("FlatSettings", func(t *testing.T) {
+		jsonData := []byte(`{
+			"authMode": "token",
+			"apiServerUrl": "https://kubernetes.example.com",
+			"cloudProvider": "aws",
+			"region": "us-east-1",
+			"pue": 1.2,
+			"defaultGridIntensity": 380
+		}`)
+
+		config, err := ParseDatasourceConfig(jsonData, map[string]string{"kubernetesToken": "secret"})
+		if err != nil {
+			t.Fatalf("ParseDatasourceConfig failed: %v", err)
+		}
+
+		if config.KubernetesConfig.BearerToken != "secret" {
+			t.Errorf("Expected bearer token from secure data")
+		}
+		if config.CloudConfig.Region != "us-east-1" {
+			t.Errorf("Expected region 'us-east-1', got %s", config.CloudConfig.Region)
+		}
+		if config.CarbonConfig.PUE != 1.2 {
+			t.Errorf("Expected PUE 1.2, got %f", config.CarbonConfig.PUE)
+		}
+		if config.CarbonConfig.DefaultGridIntensity != 380 {
+			t.Errorf("Expected grid intensity 380, got %f", config.CarbonConfig.DefaultGridIntensity)
+		}
+	})
+
+	t.Run("SecretsOnlyFromSecureData", func(t *testing.T) {
+		jsonData := []byte(`{"electricityMapsApiKey": "plaintext", "gridIntensityApiKey": "plaintext"}`)
+
+		config, err := ParseDatasourceConfig(jsonData, map[string]string{"electricityMapsApiKey": "encrypted"})
+		if err != nil {
+			t.Fatalf("ParseDatasourceConfig failed: %v", err)
+		}
+
+		if config.CarbonConfig.ElectricityMapsAPIKey != "encrypted" {
+			t.Errorf("Expected Electricity Maps key from secure data, got %q", config.CarbonConfig.ElectricityMapsAPIKey)
+		}
+		if config.CarbonConfig.GridIntensityAPIKey != "" {
+			t.Errorf("Expected grid intensity key to be ignored in plain JSON, got %q", config.CarbonConfig.GridIntensityAPIKey)
+		}
+	})
+
+	t.Run("AggregatedErrors", func(t *testing.T) {
+		jsonData := []byte(`{"authMode": "token", "cloudProvider": "oracle", "pue": 0.5, "energyPrice": -1, "currency": "euro", "energyModel": "guess", "utilizationSource": "prometheus", "idleAttribution": "tenants", "teamSources": [{"object": "deployment", "field": "label", "key": "team"}]}`)
+
+		_, err := ParseDatasourceConfig(jsonData, nil)
+		if err == nil {
+			t.Fatal("Expected validation error, got nil")
+		}
+
+		var errs ValidationErrors
+		if !errors.As(err, &errs) {
+			t.Fatalf("Expected ValidationErrors, got %T", err)
+		}
+
+		fields := make(map[string]bool)
+		for _, e := range errs {
+			fields[e.Field] = true
+		}
+		for _, field := range []string{"apiServerUrl", "kubernetesToken", "cloudProvider", "pue", "energyPrice", "currency", "energyModel", "prometheusUrl", "idleAttribution", "teamSources"} {
+			if !fields[field] {
+				t.Errorf("Expected error for field %s, got %v", field, errs)
+			}
+		}
+	})
+
+	t.Run("KeplerNeedsPrometheus", func(t *testing.T) {
+		jsonData := []byte(`{"authMode": "in-cluster", "cloudProvider": "aws", "energySource": "kepler"}`)
+
+		var errs ValidationErrors
+		_, err := ParseDatasourceConfig(jsonData, nil)
+		if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Field != "prometheusUrl" {
+			t.Fatalf("Expected a prometheusUrl error, got %v", err)
+		}
+
+		jsonData = []byte(`{"authMode": "in-cluster", "cloudProvider": "aws", "energySource": "kepler", "prometheusUrl": "http://prometheus:9090"}`)
+		config, err := ParseDatasourceConfig(jsonData, nil)
+		if err != nil {
+			t.Fatalf("ParseDatasourceConfig failed: %v", err)
+		}
+		if config.CarbonConfig.UtilizationSource != UtilizationSourceMetricsServer {
+			t.Errorf("Expected Kepler not to change the utilization source, got %s", config.CarbonConfig.UtilizationSource)
+		}
+	})
+
+	t.Run("MarginalNeedsWattTime", func(t *testing.T) {
+		secureData := map[string]string{secureKeyWattTimePassword: "secret"}
+		jsonData := []byte(`{"authMode": "in-cluster", "cloudProvider": "aws", "intensityBasis": "marginal", "wattTimeUsername": "user", "gridIntensityProvider": "ukcarbonintensity"}`)
+
+		var errs ValidationErrors
+		_, err := ParseDatasourceConfig(jsonData, secureData)
+		if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Field != "gridIntensityProvider" {
+			t.Fatalf("Expected a gridIntensityProvider error, got %v", err)
+		}
+
+		jsonData = []byte(`{"authMode": "in-cluster", "cloudProvider": "aws", "intensityBasis": "marginal", "wattTimeUsername": "user", "gridIntensityProvider": "watttime"}`)
+		if _, err := ParseDatasourceConfig(jsonData, secureData); err != nil {
+			t.Errorf("Expected WattTime to serve marginal intensity, got %v", err)
+		}
+
+		jsonData = []byte(`{"authMode": "in-cluster", "cloudProvider": "aws", "gridIntensityProvider": "watttime"}`)
+		_, err = ParseDatasourceConfig(jsonData, secureData)
+		if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Field != "intensityBasis" {
+			t.Errorf("Expected an intensityBasis error for WattTime with average intensity, got %v", err)
+		}
+	})
+
+	t.Run("InvalidJSON", func(t *testing.T) {
+		_, err := ParseDatasourceConfig([]byte(`{invalid json}`), nil)
+		if err == nil {
+			t.Error("Expected error for invalid JSON, got nil")
+		}
+	})
+}
@@ -0,0 +1,687 @@
+package carbon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/datasource"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Make sure CarbonFootprintDatasource implements the required interfaces
+var (
+	_ backend.QueryDataHandler      = (*CarbonFootprintDatasource)(nil)
+	_ backend.CheckHealthHandler    = (*CarbonFootprintDatasource)(nil)
+	_ backend.CallResourceHandler   = (*CarbonFootprintDatasource)(nil)
+	_ instancemgmt.InstanceDisposer = (*CarbonFootprintDatasource)(nil)
+)
+
+// CarbonFootprintDatasource implements the datasource interface
+type CarbonFootprintDatasource struct {
+	CarbonCalculator
+
+	// Cloud provider clients
+	kubernetesClient KubernetesClient
+	cloudClient      CloudClient
+
+	// teamSources derive the owning team of pods, the default sources when empty
+	teamSources []TeamSource
+
+	// energyPrice charges the cost metric per kWh, no cost when zero
+	energyPrice float64
+	// costUnit is the Grafana unit of the configured currency
+	costUnit string
+
+	// requests counts requests for carbon per request with the configured requestsQuery, nil without
+	// Prometheus. Queries can't bring their own PromQL, which would run with the datasource's credentials.
+	requests      RequestCounter
+	requestsQuery string
+
+	// currentUsage is set when measured usage drives energy but only reflects the present, as
+	// metrics-server does; comparisons then see today's usage in the earlier period too
+	currentUsage bool
+}
+
+// NewDatasourceFactory returns the factory used by the instance manager.
+// Grafana gets one instance per configured datasource; when its settings change the
+// instance is rebuilt and the previous one is disposed.
+func NewDatasourceFactory() datasource.InstanceFactoryFunc {
+	return NewCarbonFootprintDatasource
+}
+
+// NewCarbonFootprintDatasource creates a new instance of the datasource
+func NewCarbonFootprintDatasource(ctx context.Context, settings backend.DataSourceInstanceSettings) (instancemgmt.Instance, error) {
+	log.DefaultLogger.Info("Creating new carbon footprint datasource instance", "uid", settings.UID)
+
+	// Parse datasource configuration
+	config, err := ParseDatasourceConfig(settings.JSONData, settings.DecryptedSecureJSONData)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize Kubernetes client
+	kubernetesClient, err := NewKubernetesClient(config.KubernetesConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize cloud provider client
+	cloudClient, err := NewCloudClient(config.CloudConfig)
+	if err != nil {
+		kubernetesClient.Close()
+		return nil, err
+	}
+
+	// Measured usage is only queried by the "usage" and "max" allocation modes
+	utilization, err := NewUtilizationSource(config.CarbonConfig, kubernetesClient)
+	if err != nil {
+		kubernetesClient.Close()
+		cloudClient.Close()
+		return nil, err
+	}
+	opts := []CalculatorOption{WithUtilizationSource(utilization)}
+
+	// Kepler measurements replace modelled energy wherever they exist
+	if config.CarbonConfig.EnergySource == EnergySourceKepler {
+		kepler, err := NewKeplerEnergySource(config.CarbonConfig.PrometheusURL, config.CarbonConfig.PrometheusToken)
+		if err != nil {
+			kubernetesClient.Close()
+			cloudClient.Close()
+			return nil, err
+		}
+		opts = append(opts, WithEnergySource(kepler))
+	}
+	calculator := NewCarbonCalculator(config.CarbonConfig, opts...)
+
+	ds := newCarbonFootprintDatasource(calculator, kubernetesClient, cloudClient)
+	ds.teamSources = config.CarbonConfig.TeamSources
+	ds.energyPrice = config.CarbonConfig.EnergyPrice
+	ds.costUnit = currencyUnit(config.CarbonConfig.Currency)
+	ds.requestsQuery = config.CarbonConfig.RequestsQuery
+	ds.currentUsage = config.CarbonConfig.UtilizationSource == UtilizationSourceMetricsServer &&
+		config.CarbonConfig.AllocationMode != AllocationRequests
+
+	// Carbon per request counts requests with requestsQuery on the same Prometheus
+	if config.CarbonConfig.PrometheusURL != "" {
+		requests, err := NewPrometheusRequestCounter(config.CarbonConfig.PrometheusURL, config.CarbonConfig.PrometheusToken)
+		if err != nil {
+			kubernetesClient.Close()
+			cloudClient.Close()
+			return nil, err
+		}
+		ds.requests = requests
+	}
+	return ds, nil
+}
+
+// newCarbonFootprintDatasource wires a datasource from already constructed dependencies
+func newCarbonFootprintDatasource(calculator CarbonCalculator, kubernetesClient KubernetesClient, cloudClient CloudClient) *CarbonFootprintDatasource {
+	if cloudClient == nil {
+		cloudClient = noopCloudClient{}
+	}
+	return &CarbonFootprintDatasource{
+		CarbonCalculator: calculator,
+		kubernetesClient: kubernetesClient,
+		cloudClient:      cloudClient,
+		costUnit:         currencyUnit(DefaultCurrency),
+	}
+}
+
+// QueryData handles data queries
+func (d *CarbonFootprintDatasource) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	log.DefaultLogger.Debug("QueryData called", "queries", len(req.Queries))
+
+	response := backend.NewQueryDataResponse()
+
+	for _, q := range req.Queries {
+		res := d.query(ctx, req.PluginContext, q)
+		response.Responses[q.RefID] = res
+	}
+
+	return response, nil
+}
+
+// query processes individual queries
+func (d *CarbonFootprintDatasource) query(ctx context.Context, pCtx backend.PluginContext, query backend.DataQuery) backend.DataResponse {
+	var response backend.DataResponse
+
+	// Parse the query
+	carbonQuery, err := ParseQuery(query.JSON)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	window, err := queryWindow(query, carbonQuery, time.Now())
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	filter, err := parseFilters(carbonQuery.Filters)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	// Fallbacks taken while calculating are reported as notices on the frames
+	ctx, notices := withQueryNotices(ctx)
+
+	var previousWindow TimeWindow
+	if carbonQuery.QueryType == "comparison" {
+		previousWindow, err = comparisonWindow(window, carbonQuery.CompareTo)
+		if err != nil {
+			return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+		}
+	}
+
+	metrics, err := d.collect(ctx, carbonQuery, filter, window)
+	if errors.Is(err, errUnknownResourceType) {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		response.Error = err
+		return response
+	}
+
+	// Comparisons calculate the same resources a second time over the earlier period
+	if carbonQuery.QueryType == "comparison" {
+		carbonQuery.previous, err = d.collect(ctx, carbonQuery, filter, previousWindow)
+		if err != nil {
+			response.Error = err
+			return response
+		}
+		if err := d.flagComparison(ctx, carbonQuery, filter, previousWindow); err != nil {
+			response.Error = err
+			return response
+		}
+	}
+
+	if carbonQuery.QueryType == "single-value" && slices.Contains(carbonQuery.Metrics, ValueCarbonPerRequest) {
+		if err := d.countRequests(ctx, carbonQuery, window); err != nil {
+			response.Error = err
+			return response
+		}
+	}
+
+	carbonQuery.costUnit = d.costUnit
+	response.Frames, response.Error = ConvertToDataFrames(metrics, carbonQuery)
+	notices.attach(response.Frames)
+	return response
+}
+
+// errUnknownResourceType rejects queries for resource types the datasource cannot collect
+var errUnknownResourceType = errors.New("unknown resource type")
+
+// collect calculates the metrics of the resources query selects over window, filtered and grouped
+func (d *CarbonFootprintDatasource) collect(ctx context.Context, query *Query, filter *resourceFilter, window TimeWindow) ([]*Metrics, error) {
+	// Collect metrics based on query type
+	var metrics []*Metrics
+	var err error
+	groupBy := query.GroupBy
+	switch query.ResourceType {
+	case "cluster":
+		metrics, err = d.collectClusterMetrics(ctx, filter, window)
+	case "namespace":
+		metrics, err = d.collectNamespaceMetrics(ctx, filter, window)
+	case "node":
+		metrics, err = d.collectNodeMetrics(ctx, filter, window)
+	case "pod":
+		metrics, err = d.collectPodMetrics(ctx, filter, window)
+	case "deployment", "statefulset", "daemonset", "job", "cronjob", "workload":
+		metrics, err = d.collectWorkloadMetrics(ctx, query, filter, window)
+	case "team":
+		// Teams are pods grouped by team, further group-by keys split them
+		metrics, err = d.collectPodMetrics(ctx, filter, window)
+		if !slices.Contains(groupBy, GroupByTeam) {
+			groupBy = append([]string{GroupByTeam}, groupBy...)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnknownResourceType, query.ResourceType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if d.energyPrice > 0 {
+		for _, m := range metrics {
+			m.Cost = m.EnergyConsumption * d.energyPrice
+		}
+	}
+
+	if len(groupBy) > 0 {
+		nodes, err := d.kubernetesClient.GetNodes(ctx)
+		if err != nil {
+			return nil, err
+		}
+		metrics = newMetricGrouper(groupBy, nodes).group(metrics)
+	}
+	return filter.dropBelowThreshold(metrics), nil
+}
+
+// CheckHealth handles health checks
+func (d *CarbonFootprintDatasource) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	log.DefaultLogger.Debug("CheckHealth called")
+
+	// Test Kubernetes connection
+	if err := d.kubernetesClient.TestConnection(ctx); err != nil {
+		return &backend.CheckHealthResult{
+			Status:  backend.HealthStatusError,
+			Message: "Failed to connect to Kubernetes: " + err.Error(),
+		}, nil
+	}
+
+	// Test cloud provider connection
+	if err := d.cloudClient.TestConnection(ctx); err != nil {
+		return &backend.CheckHealthResult{
+			Status:  backend.HealthStatusError,
+			Message: "Failed to connect to cloud provider: " + err.Error(),
+		}, nil
+	}
+
+	return &backend.CheckHealthResult{
+		Status:  backend.HealthStatusOk,
+		Message: "Data source is working",
+	}, nil
+}
+
+// CallResource serves lookups used by the query editor
+func (d *CarbonFootprintDatasource) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	if req.Method != http.MethodGet {
+		return sendResourceError(sender, http.StatusMethodNotAllowed, "method not allowed")
+	}
+
+	switch req.Path {
+	case "namespaces":
+		namespaces, err := d.kubernetesClient.GetNamespaces(ctx)
+		if err != nil {
+			return sendResourceError(sender, http.StatusBadGateway, err.Error())
+		}
+		names := make([]string, 0, len(namespaces))
+		for _, ns := range namespaces {
+			names = append(names, ns.Name)
+		}
+		sort.Strings(names)
+		return sendResourceJSON(sender, names)
+
+	case "nodes":
+		nodes, err := d.kubernetesClient.GetNodes(ctx)
+		if err != nil {
+			return sendResourceError(sender, http.StatusBadGateway, err.Error())
+		}
+		names := make([]string, 0, len(nodes))
+		for _, node := range nodes {
+			names = append(names, node.Name)
+		}
+		sort.Strings(names)
+		return sendResourceJSON(sender, names)
+
+	default:
+		return sendResourceError(sender, http.StatusNotFound, "unknown resource: "+req.Path)
+	}
+}
+
+// sendResourceJSON sends a JSON encoded resource response
+func sendResourceJSON(sender backend.CallResourceResponseSender, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return sendResourceError(sender, http.StatusInternalServerError, err.Error())
+	}
+	return sender.Send(&backend.CallResourceResponse{
+		Status:  http.StatusOK,
+		Headers: map[string][]string{"Content-Type": {"application/json"}},
+		Body:    payload,
+	})
+}
+
+// sendResourceError sends a JSON encoded error message
+func sendResourceError(sender backend.CallResourceResponseSender, status int, message string) error {
+	payload, _ := json.Marshal(map[string]string{"message": message})
+	return sender.Send(&backend.CallResourceResponse{
+		Status:  status,
+		Headers: map[string][]string{"Content-Type": {"application/json"}},
+		Body:    payload,
+	})
+}
+
+// collectClusterMetrics collects cluster-level carbon metrics
+func (d *CarbonFootprintDatasource) collectClusterMetrics(ctx context.Context, filter *resourceFilter, window TimeWindow) ([]*Metrics, error) {
+	// Get cluster resources
+	nodes, err := d.kubernetesClient.GetNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := d.kubernetesClient.GetPods(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// Calculate carbon footprint
+	nodes, pods = filter.scope(nodes, pods)
+	if !filter.filtersLabels() {
+		return d.CarbonCalculator.CalculateClusterCarbon(ctx, nodes, pods, window)
+	}
+
+	// With a label selector the cluster sums the selected pods, including their share of idle energy
+	attributions := d.CarbonCalculator.AttributeNodes(ctx, nodes, pods, window)
+	nodesByName := indexNodes(nodes)
+	var podMetrics []*Metrics
+	for _, pod := range filter.selectPods(pods) {
+		metrics, err := d.CarbonCalculator.CalculatePodCarbon(ctx, pod, nodesByName[pod.Spec.NodeName], attributions, window)
+		if err != nil {
+			continue
+		}
+		podMetrics = append(podMetrics, metrics...)
+	}
+
+	cluster := newMetricGrouper(nil, nodes).group(podMetrics)
+	for _, m := range cluster {
+		m.ResourceType, m.ResourceName = "cluster", "cluster"
+		m.Namespace, m.NodeName, m.Team, m.Labels = "", "", "", nil
+	}
+	return cluster, nil
+}
+
+// collectNamespaceMetrics collects namespace-level carbon metrics
+func (d *CarbonFootprintDatasource) collectNamespaceMetrics(ctx context.Context, filter *resourceFilter, window TimeWindow) ([]*Metrics, error) {
+	namespaces, err := d.kubernetesClient.GetNamespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Nodes tell which region, and so which grid, each pod draws power from
+	nodes, err := d.kubernetesClient.GetNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Idle energy of a node is attributed across the pods of every namespace
+	pods, err := d.kubernetesClient.GetPods(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, pods = filter.scope(nodes, pods)
+	attributions := d.CarbonCalculator.AttributeNodes(ctx, nodes, pods, window)
+	teams := newTeamResolver(d.teamSources, namespaces)
+
+	// Namespaces sum the pods passing the label selector, those without any are left out
+	selected := filter.selectPods(pods)
+	withSelected := make(map[string]bool)
+	for _, pod := range selected {
+		withSelected[pod.Namespace] = true
+	}
+
+	// The calculator returns nothing for the idle namespace unless idle energy is charged to it.
+	// Idle energy has no labels and never passes a label selector.
+	if !filter.filtersLabels() {
+		namespaces = append(namespaces, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: IdleNamespace}})
+	}
+
+	var allMetrics []*Metrics
+	for _, ns := range namespaces {
+		if !filter.matchesNamespace(ns.Name) {
+			continue
+		}
+		if filter.filtersLabels() && !withSelected[ns.Name] {
+			continue
+		}
+
+		metrics, err := d.CarbonCalculator.CalculateNamespaceCarbon(ctx, ns, nodes, selected, attributions, window)
+		if err != nil {
+			continue
+		}
+
+		team := teams.namespaceTeam(ns.Name)
+		if ns.Name == IdleNamespace {
+			team = IdleNamespace
+		}
+		setTeam(metrics, team)
+		allMetrics = append(allMetrics, metrics...)
+	}
+
+	return allMetrics, nil
+}
+
+// collectNodeMetrics collects node-level carbon metrics
+func (d *CarbonFootprintDatasource) collectNodeMetrics(ctx context.Context, filter *resourceFilter, window TimeWindow) ([]*Metrics, error) {
+	nodes, err := d.kubernetesClient.GetNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var allMetrics []*Metrics
+	for _, node := range nodes {
+		if !filter.matchesNode(node) || !filter.matchesLabels(node.Labels) {
+			continue
+		}
+
+		pods, err := d.kubernetesClient.GetPodsOnNode(ctx, node.Name)
+		if err != nil {
+			continue
+		}
+
+		metrics, err := d.CarbonCalculator.CalculateNodeCarbon(ctx, node, pods, window)
+		if err != nil {
+			continue
+		}
+
+		allMetrics = append(allMetrics, metrics...)
+	}
+
+	return allMetrics, nil
+}
+
+// collectPodMetrics collects pod-level carbon metrics
+func (d *CarbonFootprintDatasource) collectPodMetrics(ctx context.Context, filter *resourceFilter, window TimeWindow) ([]*Metrics, error) {
+	// All pods are needed to attribute idle energy of the nodes, not just the filtered ones
+	pods, err := d.kubernetesClient.GetPods(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := d.kubernetesClient.GetNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nodes, pods = filter.scope(nodes, pods)
+	nodesByName := indexNodes(nodes)
+	attributions := d.CarbonCalculator.AttributeNodes(ctx, nodes, pods, window)
+
+	teams, err := d.teamResolver(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var allMetrics []*Metrics
+	for _, pod := range pods {
+		if !filter.matchesNamespace(pod.Namespace) || !filter.matchesLabels(pod.Labels) {
+			continue
+		}
+
+		metrics, err := d.CarbonCalculator.CalculatePodCarbon(ctx, pod, nodesByName[pod.Spec.NodeName], attributions, window)
+		if err != nil {
+			continue
+		}
+
+		setTeam(metrics, teams.podTeam(pod))
+		allMetrics = append(allMetrics, metrics...)
+	}
+
+	// Idle energy charged to the idle namespace shows up as one entry per node. It has no labels
+	// and never passes a label selector.
+	if filter.matchesNamespace(IdleNamespace) && !filter.filtersLabels() {
+		for _, node := range nodes {
+			metrics, err := d.CarbonCalculator.CalculateIdleCarbon(ctx, node, attributions, window)
+			if err != nil {
+				continue
+			}
+
+			setTeam(metrics, IdleNamespace)
+			allMetrics = append(allMetrics, metrics...)
+		}
+	}
+
+	return allMetrics, nil
+}
+
+// collectWorkloadMetrics collects carbon metrics rolled up by the workloads owning the pods
+func (d *CarbonFootprintDatasource) collectWorkloadMetrics(ctx context.Context, query *Query, filter *resourceFilter, window TimeWindow) ([]*Metrics, error) {
+	// All pods are needed to attribute idle energy of the nodes, not just the filtered ones
+	pods, err := d.kubernetesClient.GetPods(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := d.kubernetesClient.GetNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, pods = filter.scope(nodes, pods)
+	attributions := d.CarbonCalculator.AttributeNodes(ctx, nodes, pods, window)
+
+	// Owners of every namespace are listed unless an exact namespace is selected. Without access
+	// to them, Deployments are told by the pod-template-hash label and Jobs are not linked to CronJobs.
+	replicaSets, err := d.kubernetesClient.GetReplicaSets(ctx, filter.namespace)
+	if apierrors.IsForbidden(err) {
+		addQueryNotice(ctx, data.NoticeSeverityWarning, "ReplicaSets can't be listed, Deployments are recognised by the pod-template-hash label of their pods")
+	} else if err != nil {
+		return nil, err
+	}
+
+	jobs, err := d.kubernetesClient.GetJobs(ctx, filter.namespace)
+	if apierrors.IsForbidden(err) {
+		addQueryNotice(ctx, data.NoticeSeverityWarning, "Jobs can't be listed, pods of CronJobs are reported under their Job")
+	} else if err != nil {
+		return nil, err
+	}
+
+	teams, err := d.teamResolver(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]*corev1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if filter.matchesNamespace(pod.Namespace) && filter.matchesLabels(pod.Labels) {
+			members = append(members, pod)
+		}
+	}
+
+	// "workload" keeps every kind, the other resource types select one
+	kind, selectsKind := workloadResourceTypes[query.ResourceType]
+
+	var allMetrics []*Metrics
+	for _, workload := range newOwnerResolver(replicaSets, jobs).groupPodsByWorkload(members) {
+		if selectsKind && workload.Kind != kind {
+			continue
+		}
+
+		metrics, err := d.CarbonCalculator.CalculateWorkloadCarbon(ctx, workload, nodes, attributions, window)
+		if err != nil {
+			continue
+		}
+
+		// Pods of a workload share its template, the first one speaks for all
+		setTeam(metrics, teams.podTeam(workload.Pods[0]))
+		allMetrics = append(allMetrics, metrics...)
+	}
+
+	return allMetrics, nil
+}
+
+// flagComparison adds notices where the previous period of a comparison can't be reconstructed.
+// It is calculated from the pods that exist now, so pods deleted since are missing from it, and
+// with metrics-server the usage of today stands in for the usage back then.
+func (d *CarbonFootprintDatasource) flagComparison(ctx context.Context, query *Query, filter *resourceFilter, previous TimeWindow) error {
+	if d.currentUsage {
+		addQueryNotice(ctx, data.NoticeSeverityWarning,
+			"metrics-server only reports current usage, the previous period is calculated with today's usage; use the Prometheus utilization source to compare periods")
+	}
+
+	nodes, err := d.kubernetesClient.GetNodes(ctx)
+	if err != nil {
+		return err
+	}
+	pods, err := d.kubernetesClient.GetPods(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	// New pods suggest churn, the pods they replaced are gone and can't be counted
+	_, pods = filter.scope(nodes, pods)
+	namespaced := query.ResourceType != "cluster" && query.ResourceType != "node"
+	created := 0
+	for _, pod := range filter.selectPods(pods) {
+		if namespaced && !filter.matchesNamespace(pod.Namespace) {
+			continue
+		}
+		if pod.CreationTimestamp.Time.After(previous.From) {
+			created++
+		}
+	}
+	if created > 0 {
+		addQueryNotice(ctx, data.NoticeSeverityWarning,
+			"The previous period is reconstructed from the pods that exist now: %d pods were created since %s, pods deleted since then are missing from it",
+			created, previous.From.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// countRequests counts the requests of each step of window for carbon per request
+func (d *CarbonFootprintDatasource) countRequests(ctx context.Context, query *Query, window TimeWindow) error {
+	if d.requestsQuery == "" || d.requests == nil {
+		return fmt.Errorf("carbon per request needs a requests query and a Prometheus URL")
+	}
+
+	counts, err := d.requests.CountRequests(ctx, d.requestsQuery, window)
+	if err != nil {
+		return err
+	}
+
+	query.requests = make(map[int64]float64, len(counts))
+	for i, at := range window.Steps() {
+		if i < len(counts) {
+			query.requests[at.Unix()] = counts[i]
+		}
+	}
+	return nil
+}
+
+// teamResolver creates a resolver for the configured team sources
+func (d *CarbonFootprintDatasource) teamResolver(ctx context.Context) (*teamResolver, error) {
+	namespaces, err := d.kubernetesClient.GetNamespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newTeamResolver(d.teamSources, namespaces), nil
+}
+
+// setTeam assigns team to metrics
+func setTeam(metrics []*Metrics, team string) {
+	for _, m := range metrics {
+		m.Team = team
+	}
+}
+
+// Dispose releases the clients when the instance is replaced or removed
+func (d *CarbonFootprintDatasource) Dispose() {
+	log.DefaultLogger.Info("Disposing carbon footprint datasource")
+	if err := d.kubernetesClient.Close(); err != nil {
+		log.DefaultLogger.Warn("Failed to close Kubernetes client", "error", err)
+	}
+	if err := d.cloudClient.Close(); err != nil {
+		log.DefaultLogger.Warn("Failed to close cloud client", "error", err)
+	}
+}
@@ -0,0 +1,270 @@
+package carbon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDatasourceQuery(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDatasource()
+
+	for _, resourceType := range []string{"cluster", "namespace", "node", "pod"} {
+		t.Run(resourceType, func(t *testing.T) {
+			res := d.query(ctx, backend.PluginContext{}, backend.DataQuery{
+				RefID: "A",
+				JSON:  []byte(`{"refId":"A","queryType":"table","resourceType":"` + resourceType + `"}`),
+			})
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+			if len(res.Frames) != 1 {
+				t.Fatalf("Expected 1 frame, got %d", len(res.Frames))
+			}
+			if res.Frames[0].Rows() != 1 {
+				t.Errorf("Expected 1 row, got %d", res.Frames[0].Rows())
+			}
+		})
+	}
+
+	t.Run("UnknownResourceType", func(t *testing.T) {
+		res := d.query(ctx, backend.PluginContext{}, backend.DataQuery{
+			RefID: "A",
+			JSON:  []byte(`{"refId":"A","resourceType":"galaxy"}`),
+		})
+		if res.Error == nil {
+			t.Error("Expected error for unknown resource type, got nil")
+		}
+	})
+
+	t.Run("QueryData", func(t *testing.T) {
+		resp, err := d.QueryData(ctx, &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{
+				{RefID: "A", JSON: []byte(`{"refId":"A","resourceType":"cluster"}`)},
+				{RefID: "B", JSON: []byte(`{"refId":"B","resourceType":"pod"}`)},
+			},
+		})
+		if err != nil {
+			t.Fatalf("QueryData failed: %v", err)
+		}
+		if len(resp.Responses) != 2 {
+			t.Errorf("Expected 2 responses, got %d", len(resp.Responses))
+		}
+	})
+}
+
+func TestQueryWindow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	from := now.Add(-6 * time.Hour)
+
+	t.Run("DashboardRange", func(t *testing.T) {
+		dq := backend.DataQuery{TimeRange: backend.TimeRange{From: from, To: now}, Interval: time.Hour}
+		window, err := queryWindow(dq, &Query{}, now)
+		if err != nil {
+			t.Fatalf("queryWindow failed: %v", err)
+		}
+		if !window.From.Equal(from) || !window.To.Equal(now) || window.Step != time.Hour {
+			t.Errorf("Unexpected window %+v", window)
+		}
+	})
+
+	t.Run("MaxDataPoints", func(t *testing.T) {
+		dq := backend.DataQuery{TimeRange: backend.TimeRange{From: from, To: now}, Interval: time.Minute, MaxDataPoints: 12}
+		window, err := queryWindow(dq, &Query{}, now)
+		if err != nil {
+			t.Fatalf("queryWindow failed: %v", err)
+		}
+		if len(window.Steps()) != 12 {
+			t.Errorf("Expected 12 steps, got %d", len(window.Steps()))
+		}
+	})
+
+	t.Run("QueryTimeRange", func(t *testing.T) {
+		query := &Query{}
+		query.TimeRange.From = "2024-01-01T00:00:00Z"
+		query.TimeRange.To = strconv.FormatInt(now.UnixMilli(), 10)
+		window, err := queryWindow(backend.DataQuery{TimeRange: backend.TimeRange{From: from, To: now}}, query, now)
+		if err != nil {
+			t.Fatalf("queryWindow failed: %v", err)
+		}
+		if window.Duration() != 12*time.Hour {
+			t.Errorf("Expected the query's 12h range, got %s", window.Duration())
+		}
+
+		query.TimeRange.From = strconv.FormatInt(now.Add(time.Hour).UnixMilli(), 10)
+		if _, err := queryWindow(backend.DataQuery{}, query, now); err == nil {
+			t.Error("Expected error for a time range ending before it starts, got nil")
+		}
+
+		query.TimeRange.To = ""
+		if _, err := queryWindow(backend.DataQuery{TimeRange: backend.TimeRange{From: from, To: now}}, query, now); err == nil {
+			t.Error("Expected error for a query start after the dashboard end, got nil")
+		}
+
+		query.TimeRange.From = "yesterday"
+		if _, err := queryWindow(backend.DataQuery{}, query, now); err == nil {
+			t.Error("Expected error for invalid time range, got nil")
+		}
+	})
+
+	t.Run("NoRange", func(t *testing.T) {
+		window, err := queryWindow(backend.DataQuery{}, &Query{}, now)
+		if err != nil {
+			t.Fatalf("queryWindow failed: %v", err)
+		}
+		if window != defaultWindow(now) {
+			t.Errorf("Expected the default window, got %+v", window)
+		}
+	})
+}
+
+func TestDatasourceQueryTimeSeries(t *testing.T) {
+	now := time.Now()
+	res := newTestDatasource().query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		JSON:      []byte(`{"refId":"A","queryType":"timeseries","resourceType":"cluster"}`),
+		TimeRange: backend.TimeRange{From: now.Add(-2 * time.Hour), To: now},
+		Interval:  30 * time.Minute,
+	})
+	if res.Error != nil {
+		t.Fatalf("query failed: %v", res.Error)
+	}
+	if rows := res.Frames[0].Rows(); rows != 4 {
+		t.Errorf("Expected a point per 30m step, got %d", rows)
+	}
+}
+
+func TestDatasourceCheckHealth(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Healthy", func(t *testing.T) {
+		res, err := newTestDatasource().CheckHealth(ctx, &backend.CheckHealthRequest{})
+		if err != nil {
+			t.Fatalf("CheckHealth failed: %v", err)
+		}
+		if res.Status != backend.HealthStatusOk {
+			t.Errorf("Expected healthy status, got %v: %s", res.Status, res.Message)
+		}
+	})
+
+	t.Run("KubernetesUnreachable", func(t *testing.T) {
+		d := newTestDatasource()
+		d.kubernetesClient.(*FakeKubernetesClient).ConnectionError = errors.New("connection refused")
+
+		res, err := d.CheckHealth(ctx, &backend.CheckHealthRequest{})
+		if err != nil {
+			t.Fatalf("CheckHealth failed: %v", err)
+		}
+		if res.Status != backend.HealthStatusError {
+			t.Errorf("Expected error status, got %v", res.Status)
+		}
+	})
+}
+
+func TestDatasourceCallResource(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDatasource()
+
+	call := func(path string) *backend.CallResourceResponse {
+		var resp *backend.CallResourceResponse
+		err := d.CallResource(ctx, &backend.CallResourceRequest{Method: http.MethodGet, Path: path},
+			backend.CallResourceResponseSenderFunc(func(r *backend.CallResourceResponse) error {
+				resp = r
+				return nil
+			}))
+		if err != nil {
+			t.Fatalf("CallResource failed: %v", err)
+		}
+		return resp
+	}
+
+	resp := call("namespaces")
+	if resp.Status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.Status)
+	}
+	var names []string
+	if err := json.Unmarshal(resp.Body, &names); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(names) != 1 || names[0] != "production" {
+		t.Errorf("Expected [production], got %v", names)
+	}
+
+	if resp := call("unknown"); resp.Status != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", resp.Status)
+	}
+}
+
+func TestDatasourceDispose(t *testing.T) {
+	d := newTestDatasource()
+	d.Dispose()
+
+	if !d.kubernetesClient.(*FakeKubernetesClient).Closed {
+		t.Error("Expected Kubernetes client to be closed")
+	}
+}
+
+func TestNewCarbonFootprintDatasourceInvalidSettings(t *testing.T) {
+	_, err := NewDatasourceFactory()(context.Background(), backend.DataSourceInstanceSettings{
+		JSONData: []byte(`{"authMode":"token"}`),
+	})
+	if err == nil {
+		t.Error("Expected error for invalid settings, got nil")
+	}
+}
+
+func newTestDatasource() *CarbonFootprintDatasource {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node-1",
+			Labels: map[string]string{
+				"node.kubernetes.io/instance-type": "m5.large",
+			},
+		},
+		Status: corev1.NodeStatus{
+			Capacity: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("2"),
+				corev1.ResourceMemory: resource.MustParse("8Gi"),
+			},
+		},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "production"},
+		Spec: corev1.PodSpec{
+			NodeName: "node-1",
+			Containers: []corev1.Container{{
+				Name: "web",
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("500m"),
+						corev1.ResourceMemory: resource.MustParse("1Gi"),
+					},
+				},
+			}},
+		},
+	}
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "production"}}
+
+	return newCarbonFootprintDatasource(
+		NewCarbonCalculator(&CarbonConfig{
+			DefaultGridIntensity: 400,
+			PUE:                  1.2,
+		}),
+		NewFakeKubernetesClient(
+			[]*corev1.Node{node},
+			[]*corev1.Pod{pod},
+			[]*corev1.Namespace{namespace},
+		),
+		nil,
+	)
+}
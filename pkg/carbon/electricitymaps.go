@@ -0,0 +1,241 @@
+package carbon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	electricityMapsBaseURL = "https://api.electricitymap.org/v3"
+
+	// The free tier allows a handful of requests per second
+	electricityMapsRequestsPerSecond = 5
+
+	// Latest values are published hourly, past values never change
+	electricityMapsLatestTTL = 15 * time.Minute
+	electricityMapsPastTTL   = 24 * time.Hour
+
+	// The past-range endpoint serves at most ten days of hourly values per request
+	electricityMapsMaxRange = 10 * 24 * time.Hour
+
+	// defaultRetryAfter is used when a 429 response carries no Retry-After header
+	defaultRetryAfter = time.Minute
+)
+
+// electricityMapsProvider fetches average grid intensity from the Electricity Maps API
+type electricityMapsProvider struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+	cache   *intensityCache
+	limiter *rateLimiter
+}
+
+// electricityMapsResponse is the payload of the latest endpoint and a value of the past-range endpoint
+type electricityMapsResponse struct {
+	Zone            string    `json:"zone"`
+	CarbonIntensity float64   `json:"carbonIntensity"`
+	Datetime        time.Time `json:"datetime"`
+}
+
+// electricityMapsRangeResponse is the past-range endpoint payload
+type electricityMapsRangeResponse struct {
+	Zone string                    `json:"zone"`
+	Data []electricityMapsResponse `json:"data"`
+}
+
+// NewElectricityMapsProvider creates a provider for the Electricity Maps API
+func NewElectricityMapsProvider(apiKey string) GridIntensityProvider {
+	return newElectricityMapsProvider(electricityMapsBaseURL, apiKey, &http.Client{Timeout: 10 * time.Second})
+}
+
+func newElectricityMapsProvider(baseURL, apiKey string, client *http.Client) *electricityMapsProvider {
+	return &electricityMapsProvider{
+		baseURL: baseURL,
+		apiKey:  apiKey,
+		client:  client,
+		cache:   newIntensityCache(),
+		limiter: newRateLimiter(electricityMapsRequestsPerSecond),
+	}
+}
+
+// GetGridIntensity returns the latest intensity for recent times and the historical value otherwise
+func (e *electricityMapsProvider) GetGridIntensity(ctx context.Context, zone string, at time.Time) (*IntensityDataPoint, error) {
+	if zone == "" {
+		return nil, fmt.Errorf("electricity maps: zone is required")
+	}
+	if at.IsZero() || time.Since(at) < time.Hour {
+		return e.latest(ctx, zone)
+	}
+	return e.past(ctx, zone, at)
+}
+
+// latest returns the most recent intensity of zone
+func (e *electricityMapsProvider) latest(ctx context.Context, zone string) (*IntensityDataPoint, error) {
+	key := zone + "|latest"
+	cached, fresh := e.cache.get(key)
+	if fresh {
+		return cached, nil
+	}
+
+	var body electricityMapsResponse
+	if err := e.get(ctx, "/carbon-intensity/latest", url.Values{"zone": {zone}}, &body); err != nil {
+		// Serve a stale value rather than nothing while the API is unavailable
+		if cached != nil {
+			return cached, nil
+		}
+		return nil, err
+	}
+
+	point := newElectricityMapsPoint(zone, body)
+	e.cache.set(key, point, electricityMapsLatestTTL)
+	return point, nil
+}
+
+// past returns the intensity of the hour holding at. Hours outside a loaded range
+// load the whole day around them, so a query never falls back to one request per hour.
+func (e *electricityMapsProvider) past(ctx context.Context, zone string, at time.Time) (*IntensityDataPoint, error) {
+	hour := at.UTC().Truncate(time.Hour)
+	key := electricityMapsPastKey(zone, hour)
+	cached, fresh := e.cache.get(key)
+	if fresh {
+		return cached, nil
+	}
+
+	day := hour.Truncate(24 * time.Hour)
+	if err := e.LoadRange(ctx, zone, day, day.Add(24*time.Hour)); err != nil {
+		if cached != nil {
+			return cached, nil
+		}
+		return nil, err
+	}
+
+	if point, _ := e.cache.get(key); point != nil {
+		return point, nil
+	}
+	return nil, fmt.Errorf("electricity maps has no intensity for zone %s at %s", zone, hour.Format(time.RFC3339))
+}
+
+// LoadRange caches the hourly history of zone from from to to with the past-range endpoint.
+// Hours recent enough to be served by the latest endpoint are left out.
+func (e *electricityMapsProvider) LoadRange(ctx context.Context, zone string, from, to time.Time) error {
+	if zone == "" {
+		return fmt.Errorf("electricity maps: zone is required")
+	}
+
+	from = from.UTC().Truncate(time.Hour)
+	to = to.UTC().Add(time.Hour - 1).Truncate(time.Hour)
+	if end := time.Now().UTC().Add(-time.Hour).Truncate(time.Hour).Add(time.Hour); to.After(end) {
+		to = end
+	}
+	if !from.Before(to) {
+		return nil
+	}
+
+	key := zone + "|" + from.Format(time.RFC3339) + "|" + to.Format(time.RFC3339)
+	if loaded, err := e.cache.rangeLoaded(key); loaded {
+		return err
+	}
+
+	var err error
+	for start := from; start.Before(to) && err == nil; start = start.Add(electricityMapsMaxRange) {
+		end := start.Add(electricityMapsMaxRange)
+		if end.After(to) {
+			end = to
+		}
+		err = e.loadPastRange(ctx, zone, start, end)
+	}
+	e.cache.setRangeLoaded(key, err, electricityMapsPastTTL)
+	return err
+}
+
+// loadPastRange caches the hours from start up to, but excluding, end in a single request
+func (e *electricityMapsProvider) loadPastRange(ctx context.Context, zone string, start, end time.Time) error {
+	params := url.Values{
+		"zone":  {zone},
+		"start": {start.Format(time.RFC3339)},
+		"end":   {end.Format(time.RFC3339)},
+	}
+
+	var body electricityMapsRangeResponse
+	if err := e.get(ctx, "/carbon-intensity/past-range", params, &body); err != nil {
+		return err
+	}
+
+	for _, value := range body.Data {
+		hour := value.Datetime.UTC().Truncate(time.Hour)
+		e.cache.set(electricityMapsPastKey(zone, hour), newElectricityMapsPoint(zone, value), electricityMapsPastTTL)
+	}
+	return nil
+}
+
+// get queries an endpoint and decodes the response into out
+func (e *electricityMapsProvider) get(ctx context.Context, endpoint string, params url.Values, out interface{}) error {
+	if err := e.limiter.wait(ctx); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.baseURL+endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("auth-token", e.apiKey)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("electricity maps request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		e.limiter.backoff(parseRetryAfter(resp.Header.Get("Retry-After")))
+		return errRateLimited
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("electricity maps returned status %d for zone %s", resp.StatusCode, params.Get("zone"))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode electricity maps response: %w", err)
+	}
+	return nil
+}
+
+// newElectricityMapsPoint converts a carbon-intensity value of zone
+func newElectricityMapsPoint(zone string, value electricityMapsResponse) *IntensityDataPoint {
+	return &IntensityDataPoint{
+		Timestamp: value.Datetime,
+		Zone:      zone,
+		Intensity: value.CarbonIntensity,
+		Basis:     IntensityBasisAverage,
+		Source:    GridProviderElectricityMaps,
+	}
+}
+
+// electricityMapsPastKey is the cache key of an hour of zone's history
+func electricityMapsPastKey(zone string, hour time.Time) string {
+	return zone + "|" + hour.Format(time.RFC3339)
+}
+
+// Name returns the provider identifier
+func (e *electricityMapsProvider) Name() string {
+	return GridProviderElectricityMaps
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := time.Until(date); d > 0 {
+			return d
+		}
+	}
+	return defaultRetryAfter
+}
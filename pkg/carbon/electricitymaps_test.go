@@ -0,0 +1,233 @@
+package carbon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestElectricityMapsProvider(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("LatestIsCached", func(t *testing.T) {
+		var requests int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			if r.URL.Path != "/carbon-intensity/latest" {
+				t.Errorf("Unexpected path %s", r.URL.Path)
+			}
+			if r.Header.Get("auth-token") != "test-key" {
+				t.Errorf("Expected auth-token header, got %q", r.Header.Get("auth-token"))
+			}
+			if r.URL.Query().Get("zone") != "DE" {
+				t.Errorf("Expected zone DE, got %s", r.URL.Query().Get("zone"))
+			}
+			w.Write([]byte(`{"zone":"DE","carbonIntensity":302,"datetime":"2023-01-01T10:00:00.000Z"}`))
+		}))
+		defer server.Close()
+
+		provider := newElectricityMapsProvider(server.URL, "test-key", server.Client())
+
+		for i := 0; i < 3; i++ {
+			point, err := provider.GetGridIntensity(ctx, "DE", time.Time{})
+			if err != nil {
+				t.Fatalf("GetGridIntensity failed: %v", err)
+			}
+			if point.Intensity != 302 {
+				t.Errorf("Expected intensity 302, got %f", point.Intensity)
+			}
+			if point.Source != GridProviderElectricityMaps {
+				t.Errorf("Expected source %s, got %s", GridProviderElectricityMaps, point.Source)
+			}
+		}
+
+		if requests != 1 {
+			t.Errorf("Expected 1 upstream request, got %d", requests)
+		}
+	})
+
+	t.Run("PastLoadsDay", func(t *testing.T) {
+		var requests int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			if r.URL.Path != "/carbon-intensity/past-range" {
+				t.Errorf("Unexpected path %s", r.URL.Path)
+			}
+			if start, end := r.URL.Query().Get("start"), r.URL.Query().Get("end"); start != "2023-01-01T00:00:00Z" || end != "2023-01-02T00:00:00Z" {
+				t.Errorf("Expected the day around the hour, got %s to %s", start, end)
+			}
+			w.Write([]byte(`{"zone":"FR","data":[` +
+				`{"zone":"FR","carbonIntensity":56,"datetime":"2023-01-01T10:00:00.000Z"},` +
+				`{"zone":"FR","carbonIntensity":61,"datetime":"2023-01-01T11:00:00.000Z"}]}`))
+		}))
+		defer server.Close()
+
+		provider := newElectricityMapsProvider(server.URL, "test-key", server.Client())
+
+		for at, want := range map[time.Time]float64{
+			time.Date(2023, 1, 1, 10, 30, 0, 0, time.UTC): 56,
+			time.Date(2023, 1, 1, 11, 0, 0, 0, time.UTC):  61,
+		} {
+			point, err := provider.GetGridIntensity(ctx, "FR", at)
+			if err != nil {
+				t.Fatalf("GetGridIntensity failed: %v", err)
+			}
+			if point.Intensity != want {
+				t.Errorf("Expected intensity %f at %s, got %f", want, at, point.Intensity)
+			}
+		}
+
+		// Hours the range has no value for are not requested one by one
+		if _, err := provider.GetGridIntensity(ctx, "FR", time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)); err == nil {
+			t.Error("Expected error for an hour without data, got nil")
+		}
+		if requests != 1 {
+			t.Errorf("Expected 1 upstream request, got %d", requests)
+		}
+	})
+
+	t.Run("LoadRange", func(t *testing.T) {
+		var requests int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			start, _ := time.Parse(time.RFC3339, r.URL.Query().Get("start"))
+			end, _ := time.Parse(time.RFC3339, r.URL.Query().Get("end"))
+			if end.Sub(start) > electricityMapsMaxRange {
+				t.Errorf("Expected at most ten days per request, got %s", end.Sub(start))
+			}
+
+			var values []string
+			for at := start; at.Before(end); at = at.Add(time.Hour) {
+				values = append(values, fmt.Sprintf(`{"zone":"DE","carbonIntensity":%d,"datetime":"%s"}`, at.Day(), at.Format(time.RFC3339)))
+			}
+			fmt.Fprintf(w, `{"zone":"DE","data":[%s]}`, strings.Join(values, ","))
+		}))
+		defer server.Close()
+
+		provider := newElectricityMapsProvider(server.URL, "test-key", server.Client())
+
+		// Thirty days of hourly values take three requests, not one per hour
+		from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		if err := provider.LoadRange(ctx, "DE", from, from.AddDate(0, 0, 30)); err != nil {
+			t.Fatalf("LoadRange failed: %v", err)
+		}
+		if err := provider.LoadRange(ctx, "DE", from, from.AddDate(0, 0, 30)); err != nil {
+			t.Fatalf("LoadRange failed: %v", err)
+		}
+		for at := from; at.Before(from.AddDate(0, 0, 30)); at = at.Add(7 * time.Hour) {
+			point, err := provider.GetGridIntensity(ctx, "DE", at)
+			if err != nil {
+				t.Fatalf("GetGridIntensity failed: %v", err)
+			}
+			if point.Intensity != float64(at.Day()) {
+				t.Errorf("Expected intensity %d at %s, got %f", at.Day(), at, point.Intensity)
+			}
+		}
+		if requests != 3 {
+			t.Errorf("Expected 3 upstream requests, got %d", requests)
+		}
+	})
+
+	t.Run("FailedRangeIsRemembered", func(t *testing.T) {
+		var requests int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		provider := newElectricityMapsProvider(server.URL, "test-key", server.Client())
+
+		from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		for at := from; at.Before(from.Add(24 * time.Hour)); at = at.Add(time.Hour) {
+			if _, err := provider.GetGridIntensity(ctx, "DE", at); err == nil {
+				t.Fatal("Expected error from failing API, got nil")
+			}
+		}
+		if requests != 1 {
+			t.Errorf("Expected 1 upstream request for the day, got %d", requests)
+		}
+	})
+
+	t.Run("RateLimited", func(t *testing.T) {
+		var requests int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer server.Close()
+
+		provider := newElectricityMapsProvider(server.URL, "test-key", server.Client())
+
+		if _, err := provider.GetGridIntensity(ctx, "DE", time.Time{}); err == nil {
+			t.Fatal("Expected error when rate limited, got nil")
+		}
+		if _, err := provider.GetGridIntensity(ctx, "FR", time.Time{}); err != errRateLimited {
+			t.Errorf("Expected errRateLimited while backing off, got %v", err)
+		}
+		if requests != 1 {
+			t.Errorf("Expected no requests during backoff, got %d", requests)
+		}
+	})
+
+	t.Run("MissingZone", func(t *testing.T) {
+		provider := newElectricityMapsProvider("http://127.0.0.1:0", "test-key", http.DefaultClient)
+		if _, err := provider.GetGridIntensity(ctx, "", time.Time{}); err == nil {
+			t.Error("Expected error for missing zone, got nil")
+		}
+	})
+}
+
+func TestCalculatorUsesGridIntensityProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/carbon-intensity/latest" {
+			w.Write([]byte(`{"zone":"SE","carbonIntensity":25,"datetime":"2023-01-01T10:00:00.000Z"}`))
+			return
+		}
+		start, _ := time.Parse(time.RFC3339, r.URL.Query().Get("start"))
+		fmt.Fprintf(w, `{"zone":"SE","data":[{"zone":"SE","carbonIntensity":25,"datetime":"%s"}]}`, start.Format(time.RFC3339))
+	}))
+	defer server.Close()
+
+	config := &CarbonConfig{DefaultGridIntensity: 475, PUE: 1.0, GridZone: "SE"}
+	calculator := NewCarbonCalculator(config,
+		WithGridIntensityProvider(newElectricityMapsProvider(server.URL, "test-key", server.Client())))
+
+	metrics, err := calculator.CalculatePodCarbon(context.Background(), createTestPods()[0], nil, nil, defaultWindow(time.Now()))
+	if err != nil {
+		t.Fatalf("CalculatePodCarbon failed: %v", err)
+	}
+	if metrics[0].GridIntensity != 25 {
+		t.Errorf("Expected grid intensity 25 from provider, got %f", metrics[0].GridIntensity)
+	}
+
+	// Unreachable providers fall back to the configured default
+	calculator = NewCarbonCalculator(config,
+		WithGridIntensityProvider(newElectricityMapsProvider("http://127.0.0.1:0", "test-key", http.DefaultClient)))
+	metrics, err = calculator.CalculatePodCarbon(context.Background(), createTestPods()[0], nil, nil, defaultWindow(time.Now()))
+	if err != nil {
+		t.Fatalf("CalculatePodCarbon failed: %v", err)
+	}
+	if metrics[0].GridIntensity != 475 {
+		t.Errorf("Expected default grid intensity 475, got %f", metrics[0].GridIntensity)
+	}
+
+	// Fallbacks are reported with the query
+	ctx, notices := withQueryNotices(context.Background())
+	if _, err := calculator.CalculatePodCarbon(ctx, createTestPods()[0], nil, nil, defaultWindow(time.Now())); err != nil {
+		t.Fatalf("CalculatePodCarbon failed: %v", err)
+	}
+	frames := data.Frames{data.NewFrame("A")}
+	notices.attach(frames)
+	if frames[0].Meta == nil || len(frames[0].Meta.Notices) != 1 || !strings.Contains(frames[0].Meta.Notices[0].Text, `"SE"`) {
+		t.Errorf("Expected a notice about zone SE, got %+v", frames[0].Meta)
+	}
+}
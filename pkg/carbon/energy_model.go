@@ -0,0 +1,130 @@
+package carbon
+
+// Energy models
+const (
+	// EnergyModelCCF is the Cloud Carbon Footprint linear min/max watts per vCPU model
+	EnergyModelCCF = "ccf"
+	// EnergyModelSPECpower interpolates measured SPECpower_ssj2008 server load curves
+	EnergyModelSPECpower = "specpower"
+)
+
+// bytesPerGiB converts Kubernetes memory quantities to the GB used by energy models
+const bytesPerGiB = 1024 * 1024 * 1024
+
+// Sizing for nodes and pods whose instance type is unknown and whose capacity is not reported
+const (
+	defaultNodeVCPUs    = 2
+	defaultNodeMemoryGB = 4
+)
+
+// EnergyModelProvider turns hardware specs and utilization into power draw
+type EnergyModelProvider interface {
+	// NodePower returns the draw in watts of a whole node running at cpuUtilization (0-1)
+	NodePower(specs *InstanceSpecs, cpuUtilization float64) float64
+
+	// WorkloadPower returns the draw in watts attributable to a workload using
+	// cpuCores and memoryGB on a node described by specs
+	WorkloadPower(specs *InstanceSpecs, cpuCores, memoryGB float64) float64
+
+	// Name returns the model identifier, one of the EnergyModel constants
+	Name() string
+}
+
+// NewEnergyModelProvider creates the energy model selected in the carbon configuration
+func NewEnergyModelProvider(config *CarbonConfig) EnergyModelProvider {
+	switch config.EnergyModel {
+	case EnergyModelSPECpower:
+		return NewSPECpowerEnergyModel()
+	default:
+		return NewCCFEnergyModel()
+	}
+}
+
+// ccfCoefficients are the idle and full load watts per vCPU of a CPU microarchitecture
+type ccfCoefficients struct {
+	minWatts float64
+	maxWatts float64
+}
+
+// ccfMicroarchitectures holds the Cloud Carbon Footprint per-vCPU coefficients.
+// Ice Lake and Graviton3 are not published yet and reuse their predecessors' figures.
+var ccfMicroarchitectures = map[string]ccfCoefficients{
+	"Sandy Bridge": {minWatts: 2.17, maxWatts: 8.58},
+	"Ivy Bridge":   {minWatts: 3.04, maxWatts: 8.25},
+	"Haswell":      {minWatts: 1.00, maxWatts: 4.74},
+	"Broadwell":    {minWatts: 0.71, maxWatts: 3.69},
+	"Skylake":      {minWatts: 0.65, maxWatts: 4.26},
+	"Cascade Lake": {minWatts: 0.64, maxWatts: 3.97},
+	"Ice Lake":     {minWatts: 0.64, maxWatts: 3.97},
+	"Coffee Lake":  {minWatts: 1.14, maxWatts: 5.42},
+	"EPYC 1st Gen": {minWatts: 0.82, maxWatts: 2.55},
+	"EPYC 2nd Gen": {minWatts: 0.47, maxWatts: 1.64},
+	"EPYC 3rd Gen": {minWatts: 0.45, maxWatts: 2.02},
+	"Graviton":     {minWatts: 0.47, maxWatts: 1.69},
+	"Graviton2":    {minWatts: 0.47, maxWatts: 1.69},
+	"Graviton3":    {minWatts: 0.47, maxWatts: 1.69},
+}
+
+// ccfAverageCoefficients is used for unknown microarchitectures, the average across all of them
+var ccfAverageCoefficients = ccfCoefficients{minWatts: 0.74, maxWatts: 3.5}
+
+// ccfMemoryWattsPerGB is the Cloud Carbon Footprint memory coefficient, 0.000392 kWh per GB-hour
+const ccfMemoryWattsPerGB = 0.392
+
+// ccfEnergyModel implements the Cloud Carbon Footprint methodology: per-vCPU watts interpolated
+// linearly between idle and full load, plus a fixed draw per GB of memory
+type ccfEnergyModel struct{}
+
+// NewCCFEnergyModel creates a Cloud Carbon Footprint energy model
+func NewCCFEnergyModel() EnergyModelProvider {
+	return ccfEnergyModel{}
+}
+
+// NodePower returns the CPU draw at cpuUtilization plus the draw of all installed memory
+func (ccfEnergyModel) NodePower(specs *InstanceSpecs, cpuUtilization float64) float64 {
+	minWatts, maxWatts := ccfCPUWatts(specs)
+	return minWatts + (maxWatts-minWatts)*clampUtilization(cpuUtilization) + specs.MemoryGB*ccfMemoryWattsPerGB
+}
+
+// WorkloadPower charges each core a workload uses at full load, so it carries its cores' idle draw too
+func (ccfEnergyModel) WorkloadPower(specs *InstanceSpecs, cpuCores, memoryGB float64) float64 {
+	_, maxWatts := ccfCPUWatts(specs)
+	perVCPU := ccfCoefficientsFor(specs.Microarchitecture).maxWatts
+	if specs.VCPUs > 0 {
+		perVCPU = maxWatts / float64(specs.VCPUs)
+	}
+	return cpuCores*perVCPU + memoryGB*ccfMemoryWattsPerGB
+}
+
+// Name returns the model identifier
+func (ccfEnergyModel) Name() string {
+	return EnergyModelCCF
+}
+
+// ccfCPUWatts returns the idle and full load CPU draw of the whole instance. Catalog entries carry
+// their own watts, nodes outside the catalog fall back to the microarchitecture coefficients.
+func ccfCPUWatts(specs *InstanceSpecs) (float64, float64) {
+	if specs.MaxWatts > 0 {
+		return specs.MinWatts, specs.MaxWatts
+	}
+	coeff := ccfCoefficientsFor(specs.Microarchitecture)
+	return float64(specs.VCPUs) * coeff.minWatts, float64(specs.VCPUs) * coeff.maxWatts
+}
+
+func ccfCoefficientsFor(microarchitecture string) ccfCoefficients {
+	if coeff, ok := ccfMicroarchitectures[microarchitecture]; ok {
+		return coeff
+	}
+	return ccfAverageCoefficients
+}
+
+// clampUtilization keeps utilization within 0 and 1
+func clampUtilization(u float64) float64 {
+	if u < 0 {
+		return 0
+	}
+	if u > 1 {
+		return 1
+	}
+	return u
+}
@@ -0,0 +1,110 @@
+package carbon
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCCFEnergyModel(t *testing.T) {
+	model := NewCCFEnergyModel()
+	specs := &InstanceSpecs{InstanceType: "m5.xlarge", VCPUs: 4, MemoryGB: 16, Microarchitecture: "Skylake"}
+	memoryWatts := 16 * ccfMemoryWattsPerGB
+
+	t.Run("NodeIdleAndFullLoad", func(t *testing.T) {
+		if got, want := model.NodePower(specs, 0), 4*0.65+memoryWatts; abs(got-want) > 1e-9 {
+			t.Errorf("Expected idle power %f, got %f", want, got)
+		}
+		if got, want := model.NodePower(specs, 1), 4*4.26+memoryWatts; abs(got-want) > 1e-9 {
+			t.Errorf("Expected full load power %f, got %f", want, got)
+		}
+	})
+
+	t.Run("LinearInterpolation", func(t *testing.T) {
+		idle := model.NodePower(specs, 0)
+		full := model.NodePower(specs, 1)
+		if got, want := model.NodePower(specs, 0.25), idle+(full-idle)*0.25; abs(got-want) > 1e-9 {
+			t.Errorf("Expected power %f at 25%% utilization, got %f", want, got)
+		}
+		if got := model.NodePower(specs, 1.5); abs(got-full) > 1e-9 {
+			t.Errorf("Expected utilization above 1 to be clamped to %f, got %f", full, got)
+		}
+	})
+
+	t.Run("UnknownMicroarchitectureUsesAverage", func(t *testing.T) {
+		unknown := &InstanceSpecs{VCPUs: 2, MemoryGB: 4}
+		if got, want := model.NodePower(unknown, 1), 2*ccfAverageCoefficients.maxWatts+4*ccfMemoryWattsPerGB; abs(got-want) > 1e-9 {
+			t.Errorf("Expected average coefficients %f, got %f", want, got)
+		}
+	})
+
+	t.Run("CatalogWatts", func(t *testing.T) {
+		catalog := &InstanceSpecs{VCPUs: 4, MemoryGB: 16, Microarchitecture: "Skylake", MinWatts: 10, MaxWatts: 30}
+		if got, want := model.NodePower(catalog, 0.5), 20+memoryWatts; abs(got-want) > 1e-9 {
+			t.Errorf("Expected power %f from the catalog watts, got %f", want, got)
+		}
+		if got, want := model.WorkloadPower(catalog, 2, 0), 15.0; abs(got-want) > 1e-9 {
+			t.Errorf("Expected workload power %f from the catalog watts, got %f", want, got)
+		}
+	})
+
+	t.Run("WorkloadPower", func(t *testing.T) {
+		if got, want := model.WorkloadPower(specs, 0.5, 2), 0.5*4.26+2*ccfMemoryWattsPerGB; abs(got-want) > 1e-9 {
+			t.Errorf("Expected workload power %f, got %f", want, got)
+		}
+		if model.WorkloadPower(specs, 4, 16) > model.NodePower(specs, 1) {
+			t.Error("Workload using the whole node should not draw more than the node")
+		}
+	})
+}
+
+func TestCalculatorUsesEnergyModel(t *testing.T) {
+	ctx := context.Background()
+	config := &CarbonConfig{DefaultGridIntensity: 500, PUE: 1.0}
+	calculator := NewCarbonCalculator(config, WithEnergyModelProvider(constantEnergyModel(100)))
+
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Status: corev1.NodeStatus{
+			Capacity: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("8"),
+				corev1.ResourceMemory: resource.MustParse("32Gi"),
+			},
+		},
+	}
+
+	metrics, err := calculator.CalculateNodeCarbon(ctx, node, nil, defaultWindow(time.Now()))
+	if err != nil {
+		t.Fatalf("CalculateNodeCarbon failed: %v", err)
+	}
+	// 100 W for one hour
+	if abs(metrics[0].EnergyConsumption-0.1) > 1e-9 {
+		t.Errorf("Expected 0.1 kWh from the energy model, got %f", metrics[0].EnergyConsumption)
+	}
+
+	t.Run("UnknownInstanceSizedFromCapacity", func(t *testing.T) {
+		specs := calculator.(*carbonCalculator).nodeSpecs(node)
+		if specs.VCPUs != 8 || specs.MemoryGB != 32 {
+			t.Errorf("Expected 8 vCPUs and 32 GB from node capacity, got %d and %f", specs.VCPUs, specs.MemoryGB)
+		}
+	})
+}
+
+// constantEnergyModel draws the same power regardless of hardware and load
+type constantEnergyModel float64
+
+func (m constantEnergyModel) NodePower(specs *InstanceSpecs, cpuUtilization float64) float64 {
+	return float64(m)
+}
+
+func (m constantEnergyModel) WorkloadPower(specs *InstanceSpecs, cpuCores, memoryGB float64) float64 {
+	return float64(m)
+}
+
+func (m constantEnergyModel) Name() string {
+	return "constant"
+}
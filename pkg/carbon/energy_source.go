@@ -0,0 +1,98 @@
+package carbon
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Energy sources
+const (
+	// EnergySourceModel estimates energy with the configured energy model only
+	EnergySourceModel = "model"
+	// EnergySourceKepler reads energy measured by Kepler, the model fills gaps
+	EnergySourceKepler = "kepler"
+)
+
+// Values of Metrics.Source
+const (
+	// SourceCalculated marks energy estimated by the energy model
+	SourceCalculated = "calculated"
+	// SourceMeasured marks energy measured by an energy source
+	SourceMeasured = "measured"
+
+	// sourceMixed marks rollups combining measured and estimated energy
+	sourceMixed = "mixed"
+)
+
+// joulesPerKWh converts joules to kWh
+const joulesPerKWh = 3.6e6
+
+// EnergySample is the energy consumed during the step starting at Timestamp
+type EnergySample struct {
+	Timestamp time.Time
+	Joules    float64
+}
+
+// EnergySource reports measured energy consumption of pods and nodes
+type EnergySource interface {
+	// PodEnergy returns the energy pod consumed in the steps of window, steps without data are left out
+	PodEnergy(ctx context.Context, pod *corev1.Pod, window TimeWindow) ([]EnergySample, error)
+	// NodeEnergy returns the energy node consumed in the steps of window, steps without data are left out
+	NodeEnergy(ctx context.Context, node *corev1.Node, window TimeWindow) ([]EnergySample, error)
+}
+
+// measurePodEnergy returns the measured kWh of pod for each step of window, nil when no energy
+// source is set or it has no data, with nil entries for steps without data
+func (c *carbonCalculator) measurePodEnergy(ctx context.Context, pod *corev1.Pod, window TimeWindow) []*float64 {
+	if c.energySource == nil {
+		return nil
+	}
+	samples, err := c.energySource.PodEnergy(ctx, pod, window)
+	if err != nil {
+		return nil
+	}
+	return bucketEnergy(samples, window)
+}
+
+// measureNodeEnergy returns the measured kWh of node for each step of window, nil when no energy
+// source is set or it has no data, with nil entries for steps without data
+func (c *carbonCalculator) measureNodeEnergy(ctx context.Context, node *corev1.Node, window TimeWindow) []*float64 {
+	if c.energySource == nil {
+		return nil
+	}
+	samples, err := c.energySource.NodeEnergy(ctx, node, window)
+	if err != nil {
+		return nil
+	}
+	return bucketEnergy(samples, window)
+}
+
+// bucketEnergy places samples, converted to kWh, at the index of their step
+func bucketEnergy(samples []EnergySample, window TimeWindow) []*float64 {
+	steps := make([]*float64, len(window.Steps()))
+	for _, sample := range samples {
+		if idx := window.stepIndex(sample.Timestamp); idx >= 0 && idx < len(steps) {
+			kWh := sample.Joules / joulesPerKWh
+			steps[idx] = &kWh
+		}
+	}
+	return steps
+}
+
+// stepEnergyOf returns the measured kWh of step i, nil when there is none
+func stepEnergyOf(measured []*float64, i int) *float64 {
+	if i >= len(measured) {
+		return nil
+	}
+	return measured[i]
+}
+
+// combineSources returns the source of a rollup over values from source and previous
+func combineSources(previous, source string) string {
+	if previous == "" || previous == source {
+		return source
+	}
+	return sourceMixed
+}
@@ -0,0 +1,264 @@
+package carbon
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// fileIntensityReloadInterval bounds how often the file is checked for changes
+	fileIntensityReloadInterval = time.Minute
+
+	// fileIntensityDefaultZone is used for zones missing from the file
+	fileIntensityDefaultZone = "default"
+)
+
+// fileIntensityTimeFormats are accepted for timestamps, from hourly down to monthly resolution
+var fileIntensityTimeFormats = []string{
+	time.RFC3339,
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006-01",
+}
+
+// fileIntensityProvider serves grid intensity from a local CSV or JSON file, for clusters without
+// internet egress. Values between two points are linearly interpolated; times outside the
+// covered range use the nearest point.
+//
+// CSV files have a "zone,timestamp,intensity" header. JSON files look like
+//
+//	{"zones": {"DE": [{"timestamp": "2024-01", "intensity": 380}, ...]}}
+//
+// A zone named "default" is used for zones missing from the file.
+type fileIntensityProvider struct {
+	path string
+
+	mu        sync.Mutex
+	series    map[string][]fileIntensityPoint
+	modTime   time.Time
+	checkedAt time.Time
+	loadErr   error
+}
+
+// fileIntensityPoint is one row of the intensity file
+type fileIntensityPoint struct {
+	Timestamp time.Time
+	Intensity float64
+}
+
+// NewFileGridIntensityProvider creates a provider reading the intensity file at path
+func NewFileGridIntensityProvider(path string) GridIntensityProvider {
+	return &fileIntensityProvider{path: path}
+}
+
+// GetGridIntensity returns the interpolated intensity for zone at the given time
+func (f *fileIntensityProvider) GetGridIntensity(ctx context.Context, zone string, at time.Time) (*IntensityDataPoint, error) {
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	series, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+
+	points, ok := series[zone]
+	if !ok {
+		points, ok = series[fileIntensityDefaultZone]
+	}
+	if !ok || len(points) == 0 {
+		return nil, fmt.Errorf("no intensity for zone %s in %s", zone, f.path)
+	}
+
+	return &IntensityDataPoint{
+		Timestamp: at,
+		Zone:      zone,
+		Intensity: interpolateIntensity(points, at),
+		Basis:     IntensityBasisAverage,
+		Source:    GridProviderFile,
+	}, nil
+}
+
+// Name returns the provider identifier
+func (f *fileIntensityProvider) Name() string {
+	return GridProviderFile
+}
+
+// load returns the parsed file, re-reading it when it changed on disk. Once the file loaded, the last
+// good data keeps being served while the file is missing or broken, e.g. during a ConfigMap swap.
+func (f *fileIntensityProvider) load() (map[string][]fileIntensityPoint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	if (f.series != nil || f.loadErr != nil) && now.Sub(f.checkedAt) < fileIntensityReloadInterval {
+		return f.lastGood()
+	}
+	f.checkedAt = now
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		f.loadErr = fmt.Errorf("failed to read grid intensity file: %w", err)
+		return f.lastGood()
+	}
+	if f.series != nil && info.ModTime().Equal(f.modTime) {
+		return f.series, nil
+	}
+
+	series, err := readIntensityFile(f.path)
+	if err != nil {
+		f.loadErr = err
+		return f.lastGood()
+	}
+
+	f.series = series
+	f.modTime = info.ModTime()
+	f.loadErr = nil
+	return f.series, nil
+}
+
+// lastGood returns the last successfully loaded data, or the load error when the file never loaded
+func (f *fileIntensityProvider) lastGood() (map[string][]fileIntensityPoint, error) {
+	if f.series != nil {
+		return f.series, nil
+	}
+	return nil, f.loadErr
+}
+
+// readIntensityFile parses a CSV or JSON intensity file, chosen by extension
+func readIntensityFile(path string) (map[string][]fileIntensityPoint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open grid intensity file: %w", err)
+	}
+	defer file.Close()
+
+	var series map[string][]fileIntensityPoint
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		series, err = parseIntensityJSON(file)
+	case ".csv":
+		series, err = parseIntensityCSV(file)
+	default:
+		return nil, fmt.Errorf("unsupported grid intensity file type %q, expected .csv or .json", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse grid intensity file %s: %w", path, err)
+	}
+
+	for _, points := range series {
+		sort.Slice(points, func(i, j int) bool { return points[i].Timestamp.Before(points[j].Timestamp) })
+	}
+	return series, nil
+}
+
+// parseIntensityJSON reads the {"zones": {...}} form
+func parseIntensityJSON(r io.Reader) (map[string][]fileIntensityPoint, error) {
+	var body struct {
+		Zones map[string][]struct {
+			Timestamp string  `json:"timestamp"`
+			Intensity float64 `json:"intensity"`
+		} `json:"zones"`
+	}
+	if err := json.NewDecoder(r).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	series := make(map[string][]fileIntensityPoint, len(body.Zones))
+	for zone, rows := range body.Zones {
+		for i, row := range rows {
+			ts, err := parseIntensityTimestamp(row.Timestamp)
+			if err != nil {
+				return nil, fmt.Errorf("zone %s entry %d: %w", zone, i, err)
+			}
+			series[zone] = append(series[zone], fileIntensityPoint{Timestamp: ts, Intensity: row.Intensity})
+		}
+	}
+	return series, nil
+}
+
+// csvIntensityHeader is the required first line of CSV intensity files
+var csvIntensityHeader = []string{"zone", "timestamp", "intensity"}
+
+// parseIntensityCSV reads rows of zone, timestamp and intensity after a header line
+func parseIntensityCSV(r io.Reader) (map[string][]fileIntensityPoint, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("file is empty")
+	}
+	for i, column := range csvIntensityHeader {
+		if !strings.EqualFold(strings.TrimSpace(records[0][i]), column) {
+			return nil, fmt.Errorf("line 1: expected header %q, got %q", strings.Join(csvIntensityHeader, ","), strings.Join(records[0], ","))
+		}
+	}
+
+	series := make(map[string][]fileIntensityPoint)
+	for i, record := range records[1:] {
+		// TrimLeadingSpace leaves the spaces before each separator
+		for j := range record {
+			record[j] = strings.TrimSpace(record[j])
+		}
+		ts, err := parseIntensityTimestamp(record[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+2, err)
+		}
+		intensity, err := strconv.ParseFloat(record[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid intensity %q", i+2, record[2])
+		}
+		series[record[0]] = append(series[record[0]], fileIntensityPoint{Timestamp: ts, Intensity: intensity})
+	}
+	return series, nil
+}
+
+// parseIntensityTimestamp accepts hourly, daily and monthly timestamps, UTC unless stated otherwise
+func parseIntensityTimestamp(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range fileIntensityTimeFormats {
+		if ts, err := time.Parse(layout, value); err == nil {
+			return ts, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+}
+
+// interpolateIntensity linearly interpolates sorted points at the given time
+func interpolateIntensity(points []fileIntensityPoint, at time.Time) float64 {
+	if !at.After(points[0].Timestamp) {
+		return points[0].Intensity
+	}
+	last := points[len(points)-1]
+	if !at.Before(last.Timestamp) {
+		return last.Intensity
+	}
+
+	// First point after at, guaranteed to exist and be preceded by another
+	i := sort.Search(len(points), func(i int) bool { return points[i].Timestamp.After(at) })
+	prev, next := points[i-1], points[i]
+
+	span := next.Timestamp.Sub(prev.Timestamp)
+	if span <= 0 {
+		return next.Intensity
+	}
+	fraction := float64(at.Sub(prev.Timestamp)) / float64(span)
+	return prev.Intensity + fraction*(next.Intensity-prev.Intensity)
+}
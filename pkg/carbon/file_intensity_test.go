@@ -0,0 +1,180 @@
+package carbon
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileGridIntensityProvider(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	t.Run("HourlyCSV", func(t *testing.T) {
+		path := filepath.Join(dir, "hourly.csv")
+		writeFile(t, path, "zone,timestamp,intensity\n"+
+			"DE,2024-01-01T00:00:00Z,300\n"+
+			"DE,2024-01-01T01:00:00Z,400\n"+
+			"FR,2024-01-01T00:00:00Z,50\n")
+
+		provider := NewFileGridIntensityProvider(path)
+
+		tests := []struct {
+			zone     string
+			at       time.Time
+			expected float64
+		}{
+			{"DE", time.Date(2024, 1, 1, 0, 30, 0, 0, time.UTC), 350},
+			{"DE", time.Date(2024, 1, 1, 0, 15, 0, 0, time.UTC), 325},
+			{"DE", time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC), 300},
+			{"DE", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), 400},
+			{"FR", time.Date(2024, 1, 1, 0, 30, 0, 0, time.UTC), 50},
+		}
+		for _, tt := range tests {
+			point, err := provider.GetGridIntensity(ctx, tt.zone, tt.at)
+			if err != nil {
+				t.Fatalf("GetGridIntensity failed: %v", err)
+			}
+			if abs(point.Intensity-tt.expected) > 1e-9 {
+				t.Errorf("Expected %f for %s at %s, got %f", tt.expected, tt.zone, tt.at, point.Intensity)
+			}
+		}
+
+		if _, err := provider.GetGridIntensity(ctx, "PL", time.Now()); err == nil {
+			t.Error("Expected error for zone missing from file, got nil")
+		}
+	})
+
+	t.Run("MonthlyJSONWithDefault", func(t *testing.T) {
+		path := filepath.Join(dir, "monthly.json")
+		writeFile(t, path, `{"zones": {
+			"default": [{"timestamp": "2024-01", "intensity": 500}],
+			"SE": [{"timestamp": "2024-01", "intensity": 20}, {"timestamp": "2024-03", "intensity": 40}]
+		}}`)
+
+		provider := NewFileGridIntensityProvider(path)
+
+		point, err := provider.GetGridIntensity(ctx, "SE", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatalf("GetGridIntensity failed: %v", err)
+		}
+		// February 1st is 31 of the 60 days between the two monthly points
+		expected := 20 + 20*31.0/60.0
+		if abs(point.Intensity-expected) > 1e-9 {
+			t.Errorf("Expected %f, got %f", expected, point.Intensity)
+		}
+
+		point, err = provider.GetGridIntensity(ctx, "US-CAL-CISO", time.Now())
+		if err != nil {
+			t.Fatalf("GetGridIntensity failed: %v", err)
+		}
+		if point.Intensity != 500 {
+			t.Errorf("Expected default zone intensity 500, got %f", point.Intensity)
+		}
+	})
+
+	t.Run("Reload", func(t *testing.T) {
+		path := filepath.Join(dir, "reload.csv")
+		writeFile(t, path, "zone,timestamp,intensity\nDE,2024-01,300\n")
+
+		provider := NewFileGridIntensityProvider(path).(*fileIntensityProvider)
+		if point, _ := provider.GetGridIntensity(ctx, "DE", time.Now()); point == nil || point.Intensity != 300 {
+			t.Fatalf("Expected initial intensity 300, got %v", point)
+		}
+
+		writeFile(t, path, "zone,timestamp,intensity\nDE,2024-01,250\n")
+		later := time.Now().Add(time.Hour)
+		if err := os.Chtimes(path, later, later); err != nil {
+			t.Fatalf("Chtimes failed: %v", err)
+		}
+		provider.checkedAt = time.Time{}
+
+		if point, _ := provider.GetGridIntensity(ctx, "DE", time.Now()); point == nil || point.Intensity != 250 {
+			t.Errorf("Expected reloaded intensity 250, got %v", point)
+		}
+	})
+
+	t.Run("KeepsLastGoodData", func(t *testing.T) {
+		path := filepath.Join(dir, "swap.csv")
+		writeFile(t, path, "zone,timestamp,intensity\nDE,2024-01,300\n")
+
+		provider := NewFileGridIntensityProvider(path).(*fileIntensityProvider)
+		if point, _ := provider.GetGridIntensity(ctx, "DE", time.Now()); point == nil || point.Intensity != 300 {
+			t.Fatalf("Expected initial intensity 300, got %v", point)
+		}
+
+		// Briefly missing, then broken, the file keeps serving the data loaded before
+		if err := os.Remove(path); err != nil {
+			t.Fatalf("Remove failed: %v", err)
+		}
+		for _, recheck := range []bool{true, false} {
+			if recheck {
+				provider.checkedAt = time.Time{}
+			}
+			point, err := provider.GetGridIntensity(ctx, "DE", time.Now())
+			if err != nil || point.Intensity != 300 {
+				t.Errorf("Expected last good intensity 300 while the file is missing, got %v, %v", point, err)
+			}
+		}
+
+		writeFile(t, path, "zone,timestamp,intensity\nDE,not-a-time,250\n")
+		later := time.Now().Add(time.Hour)
+		if err := os.Chtimes(path, later, later); err != nil {
+			t.Fatalf("Chtimes failed: %v", err)
+		}
+		provider.checkedAt = time.Time{}
+		for i := 0; i < 2; i++ {
+			point, err := provider.GetGridIntensity(ctx, "DE", time.Now())
+			if err != nil || point.Intensity != 300 {
+				t.Errorf("Expected last good intensity 300 while the file is broken, got %v, %v", point, err)
+			}
+		}
+	})
+
+	t.Run("SpacedCSV", func(t *testing.T) {
+		path := filepath.Join(dir, "spaced.csv")
+		writeFile(t, path, "zone , timestamp , intensity\n"+
+			"DE , 2024-01-01T00:00:00Z , 300 \n")
+
+		point, err := NewFileGridIntensityProvider(path).GetGridIntensity(ctx, "DE", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatalf("GetGridIntensity failed: %v", err)
+		}
+		if point.Intensity != 300 {
+			t.Errorf("Expected 300, got %f", point.Intensity)
+		}
+	})
+
+	t.Run("MissingHeader", func(t *testing.T) {
+		path := filepath.Join(dir, "headerless.csv")
+		writeFile(t, path, "DE,2024-01,300\nFR,2024-01,60\n")
+
+		if _, err := NewFileGridIntensityProvider(path).GetGridIntensity(ctx, "DE", time.Now()); err == nil || !strings.Contains(err.Error(), "header") {
+			t.Errorf("Expected header error, got %v", err)
+		}
+	})
+
+	t.Run("MissingFile", func(t *testing.T) {
+		provider := NewFileGridIntensityProvider(filepath.Join(dir, "missing.csv"))
+		if _, err := provider.GetGridIntensity(ctx, "DE", time.Now()); err == nil {
+			t.Error("Expected error for missing file, got nil")
+		}
+	})
+
+	t.Run("ConfigValidation", func(t *testing.T) {
+		_, err := ParseDatasourceConfig([]byte(`{"gridIntensityProvider": "file", "gridIntensityFile": "/nonexistent.csv"}`), nil)
+		if err == nil {
+			t.Error("Expected validation error for unreadable file, got nil")
+		}
+	})
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+}
@@ -0,0 +1,262 @@
+package carbon
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Keys of Query.Filters
+const (
+	FilterNamespace      = "namespace"      // exact namespace
+	FilterNamespaceRegex = "namespaceRegex" // anchored regular expression on the namespace
+	FilterNodeRegex      = "nodeRegex"      // anchored regular expression on the node name
+	FilterLabelSelector  = "labelSelector"  // Kubernetes label selector, e.g. "app=web,tier!=cache"
+	FilterLabels         = "labels"         // label key/value pairs that must all match
+	FilterZone           = "zone"           // topology zone of the node
+	FilterInstanceType   = "instanceType"   // instance type of the node
+	FilterMinEmissions   = "minEmissions"   // minimum grams CO2 of a resource over the time range
+)
+
+// resourceFilter restricts the resources a query returns.
+//
+// Node filters (node regex, zone and instance type) drop whole nodes along with the pods running on
+// them before anything is calculated, so idle energy is still attributed across complete nodes.
+// Namespace filters select the pods, namespaces and workloads of matching namespaces and leave
+// resources without a namespace, such as nodes and the cluster, alone. The label selector matches
+// pod labels before anything is rolled up, so namespaces, workloads, teams and the cluster only sum
+// the selected pods; node queries match it against the labels of the nodes. The emissions threshold
+// applies to the final rows or groups.
+type resourceFilter struct {
+	namespace      string
+	namespaceRegex *regexp.Regexp
+	nodeRegex      *regexp.Regexp
+	selector       labels.Selector
+	zone           string
+	instanceType   string
+	minEmissions   float64
+}
+
+// parseFilters parses Query.Filters, the error lists every invalid filter
+func parseFilters(filters map[string]interface{}) (*resourceFilter, error) {
+	f := &resourceFilter{selector: labels.Everything()}
+
+	var errs ValidationErrors
+	requirements := labels.Requirements{}
+	for key, value := range filters {
+		field := "filters." + key
+		if value == nil {
+			continue
+		}
+
+		switch key {
+		case FilterNamespace, FilterZone, FilterInstanceType:
+			s, ok := value.(string)
+			if !ok {
+				errs.add(field, "must be a string")
+				continue
+			}
+			switch key {
+			case FilterNamespace:
+				f.namespace = s
+			case FilterZone:
+				f.zone = s
+			case FilterInstanceType:
+				f.instanceType = s
+			}
+		case FilterNamespaceRegex, FilterNodeRegex:
+			s, ok := value.(string)
+			if !ok {
+				errs.add(field, "must be a string")
+				continue
+			}
+			if s == "" {
+				continue
+			}
+			re, err := regexp.Compile("^(?:" + s + ")$")
+			if err != nil {
+				errs.add(field, "invalid regular expression: %v", err)
+				continue
+			}
+			if key == FilterNamespaceRegex {
+				f.namespaceRegex = re
+			} else {
+				f.nodeRegex = re
+			}
+		case FilterLabelSelector:
+			s, ok := value.(string)
+			if !ok {
+				errs.add(field, "must be a string")
+				continue
+			}
+			selector, err := labels.Parse(s)
+			if err != nil {
+				errs.add(field, "invalid label selector: %v", err)
+				continue
+			}
+			parsed, _ := selector.Requirements()
+			requirements = append(requirements, parsed...)
+		case FilterLabels:
+			pairs, ok := value.(map[string]interface{})
+			if !ok {
+				errs.add(field, "must be an object of label values")
+				continue
+			}
+			set := make(labels.Set, len(pairs))
+			for k, v := range pairs {
+				s, ok := v.(string)
+				if !ok {
+					errs.add(field, "value of %q must be a string", k)
+					continue
+				}
+				set[k] = s
+			}
+			selector, err := labels.ValidatedSelectorFromSet(set)
+			if err != nil {
+				errs.add(field, "%v", err)
+				continue
+			}
+			parsed, _ := selector.Requirements()
+			requirements = append(requirements, parsed...)
+		case FilterMinEmissions:
+			threshold, err := parseThreshold(value)
+			if err != nil {
+				errs.add(field, "%v", err)
+				continue
+			}
+			f.minEmissions = threshold
+		default:
+			errs.add(field, "unknown filter")
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid query filters: %s", errs.join())
+	}
+	if len(requirements) > 0 {
+		f.selector = labels.NewSelector().Add(requirements...)
+	}
+	return f, nil
+}
+
+// parseThreshold accepts numbers and, for dashboard variables, numeric strings
+func parseThreshold(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case string:
+		if v == "" {
+			return 0, nil
+		}
+		threshold, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, fmt.Errorf("must be a number")
+		}
+		return threshold, nil
+	default:
+		return 0, fmt.Errorf("must be a number")
+	}
+}
+
+// filtersNodes reports whether any node filter is set
+func (f *resourceFilter) filtersNodes() bool {
+	return f.nodeRegex != nil || f.zone != "" || f.instanceType != ""
+}
+
+// matchesNode reports whether node passes the node filters
+func (f *resourceFilter) matchesNode(node *corev1.Node) bool {
+	if f.nodeRegex != nil && !f.nodeRegex.MatchString(node.Name) {
+		return false
+	}
+	if f.zone != "" && node.Labels[labelTopologyZone] != f.zone {
+		return false
+	}
+	if f.instanceType != "" && nodeInstanceType(node) != f.instanceType {
+		return false
+	}
+	return true
+}
+
+// matchesNamespace reports whether namespace passes the namespace filters
+func (f *resourceFilter) matchesNamespace(namespace string) bool {
+	if f.namespace != "" && namespace != f.namespace {
+		return false
+	}
+	if f.namespaceRegex != nil && !f.namespaceRegex.MatchString(namespace) {
+		return false
+	}
+	return true
+}
+
+// scope keeps the nodes passing the node filters and the pods running on them
+func (f *resourceFilter) scope(nodes []*corev1.Node, pods []*corev1.Pod) ([]*corev1.Node, []*corev1.Pod) {
+	if !f.filtersNodes() {
+		return nodes, pods
+	}
+
+	kept := make(map[string]bool, len(nodes))
+	scopedNodes := make([]*corev1.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if f.matchesNode(node) {
+			kept[node.Name] = true
+			scopedNodes = append(scopedNodes, node)
+		}
+	}
+
+	scopedPods := make([]*corev1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if kept[pod.Spec.NodeName] {
+			scopedPods = append(scopedPods, pod)
+		}
+	}
+	return scopedNodes, scopedPods
+}
+
+// filtersLabels reports whether a label selector is set
+func (f *resourceFilter) filtersLabels() bool {
+	return !f.selector.Empty()
+}
+
+// matchesLabels reports whether the labels of a pod, or of a node in node queries, pass the label selector
+func (f *resourceFilter) matchesLabels(objectLabels map[string]string) bool {
+	return f.selector.Matches(labels.Set(objectLabels))
+}
+
+// selectPods keeps the pods passing the label selector
+func (f *resourceFilter) selectPods(pods []*corev1.Pod) []*corev1.Pod {
+	if !f.filtersLabels() {
+		return pods
+	}
+
+	selected := make([]*corev1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if f.matchesLabels(pod.Labels) {
+			selected = append(selected, pod)
+		}
+	}
+	return selected
+}
+
+// dropBelowThreshold removes the resources whose emissions summed over all steps are below the threshold
+func (f *resourceFilter) dropBelowThreshold(metrics []*Metrics) []*Metrics {
+	if f.minEmissions <= 0 {
+		return metrics
+	}
+
+	totals := make(map[string]float64)
+	for _, m := range metrics {
+		totals[seriesKey(m)] += m.CO2Emissions
+	}
+
+	kept := make([]*Metrics, 0, len(metrics))
+	for _, m := range metrics {
+		if totals[seriesKey(m)] >= f.minEmissions {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,238 @@
+package carbon
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseFilters(t *testing.T) {
+	parse := func(t *testing.T, filtersJSON string) (*resourceFilter, error) {
+		t.Helper()
+		var filters map[string]interface{}
+		if err := json.Unmarshal([]byte(filtersJSON), &filters); err != nil {
+			t.Fatalf("invalid test filters: %v", err)
+		}
+		return parseFilters(filters)
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		f, err := parse(t, `{"namespaceRegex": "prod|staging", "labelSelector": "app=web,tier!=cache", "labels": {"team": "checkout"}, "minEmissions": "12.5"}`)
+		if err != nil {
+			t.Fatalf("parseFilters failed: %v", err)
+		}
+
+		if !f.matchesNamespace("prod") || f.matchesNamespace("production") {
+			t.Error("Expected the namespace regex to be anchored")
+		}
+		if f.minEmissions != 12.5 {
+			t.Errorf("Expected minimum emissions 12.5, got %f", f.minEmissions)
+		}
+
+		pods := []*corev1.Pod{
+			{ObjectMeta: metav1.ObjectMeta{Name: "web", Labels: map[string]string{"app": "web", "team": "checkout"}}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "cache", Labels: map[string]string{"app": "web", "tier": "cache", "team": "checkout"}}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "other-team", Labels: map[string]string{"app": "web", "team": "billing"}}},
+		}
+		selected := f.selectPods(pods)
+		if len(selected) != 1 || selected[0].Name != "web" {
+			t.Errorf("Expected only web to match, got %d pods", len(selected))
+		}
+	})
+
+	t.Run("AggregatedErrors", func(t *testing.T) {
+		_, err := parse(t, `{"nodeRegex": "(", "labelSelector": "app in (", "minEmissions": "lots", "owner": "me"}`)
+
+		if err == nil {
+			t.Fatal("Expected validation error, got nil")
+		}
+		for _, field := range []string{"filters.nodeRegex", "filters.labelSelector", "filters.minEmissions", "filters.owner"} {
+			if !strings.Contains(err.Error(), field) {
+				t.Errorf("Expected error for field %s, got %v", field, err)
+			}
+		}
+	})
+
+	t.Run("ScopeNodes", func(t *testing.T) {
+		f, err := parse(t, `{"zone": "us-west-2b"}`)
+		if err != nil {
+			t.Fatalf("parseFilters failed: %v", err)
+		}
+
+		pods := createTestPods()
+		pods[2].Spec.NodeName = "test-node-2"
+		nodes, scoped := f.scope(createTestNodes(), pods)
+		if len(nodes) != 1 || nodes[0].Name != "test-node-2" {
+			t.Errorf("Expected only test-node-2, got %d nodes", len(nodes))
+		}
+		if len(scoped) != 1 || scoped[0].Name != "test-pod-3" {
+			t.Errorf("Expected only the pod on test-node-2, got %d pods", len(scoped))
+		}
+	})
+}
+
+func TestDatasourceFilterAndGroupBy(t *testing.T) {
+	ctx := context.Background()
+
+	namespaces := []*corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "production"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "development"}},
+	}
+	calculator := NewCarbonCalculator(&CarbonConfig{DefaultGridIntensity: 400, PUE: 1.0})
+	d := newCarbonFootprintDatasource(calculator, NewFakeKubernetesClient(createTestNodes(), createTestPods(), namespaces), nil)
+
+	tests := []struct {
+		name      string
+		queryJSON string
+		wantRows  int
+	}{
+		{"ZoneWithPods", `{"resourceType":"pod","filters":{"zone":"us-west-2a"}}`, 3},
+		{"ZoneWithoutPods", `{"resourceType":"pod","filters":{"zone":"us-west-2b"}}`, 0},
+		{"InstanceType", `{"resourceType":"node","filters":{"instanceType":"m5.xlarge"}}`, 1},
+		{"NodeRegex", `{"resourceType":"node","filters":{"nodeRegex":"test-node-[12]"}}`, 2},
+		{"NamespaceRegex", `{"resourceType":"namespace","filters":{"namespaceRegex":"prod.*"}}`, 1},
+		{"ExactNamespace", `{"resourceType":"pod","filters":{"namespace":"development"}}`, 1},
+		{"LabelSelector", `{"resourceType":"pod","filters":{"labelSelector":"app=test-app,tier!=cache"}}`, 3},
+		{"LabelSelectorExcludes", `{"resourceType":"pod","filters":{"labelSelector":"app!=test-app"}}`, 0},
+		{"NodeLabelSelector", `{"resourceType":"node","filters":{"labelSelector":"topology.kubernetes.io/zone=us-west-2b"}}`, 1},
+		{"MinEmissions", `{"resourceType":"pod","filters":{"minEmissions":1e12}}`, 0},
+		{"GroupByNamespace", `{"resourceType":"pod","groupBy":["namespace"]}`, 2},
+		{"GroupByLabel", `{"resourceType":"pod","groupBy":["app"]}`, 1},
+		{"GroupByNamespaceAndZone", `{"resourceType":"pod","groupBy":["namespace","zone"]}`, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var query map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.queryJSON), &query); err != nil {
+				t.Fatalf("invalid test query: %v", err)
+			}
+			query["refId"] = "A"
+			query["queryType"] = "table"
+			queryJSON, _ := json.Marshal(query)
+
+			res := d.query(ctx, backend.PluginContext{}, backend.DataQuery{RefID: "A", JSON: queryJSON})
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+			rows := 0
+			if len(res.Frames) > 0 {
+				rows = res.Frames[0].Rows()
+			}
+			if rows != tt.wantRows {
+				t.Errorf("Expected %d rows, got %d", tt.wantRows, rows)
+			}
+		})
+	}
+
+	t.Run("InvalidFilter", func(t *testing.T) {
+		res := d.query(ctx, backend.PluginContext{}, backend.DataQuery{
+			RefID: "A",
+			JSON:  []byte(`{"refId":"A","queryType":"table","resourceType":"pod","filters":{"nodeRegex":"("}}`),
+		})
+		if res.Status != backend.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", backend.StatusBadRequest, res.Status)
+		}
+	})
+}
+
+func TestDatasourceLabelSelector(t *testing.T) {
+	ctx := context.Background()
+
+	web := []*corev1.Pod{
+		createOwnedPod("web-7d4b9c-abcde", "production", "ReplicaSet", "web-7d4b9c"),
+		createOwnedPod("web-7d4b9c-fghij", "production", "ReplicaSet", "web-7d4b9c"),
+	}
+	batch := createOwnedPod("report-28374650-abcde", "development", "Job", "report-28374650")
+	for _, pod := range web {
+		pod.Labels = map[string]string{"app": "web"}
+	}
+	batch.Labels = map[string]string{"app": "report"}
+
+	namespaces := []*corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "production"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "development"}},
+	}
+	client := NewFakeKubernetesClient(createTestNodes(), append(web, batch), namespaces)
+	client.ReplicaSets = []*appsv1.ReplicaSet{{ObjectMeta: ownedBy("web-7d4b9c", "production", "Deployment", "web")}}
+	calculator := NewCarbonCalculator(&CarbonConfig{DefaultGridIntensity: 400, PUE: 1.0, IdleAttribution: IdleAttributionProportional})
+	d := newCarbonFootprintDatasource(calculator, client, nil)
+
+	query := func(t *testing.T, resourceType, selector string) *data.Frame {
+		t.Helper()
+		filters := ""
+		if selector != "" {
+			filters = `,"filters":{"labelSelector":"` + selector + `"}`
+		}
+		res := d.query(ctx, backend.PluginContext{}, backend.DataQuery{
+			RefID: "A",
+			JSON:  []byte(`{"refId":"A","queryType":"table","resourceType":"` + resourceType + `"` + filters + `}`),
+		})
+		if res.Error != nil {
+			t.Fatalf("query failed: %v", res.Error)
+		}
+		if len(res.Frames) == 0 {
+			return data.NewFrame("A")
+		}
+		return res.Frames[0]
+	}
+	totalEnergy := func(frame *data.Frame) float64 {
+		field, _ := frame.FieldByName("energy_consumption")
+		var total float64
+		for i := 0; field != nil && i < field.Len(); i++ {
+			total += field.At(i).(float64)
+		}
+		return total
+	}
+
+	webEnergy := totalEnergy(query(t, "pod", "app=web"))
+	if webEnergy == 0 {
+		t.Fatal("Expected energy for the web pods")
+	}
+
+	t.Run("Workload", func(t *testing.T) {
+		frame := query(t, "workload", "app=web")
+		if frame.Rows() != 1 || frame.Fields[0].At(0) != "web" {
+			t.Fatalf("Expected only the web deployment, got %d rows", frame.Rows())
+		}
+		if abs(totalEnergy(frame)-webEnergy) > 1e-12 {
+			t.Errorf("Expected %f kWh of the web pods, got %f", webEnergy, totalEnergy(frame))
+		}
+	})
+
+	t.Run("Namespace", func(t *testing.T) {
+		frame := query(t, "namespace", "app=web")
+		if frame.Rows() != 1 || frame.Fields[0].At(0) != "production" {
+			t.Fatalf("Expected only production, got %d rows", frame.Rows())
+		}
+		if abs(totalEnergy(frame)-webEnergy) > 1e-12 {
+			t.Errorf("Expected %f kWh of the web pods, got %f", webEnergy, totalEnergy(frame))
+		}
+	})
+
+	t.Run("Team", func(t *testing.T) {
+		if frame := query(t, "team", "app=web"); abs(totalEnergy(frame)-webEnergy) > 1e-12 {
+			t.Errorf("Expected %f kWh of the web pods, got %f", webEnergy, totalEnergy(frame))
+		}
+	})
+
+	t.Run("Cluster", func(t *testing.T) {
+		frame := query(t, "cluster", "app=web")
+		if frame.Rows() != 1 || frame.Fields[0].At(0) != "cluster" {
+			t.Fatalf("Expected the cluster row, got %d rows", frame.Rows())
+		}
+		if abs(totalEnergy(frame)-webEnergy) > 1e-12 {
+			t.Errorf("Expected %f kWh of the web pods, got %f", webEnergy, totalEnergy(frame))
+		}
+		if whole := totalEnergy(query(t, "cluster", "")); whole <= webEnergy {
+			t.Errorf("Expected the whole cluster to consume more than the web pods, got %f and %f", whole, webEnergy)
+		}
+	})
+}
@@ -0,0 +1,250 @@
+package carbon
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Grid intensity providers
+const (
+	GridProviderStatic          = "static"
+	GridProviderElectricityMaps = "electricitymaps"
+	GridProviderWattTime        = "watttime"
+	// GridProviderUKCarbonIntensity is the keyless National Grid ESO Carbon Intensity API
+	GridProviderUKCarbonIntensity = "ukcarbonintensity"
+	// GridProviderFile reads intensity from a local file, for clusters without internet egress
+	GridProviderFile = "file"
+)
+
+// Grid intensity bases
+const (
+	// IntensityBasisAverage is the average emissions of all generation on the grid
+	IntensityBasisAverage = "average"
+	// IntensityBasisMarginal is the emissions of the generator responding to a change in load
+	IntensityBasisMarginal = "marginal"
+
+	// intensityBasisMixed marks rollups combining values of different bases, e.g. after a fallback
+	intensityBasisMixed = "mixed"
+)
+
+// failedRangeTTL is how long a failed bulk load is remembered, so the steps of a query don't retry it one by one
+const failedRangeTTL = time.Minute
+
+// errRateLimited is returned while a provider is backing off after hitting its rate limit
+var errRateLimited = errors.New("grid intensity provider is rate limited")
+
+// GridIntensityProvider supplies the carbon intensity of electricity for a grid zone
+type GridIntensityProvider interface {
+	// GetGridIntensity returns the intensity for zone at the given time.
+	// A zero time means the latest available value.
+	GetGridIntensity(ctx context.Context, zone string, at time.Time) (*IntensityDataPoint, error)
+
+	// Name returns the provider identifier, one of the GridProvider constants
+	Name() string
+}
+
+// GridIntensityRangeLoader is implemented by providers that can fetch the history of a zone in bulk.
+// The calculator loads the time range of a query up front, so that the lookups of every step that
+// follow are served from the provider's cache rather than with one upstream request each.
+type GridIntensityRangeLoader interface {
+	LoadRange(ctx context.Context, zone string, from, to time.Time) error
+}
+
+// GridIntensityForecaster is implemented by providers that publish forecasts
+type GridIntensityForecaster interface {
+	GetForecast(ctx context.Context, zone string, from time.Time) ([]*IntensityDataPoint, error)
+}
+
+// IntensityDataPoint is a grid carbon intensity observation
+type IntensityDataPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Zone      string    `json:"zone"`
+	Intensity float64   `json:"intensity"` // gCO2/kWh
+	Basis     string    `json:"basis"`     // "average" or "marginal"
+	Source    string    `json:"source"`
+}
+
+// NewGridIntensityProvider creates the provider selected in the carbon configuration.
+// Without an explicit choice, marginal intensity is served by WattTime and average
+// intensity by Electricity Maps when an API key is configured.
+func NewGridIntensityProvider(config *CarbonConfig) GridIntensityProvider {
+	apiKey := electricityMapsAPIKey(config)
+	provider := config.GridIntensityProvider
+	if provider == "" && config.IntensityBasis == IntensityBasisMarginal {
+		provider = GridProviderWattTime
+	} else if provider == "" && apiKey != "" {
+		provider = GridProviderElectricityMaps
+	}
+
+	switch provider {
+	case GridProviderWattTime:
+		return NewWattTimeProvider(config.WattTimeUsername, config.WattTimePassword)
+	case GridProviderElectricityMaps:
+		return NewElectricityMapsProvider(apiKey)
+	case GridProviderUKCarbonIntensity:
+		return NewUKCarbonIntensityProvider()
+	case GridProviderFile:
+		return NewFileGridIntensityProvider(config.GridIntensityFile)
+	default:
+		return NewStaticGridIntensityProvider(config.DefaultGridIntensity)
+	}
+}
+
+// electricityMapsAPIKey prefers the dedicated key and falls back to the generic grid intensity key
+func electricityMapsAPIKey(config *CarbonConfig) string {
+	if config.ElectricityMapsAPIKey != "" {
+		return config.ElectricityMapsAPIKey
+	}
+	return config.GridIntensityAPIKey
+}
+
+// staticGridIntensityProvider returns the same intensity for every zone and time
+type staticGridIntensityProvider struct {
+	intensity float64
+}
+
+// NewStaticGridIntensityProvider creates a provider that always returns intensity
+func NewStaticGridIntensityProvider(intensity float64) GridIntensityProvider {
+	return &staticGridIntensityProvider{intensity: intensity}
+}
+
+// GetGridIntensity returns the configured intensity
+func (s *staticGridIntensityProvider) GetGridIntensity(ctx context.Context, zone string, at time.Time) (*IntensityDataPoint, error) {
+	if at.IsZero() {
+		at = time.Now()
+	}
+	return &IntensityDataPoint{
+		Timestamp: at,
+		Zone:      zone,
+		Intensity: s.intensity,
+		Basis:     IntensityBasisAverage,
+		Source:    GridProviderStatic,
+	}, nil
+}
+
+// Name returns the provider identifier
+func (s *staticGridIntensityProvider) Name() string {
+	return GridProviderStatic
+}
+
+// intensityCache holds provider responses until they expire, along with the ranges loaded in bulk
+type intensityCache struct {
+	mu      sync.Mutex
+	entries map[string]intensityCacheEntry
+	ranges  map[string]intensityRangeEntry
+}
+
+type intensityCacheEntry struct {
+	point   *IntensityDataPoint
+	expires time.Time
+}
+
+type intensityRangeEntry struct {
+	err     error
+	expires time.Time
+}
+
+func newIntensityCache() *intensityCache {
+	return &intensityCache{
+		entries: make(map[string]intensityCacheEntry),
+		ranges:  make(map[string]intensityRangeEntry),
+	}
+}
+
+// get returns a cached point; stale entries are returned with fresh set to false
+func (c *intensityCache) get(key string) (point *IntensityDataPoint, fresh bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	return entry.point, time.Now().Before(entry.expires)
+}
+
+func (c *intensityCache) set(key string, point *IntensityDataPoint, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = intensityCacheEntry{point: point, expires: time.Now().Add(ttl)}
+}
+
+// rangeLoaded reports whether the range key was loaded recently, and the error if loading it failed
+func (c *intensityCache) rangeLoaded(key string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.ranges[key]
+	if !ok || !time.Now().Before(entry.expires) {
+		return false, nil
+	}
+	return true, entry.err
+}
+
+// setRangeLoaded records the outcome of loading the range key. Failures are kept for
+// failedRangeTTL only, successes for ttl.
+func (c *intensityCache) setRangeLoaded(key string, err error, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		ttl = failedRangeTTL
+	}
+	c.ranges[key] = intensityRangeEntry{err: err, expires: time.Now().Add(ttl)}
+}
+
+// rateLimiter spaces out requests and backs off when the upstream API says so
+type rateLimiter struct {
+	mu           sync.Mutex
+	interval     time.Duration
+	next         time.Time
+	blockedUntil time.Time
+}
+
+func newRateLimiter(requestsPerSecond float64) *rateLimiter {
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / requestsPerSecond)}
+}
+
+// wait reserves the next request slot. It fails fast with errRateLimited while backing
+// off so a Grafana query is never held up for the length of an upstream penalty.
+func (r *rateLimiter) wait(ctx context.Context) error {
+	r.mu.Lock()
+	now := time.Now()
+	if now.Before(r.blockedUntil) {
+		r.mu.Unlock()
+		return errRateLimited
+	}
+	slot := r.next
+	if slot.Before(now) {
+		slot = now
+	}
+	r.next = slot.Add(r.interval)
+	r.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// backoff blocks all requests for d
+func (r *rateLimiter) backoff(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if until := time.Now().Add(d); until.After(r.blockedUntil) {
+		r.blockedUntil = until
+	}
+}
@@ -0,0 +1,130 @@
+package carbon
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Idle attribution modes decide who pays for node energy not explained by its pods:
+// the idle baseline and capacity nobody requested or used
+const (
+	// IdleAttributionNone leaves idle energy unattributed, pod and namespace sums stay below cluster totals
+	IdleAttributionNone = "none"
+	// IdleAttributionProportional spreads idle energy over the pods of each node by their share of its energy
+	IdleAttributionProportional = "proportional"
+	// IdleAttributionNamespace charges idle energy to the synthetic IdleNamespace
+	IdleAttributionNamespace = "namespace"
+)
+
+// IdleNamespace is the synthetic namespace idle energy is charged to. Nodes without pods charge it
+// in the proportional mode too, as there is nobody to spread their energy over.
+const IdleNamespace = "__idle__"
+
+// nodeAttribution splits one step of a node's energy between its pods and idle capacity
+type nodeAttribution struct {
+	scale      float64 // factor applied to the energy of every pod on the node
+	idle       float64 // kWh charged to IdleNamespace, before PUE
+	idleSource string  // Metrics.Source of the idle energy
+}
+
+// attributesIdle reports whether idle energy is attributed at all
+func (c *carbonCalculator) attributesIdle() bool {
+	return c.config.IdleAttribution == IdleAttributionProportional || c.config.IdleAttribution == IdleAttributionNamespace
+}
+
+// attributeNode splits the energy of node in each step of window between the pods running on it
+// and idle capacity. pods may hold pods of other nodes, they are ignored.
+func (c *carbonCalculator) attributeNode(ctx context.Context, node *corev1.Node, pods []*corev1.Pod, window TimeWindow) ([]nodeAttribution, error) {
+	nodeSteps, err := c.calculateNodeEnergyConsumption(ctx, node, pods, window)
+	if err != nil {
+		return nil, err
+	}
+
+	podEnergy := make([]float64, len(nodeSteps))
+	for _, pod := range pods {
+		if pod.Spec.NodeName != node.Name {
+			continue
+		}
+		podSteps, err := c.calculatePodEnergyConsumption(ctx, pod, node, window)
+		if err != nil {
+			continue
+		}
+		for i, step := range podSteps {
+			podEnergy[i] += step.energy
+		}
+	}
+
+	attribution := make([]nodeAttribution, len(nodeSteps))
+	for i, step := range nodeSteps {
+		attribution[i] = attributeStep(c.config.IdleAttribution, step.energy, podEnergy[i])
+		attribution[i].idleSource = step.source()
+	}
+	return attribution, nil
+}
+
+// attributeStep splits nodeEnergy between pods consuming podEnergy in total and idle capacity.
+// Pods are scaled down when they add up to more than the node, so sums never exceed it.
+func attributeStep(mode string, nodeEnergy, podEnergy float64) nodeAttribution {
+	switch mode {
+	case IdleAttributionProportional:
+		if podEnergy > 0 {
+			return nodeAttribution{scale: nodeEnergy / podEnergy}
+		}
+		return nodeAttribution{scale: 1, idle: nodeEnergy}
+	case IdleAttributionNamespace:
+		if podEnergy > nodeEnergy {
+			return nodeAttribution{scale: nodeEnergy / podEnergy}
+		}
+		return nodeAttribution{scale: 1, idle: nodeEnergy - podEnergy}
+	default:
+		return nodeAttribution{scale: 1}
+	}
+}
+
+// NodeAttributions holds how the energy of every node splits between its pods and idle capacity
+// in each step of a window. It is computed once per query and shared by all resources of the query.
+// A nil NodeAttributions leaves idle energy unattributed.
+type NodeAttributions struct {
+	byNode map[string][]nodeAttribution
+}
+
+// of returns the attribution of the node named nodeName, nil when there is none
+func (a *NodeAttributions) of(nodeName string) []nodeAttribution {
+	if a == nil {
+		return nil
+	}
+	return a.byNode[nodeName]
+}
+
+// AttributeNodes splits the energy of each of nodes in each step of window between the pods
+// running on it and idle capacity. It returns nil when idle energy is not attributed.
+func (c *carbonCalculator) AttributeNodes(ctx context.Context, nodes []*corev1.Node, pods []*corev1.Pod, window TimeWindow) *NodeAttributions {
+	if !c.attributesIdle() {
+		return nil
+	}
+
+	podsByNode := make(map[string][]*corev1.Pod)
+	for _, pod := range pods {
+		podsByNode[pod.Spec.NodeName] = append(podsByNode[pod.Spec.NodeName], pod)
+	}
+
+	attributions := &NodeAttributions{byNode: make(map[string][]nodeAttribution, len(nodes))}
+	for _, node := range nodes {
+		// Nodes that can't be attributed keep their pods' energy unscaled
+		attribution, err := c.attributeNode(ctx, node, podsByNode[node.Name], window)
+		if err != nil {
+			continue
+		}
+		attributions.byNode[node.Name] = attribution
+	}
+	return attributions
+}
+
+// scaleAt returns the pod energy factor of step i, 1 when there is no attribution
+func scaleAt(attribution []nodeAttribution, i int) float64 {
+	if i >= len(attribution) {
+		return 1
+	}
+	return attribution[i].scale
+}
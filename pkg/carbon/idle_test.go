@@ -0,0 +1,154 @@
+package carbon
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAttributeStep(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       string
+		nodeEnergy float64
+		podEnergy  float64
+		wantScale  float64
+		wantIdle   float64
+	}{
+		{"NoneKeepsPods", IdleAttributionNone, 10, 4, 1, 0},
+		{"ProportionalScalesUp", IdleAttributionProportional, 10, 4, 2.5, 0},
+		{"ProportionalWithoutPods", IdleAttributionProportional, 10, 0, 1, 10},
+		{"NamespaceChargesRemainder", IdleAttributionNamespace, 10, 4, 1, 6},
+		{"NamespaceScalesDown", IdleAttributionNamespace, 10, 20, 0.5, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := attributeStep(tt.mode, tt.nodeEnergy, tt.podEnergy)
+			if got.scale != tt.wantScale || got.idle != tt.wantIdle {
+				t.Errorf("Expected scale %f and idle %f, got %f and %f", tt.wantScale, tt.wantIdle, got.scale, got.idle)
+			}
+		})
+	}
+}
+
+func TestIdleAttributionReconciles(t *testing.T) {
+	ctx := context.Background()
+	nodes := createTestNodes()
+	pods := createTestPods()
+	namespaces := []*corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "production"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "development"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: IdleNamespace}},
+	}
+	window := defaultWindow(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	for _, mode := range []string{IdleAttributionProportional, IdleAttributionNamespace} {
+		t.Run(mode, func(t *testing.T) {
+			calculator := NewCarbonCalculator(&CarbonConfig{DefaultGridIntensity: 400, PUE: 1.2, IdleAttribution: mode})
+
+			cluster, err := calculator.CalculateClusterCarbon(ctx, nodes, pods, window)
+			if err != nil {
+				t.Fatalf("CalculateClusterCarbon failed: %v", err)
+			}
+			want := cluster[0].EnergyConsumption
+			attributions := calculator.AttributeNodes(ctx, nodes, pods, window)
+
+			var namespaceSum float64
+			for _, namespace := range namespaces {
+				metrics, err := calculator.CalculateNamespaceCarbon(ctx, namespace, nodes, pods, attributions, window)
+				if err != nil {
+					t.Fatalf("CalculateNamespaceCarbon failed: %v", err)
+				}
+				namespaceSum += metrics[0].EnergyConsumption
+			}
+			if abs(namespaceSum-want) > 1e-9 {
+				t.Errorf("Expected namespaces to add up to cluster energy %f, got %f", want, namespaceSum)
+			}
+
+			var podSum float64
+			for _, pod := range pods {
+				metrics, err := calculator.CalculatePodCarbon(ctx, pod, indexNodes(nodes)[pod.Spec.NodeName], attributions, window)
+				if err != nil {
+					t.Fatalf("CalculatePodCarbon failed: %v", err)
+				}
+				podSum += metrics[0].EnergyConsumption
+			}
+			for _, node := range nodes {
+				metrics, err := calculator.CalculateIdleCarbon(ctx, node, attributions, window)
+				if err != nil {
+					t.Fatalf("CalculateIdleCarbon failed: %v", err)
+				}
+				podSum += metrics[0].EnergyConsumption
+			}
+			if abs(podSum-want) > 1e-9 {
+				t.Errorf("Expected pods and idle energy to add up to cluster energy %f, got %f", want, podSum)
+			}
+		})
+	}
+
+	t.Run("NoneLeavesIdleUnattributed", func(t *testing.T) {
+		calculator := NewCarbonCalculator(&CarbonConfig{DefaultGridIntensity: 400, PUE: 1.2, IdleAttribution: IdleAttributionNone})
+
+		attributions := calculator.AttributeNodes(ctx, nodes, pods, window)
+		if attributions != nil {
+			t.Errorf("Expected no attributions, got %v", attributions)
+		}
+
+		metrics, err := calculator.CalculateNamespaceCarbon(ctx, namespaces[2], nodes, pods, attributions, window)
+		if err != nil || metrics != nil {
+			t.Errorf("Expected no idle namespace, got %v, %v", metrics, err)
+		}
+		metrics, err = calculator.CalculateIdleCarbon(ctx, nodes[0], attributions, window)
+		if err != nil || metrics != nil {
+			t.Errorf("Expected no idle energy, got %v, %v", metrics, err)
+		}
+	})
+}
+
+// countingUtilizationSource counts usage lookups and reports no data
+type countingUtilizationSource struct {
+	nodeLookups int32
+}
+
+func (s *countingUtilizationSource) PodUsage(ctx context.Context, pod *corev1.Pod, window TimeWindow) ([]UsageSample, error) {
+	return nil, nil
+}
+
+func (s *countingUtilizationSource) NodeUsage(ctx context.Context, node *corev1.Node, window TimeWindow) ([]UsageSample, error) {
+	atomic.AddInt32(&s.nodeLookups, 1)
+	return nil, nil
+}
+
+func TestAttributeNodesOncePerQuery(t *testing.T) {
+	ctx := context.Background()
+	nodes := createTestNodes()
+	pods := createTestPods()
+	window := defaultWindow(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	source := &countingUtilizationSource{}
+	calculator := NewCarbonCalculator(&CarbonConfig{DefaultGridIntensity: 400, PUE: 1.2, IdleAttribution: IdleAttributionProportional},
+		WithUtilizationSource(source))
+
+	attributions := calculator.AttributeNodes(ctx, nodes, pods, window)
+	for _, name := range []string{"production", "development", IdleNamespace} {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if _, err := calculator.CalculateNamespaceCarbon(ctx, namespace, nodes, pods, attributions, window); err != nil {
+			t.Fatalf("CalculateNamespaceCarbon failed: %v", err)
+		}
+	}
+	for _, pod := range pods {
+		if _, err := calculator.CalculatePodCarbon(ctx, pod, indexNodes(nodes)[pod.Spec.NodeName], attributions, window); err != nil {
+			t.Fatalf("CalculatePodCarbon failed: %v", err)
+		}
+	}
+
+	// Namespaces and pods reuse the attributions instead of measuring their nodes again
+	if source.nodeLookups != int32(len(nodes)) {
+		t.Errorf("Expected %d node lookups, got %d", len(nodes), source.nodeLookups)
+	}
+}
@@ -0,0 +1,90 @@
+// Code generated by instancegen from data/instance_types.csv. DO NOT EDIT.
+
+package carbon
+
+var instanceCatalog = []InstanceSpecs{
+	{Cloud: "aws", InstanceType: "c5.2xlarge", VCPUs: 8, MemoryGB: 16, Microarchitecture: "Skylake", MinWatts: 5.2, MaxWatts: 34.08, GPUs: 0},
+	{Cloud: "aws", InstanceType: "c5.4xlarge", VCPUs: 16, MemoryGB: 32, Microarchitecture: "Skylake", MinWatts: 10.4, MaxWatts: 68.16, GPUs: 0},
+	{Cloud: "aws", InstanceType: "c5.large", VCPUs: 2, MemoryGB: 4, Microarchitecture: "Skylake", MinWatts: 1.3, MaxWatts: 8.52, GPUs: 0},
+	{Cloud: "aws", InstanceType: "c5.xlarge", VCPUs: 4, MemoryGB: 8, Microarchitecture: "Skylake", MinWatts: 2.6, MaxWatts: 17.04, GPUs: 0},
+	{Cloud: "aws", InstanceType: "c6g.2xlarge", VCPUs: 8, MemoryGB: 16, Microarchitecture: "Graviton2", MinWatts: 3.76, MaxWatts: 13.52, GPUs: 0},
+	{Cloud: "aws", InstanceType: "c6g.large", VCPUs: 2, MemoryGB: 4, Microarchitecture: "Graviton2", MinWatts: 0.94, MaxWatts: 3.38, GPUs: 0},
+	{Cloud: "aws", InstanceType: "c6g.xlarge", VCPUs: 4, MemoryGB: 8, Microarchitecture: "Graviton2", MinWatts: 1.88, MaxWatts: 6.76, GPUs: 0},
+	{Cloud: "aws", InstanceType: "c6i.2xlarge", VCPUs: 8, MemoryGB: 16, Microarchitecture: "Ice Lake", MinWatts: 5.12, MaxWatts: 31.76, GPUs: 0},
+	{Cloud: "aws", InstanceType: "c6i.large", VCPUs: 2, MemoryGB: 4, Microarchitecture: "Ice Lake", MinWatts: 1.28, MaxWatts: 7.94, GPUs: 0},
+	{Cloud: "aws", InstanceType: "c6i.xlarge", VCPUs: 4, MemoryGB: 8, Microarchitecture: "Ice Lake", MinWatts: 2.56, MaxWatts: 15.88, GPUs: 0},
+	{Cloud: "aws", InstanceType: "g4dn.xlarge", VCPUs: 4, MemoryGB: 16, Microarchitecture: "Cascade Lake", MinWatts: 2.56, MaxWatts: 15.88, GPUs: 1},
+	{Cloud: "aws", InstanceType: "g5.xlarge", VCPUs: 4, MemoryGB: 16, Microarchitecture: "EPYC 2nd Gen", MinWatts: 1.88, MaxWatts: 6.56, GPUs: 1},
+	{Cloud: "aws", InstanceType: "m5.12xlarge", VCPUs: 48, MemoryGB: 192, Microarchitecture: "Skylake", MinWatts: 31.2, MaxWatts: 204.48, GPUs: 0},
+	{Cloud: "aws", InstanceType: "m5.16xlarge", VCPUs: 64, MemoryGB: 256, Microarchitecture: "Skylake", MinWatts: 41.6, MaxWatts: 272.64, GPUs: 0},
+	{Cloud: "aws", InstanceType: "m5.24xlarge", VCPUs: 96, MemoryGB: 384, Microarchitecture: "Skylake", MinWatts: 62.4, MaxWatts: 408.96, GPUs: 0},
+	{Cloud: "aws", InstanceType: "m5.2xlarge", VCPUs: 8, MemoryGB: 32, Microarchitecture: "Skylake", MinWatts: 5.2, MaxWatts: 34.08, GPUs: 0},
+	{Cloud: "aws", InstanceType: "m5.4xlarge", VCPUs: 16, MemoryGB: 64, Microarchitecture: "Skylake", MinWatts: 10.4, MaxWatts: 68.16, GPUs: 0},
+	{Cloud: "aws", InstanceType: "m5.8xlarge", VCPUs: 32, MemoryGB: 128, Microarchitecture: "Skylake", MinWatts: 20.8, MaxWatts: 136.32, GPUs: 0},
+	{Cloud: "aws", InstanceType: "m5.large", VCPUs: 2, MemoryGB: 8, Microarchitecture: "Skylake", MinWatts: 1.3, MaxWatts: 8.52, GPUs: 0},
+	{Cloud: "aws", InstanceType: "m5.xlarge", VCPUs: 4, MemoryGB: 16, Microarchitecture: "Skylake", MinWatts: 2.6, MaxWatts: 17.04, GPUs: 0},
+	{Cloud: "aws", InstanceType: "m6a.2xlarge", VCPUs: 8, MemoryGB: 32, Microarchitecture: "EPYC 3rd Gen", MinWatts: 3.6, MaxWatts: 16.16, GPUs: 0},
+	{Cloud: "aws", InstanceType: "m6a.large", VCPUs: 2, MemoryGB: 8, Microarchitecture: "EPYC 3rd Gen", MinWatts: 0.9, MaxWatts: 4.04, GPUs: 0},
+	{Cloud: "aws", InstanceType: "m6a.xlarge", VCPUs: 4, MemoryGB: 16, Microarchitecture: "EPYC 3rd Gen", MinWatts: 1.8, MaxWatts: 8.08, GPUs: 0},
+	{Cloud: "aws", InstanceType: "m6g.2xlarge", VCPUs: 8, MemoryGB: 32, Microarchitecture: "Graviton2", MinWatts: 3.76, MaxWatts: 13.52, GPUs: 0},
+	{Cloud: "aws", InstanceType: "m6g.large", VCPUs: 2, MemoryGB: 8, Microarchitecture: "Graviton2", MinWatts: 0.94, MaxWatts: 3.38, GPUs: 0},
+	{Cloud: "aws", InstanceType: "m6g.xlarge", VCPUs: 4, MemoryGB: 16, Microarchitecture: "Graviton2", MinWatts: 1.88, MaxWatts: 6.76, GPUs: 0},
+	{Cloud: "aws", InstanceType: "m6i.2xlarge", VCPUs: 8, MemoryGB: 32, Microarchitecture: "Ice Lake", MinWatts: 5.12, MaxWatts: 31.76, GPUs: 0},
+	{Cloud: "aws", InstanceType: "m6i.4xlarge", VCPUs: 16, MemoryGB: 64, Microarchitecture: "Ice Lake", MinWatts: 10.24, MaxWatts: 63.52, GPUs: 0},
+	{Cloud: "aws", InstanceType: "m6i.large", VCPUs: 2, MemoryGB: 8, Microarchitecture: "Ice Lake", MinWatts: 1.28, MaxWatts: 7.94, GPUs: 0},
+	{Cloud: "aws", InstanceType: "m6i.xlarge", VCPUs: 4, MemoryGB: 16, Microarchitecture: "Ice Lake", MinWatts: 2.56, MaxWatts: 15.88, GPUs: 0},
+	{Cloud: "aws", InstanceType: "m7g.large", VCPUs: 2, MemoryGB: 8, Microarchitecture: "Graviton3", MinWatts: 0.94, MaxWatts: 3.38, GPUs: 0},
+	{Cloud: "aws", InstanceType: "m7g.xlarge", VCPUs: 4, MemoryGB: 16, Microarchitecture: "Graviton3", MinWatts: 1.88, MaxWatts: 6.76, GPUs: 0},
+	{Cloud: "aws", InstanceType: "p3.2xlarge", VCPUs: 8, MemoryGB: 61, Microarchitecture: "Broadwell", MinWatts: 5.68, MaxWatts: 29.52, GPUs: 1},
+	{Cloud: "aws", InstanceType: "r5.2xlarge", VCPUs: 8, MemoryGB: 64, Microarchitecture: "Skylake", MinWatts: 5.2, MaxWatts: 34.08, GPUs: 0},
+	{Cloud: "aws", InstanceType: "r5.4xlarge", VCPUs: 16, MemoryGB: 128, Microarchitecture: "Skylake", MinWatts: 10.4, MaxWatts: 68.16, GPUs: 0},
+	{Cloud: "aws", InstanceType: "r5.large", VCPUs: 2, MemoryGB: 16, Microarchitecture: "Skylake", MinWatts: 1.3, MaxWatts: 8.52, GPUs: 0},
+	{Cloud: "aws", InstanceType: "r5.xlarge", VCPUs: 4, MemoryGB: 32, Microarchitecture: "Skylake", MinWatts: 2.6, MaxWatts: 17.04, GPUs: 0},
+	{Cloud: "aws", InstanceType: "r6i.large", VCPUs: 2, MemoryGB: 16, Microarchitecture: "Ice Lake", MinWatts: 1.28, MaxWatts: 7.94, GPUs: 0},
+	{Cloud: "aws", InstanceType: "r6i.xlarge", VCPUs: 4, MemoryGB: 32, Microarchitecture: "Ice Lake", MinWatts: 2.56, MaxWatts: 15.88, GPUs: 0},
+	{Cloud: "aws", InstanceType: "t3.large", VCPUs: 2, MemoryGB: 8, Microarchitecture: "Skylake", MinWatts: 1.3, MaxWatts: 8.52, GPUs: 0},
+	{Cloud: "aws", InstanceType: "t3.medium", VCPUs: 2, MemoryGB: 4, Microarchitecture: "Skylake", MinWatts: 1.3, MaxWatts: 8.52, GPUs: 0},
+	{Cloud: "aws", InstanceType: "t3.micro", VCPUs: 2, MemoryGB: 1, Microarchitecture: "Skylake", MinWatts: 1.3, MaxWatts: 8.52, GPUs: 0},
+	{Cloud: "aws", InstanceType: "t3.small", VCPUs: 2, MemoryGB: 2, Microarchitecture: "Skylake", MinWatts: 1.3, MaxWatts: 8.52, GPUs: 0},
+	{Cloud: "aws", InstanceType: "t3.xlarge", VCPUs: 4, MemoryGB: 16, Microarchitecture: "Skylake", MinWatts: 2.6, MaxWatts: 17.04, GPUs: 0},
+	{Cloud: "aws", InstanceType: "t3a.large", VCPUs: 2, MemoryGB: 8, Microarchitecture: "EPYC 1st Gen", MinWatts: 1.64, MaxWatts: 5.1, GPUs: 0},
+	{Cloud: "aws", InstanceType: "t3a.medium", VCPUs: 2, MemoryGB: 4, Microarchitecture: "EPYC 1st Gen", MinWatts: 1.64, MaxWatts: 5.1, GPUs: 0},
+	{Cloud: "aws", InstanceType: "t4g.large", VCPUs: 2, MemoryGB: 8, Microarchitecture: "Graviton2", MinWatts: 0.94, MaxWatts: 3.38, GPUs: 0},
+	{Cloud: "aws", InstanceType: "t4g.medium", VCPUs: 2, MemoryGB: 4, Microarchitecture: "Graviton2", MinWatts: 0.94, MaxWatts: 3.38, GPUs: 0},
+	{Cloud: "azure", InstanceType: "Standard_B2ms", VCPUs: 2, MemoryGB: 8, Microarchitecture: "Broadwell", MinWatts: 1.42, MaxWatts: 7.38, GPUs: 0},
+	{Cloud: "azure", InstanceType: "Standard_B2s", VCPUs: 2, MemoryGB: 4, Microarchitecture: "Broadwell", MinWatts: 1.42, MaxWatts: 7.38, GPUs: 0},
+	{Cloud: "azure", InstanceType: "Standard_D16s_v3", VCPUs: 16, MemoryGB: 64, Microarchitecture: "Skylake", MinWatts: 10.4, MaxWatts: 68.16, GPUs: 0},
+	{Cloud: "azure", InstanceType: "Standard_D2as_v5", VCPUs: 2, MemoryGB: 8, Microarchitecture: "EPYC 3rd Gen", MinWatts: 0.9, MaxWatts: 4.04, GPUs: 0},
+	{Cloud: "azure", InstanceType: "Standard_D2s_v3", VCPUs: 2, MemoryGB: 8, Microarchitecture: "Skylake", MinWatts: 1.3, MaxWatts: 8.52, GPUs: 0},
+	{Cloud: "azure", InstanceType: "Standard_D2s_v5", VCPUs: 2, MemoryGB: 8, Microarchitecture: "Ice Lake", MinWatts: 1.28, MaxWatts: 7.94, GPUs: 0},
+	{Cloud: "azure", InstanceType: "Standard_D4as_v5", VCPUs: 4, MemoryGB: 16, Microarchitecture: "EPYC 3rd Gen", MinWatts: 1.8, MaxWatts: 8.08, GPUs: 0},
+	{Cloud: "azure", InstanceType: "Standard_D4s_v3", VCPUs: 4, MemoryGB: 16, Microarchitecture: "Skylake", MinWatts: 2.6, MaxWatts: 17.04, GPUs: 0},
+	{Cloud: "azure", InstanceType: "Standard_D4s_v5", VCPUs: 4, MemoryGB: 16, Microarchitecture: "Ice Lake", MinWatts: 2.56, MaxWatts: 15.88, GPUs: 0},
+	{Cloud: "azure", InstanceType: "Standard_D8s_v3", VCPUs: 8, MemoryGB: 32, Microarchitecture: "Skylake", MinWatts: 5.2, MaxWatts: 34.08, GPUs: 0},
+	{Cloud: "azure", InstanceType: "Standard_D8s_v5", VCPUs: 8, MemoryGB: 32, Microarchitecture: "Ice Lake", MinWatts: 5.12, MaxWatts: 31.76, GPUs: 0},
+	{Cloud: "azure", InstanceType: "Standard_E2s_v3", VCPUs: 2, MemoryGB: 16, Microarchitecture: "Skylake", MinWatts: 1.3, MaxWatts: 8.52, GPUs: 0},
+	{Cloud: "azure", InstanceType: "Standard_E4s_v3", VCPUs: 4, MemoryGB: 32, Microarchitecture: "Skylake", MinWatts: 2.6, MaxWatts: 17.04, GPUs: 0},
+	{Cloud: "azure", InstanceType: "Standard_E8s_v3", VCPUs: 8, MemoryGB: 64, Microarchitecture: "Skylake", MinWatts: 5.2, MaxWatts: 34.08, GPUs: 0},
+	{Cloud: "azure", InstanceType: "Standard_F2s_v2", VCPUs: 2, MemoryGB: 4, Microarchitecture: "Cascade Lake", MinWatts: 1.28, MaxWatts: 7.94, GPUs: 0},
+	{Cloud: "azure", InstanceType: "Standard_F4s_v2", VCPUs: 4, MemoryGB: 8, Microarchitecture: "Cascade Lake", MinWatts: 2.56, MaxWatts: 15.88, GPUs: 0},
+	{Cloud: "azure", InstanceType: "Standard_F8s_v2", VCPUs: 8, MemoryGB: 16, Microarchitecture: "Cascade Lake", MinWatts: 5.12, MaxWatts: 31.76, GPUs: 0},
+	{Cloud: "azure", InstanceType: "Standard_NC6s_v3", VCPUs: 6, MemoryGB: 112, Microarchitecture: "Broadwell", MinWatts: 4.26, MaxWatts: 22.14, GPUs: 1},
+	{Cloud: "gcp", InstanceType: "a2-highgpu-1g", VCPUs: 12, MemoryGB: 85, Microarchitecture: "Cascade Lake", MinWatts: 7.68, MaxWatts: 47.64, GPUs: 1},
+	{Cloud: "gcp", InstanceType: "c2-standard-4", VCPUs: 4, MemoryGB: 16, Microarchitecture: "Cascade Lake", MinWatts: 2.56, MaxWatts: 15.88, GPUs: 0},
+	{Cloud: "gcp", InstanceType: "c2-standard-8", VCPUs: 8, MemoryGB: 32, Microarchitecture: "Cascade Lake", MinWatts: 5.12, MaxWatts: 31.76, GPUs: 0},
+	{Cloud: "gcp", InstanceType: "e2-medium", VCPUs: 2, MemoryGB: 4, Microarchitecture: "Skylake", MinWatts: 1.3, MaxWatts: 8.52, GPUs: 0},
+	{Cloud: "gcp", InstanceType: "e2-standard-2", VCPUs: 2, MemoryGB: 8, Microarchitecture: "Skylake", MinWatts: 1.3, MaxWatts: 8.52, GPUs: 0},
+	{Cloud: "gcp", InstanceType: "e2-standard-4", VCPUs: 4, MemoryGB: 16, Microarchitecture: "Skylake", MinWatts: 2.6, MaxWatts: 17.04, GPUs: 0},
+	{Cloud: "gcp", InstanceType: "e2-standard-8", VCPUs: 8, MemoryGB: 32, Microarchitecture: "Skylake", MinWatts: 5.2, MaxWatts: 34.08, GPUs: 0},
+	{Cloud: "gcp", InstanceType: "n1-standard-1", VCPUs: 1, MemoryGB: 3.75, Microarchitecture: "Skylake", MinWatts: 0.65, MaxWatts: 4.26, GPUs: 0},
+	{Cloud: "gcp", InstanceType: "n1-standard-2", VCPUs: 2, MemoryGB: 7.5, Microarchitecture: "Skylake", MinWatts: 1.3, MaxWatts: 8.52, GPUs: 0},
+	{Cloud: "gcp", InstanceType: "n1-standard-4", VCPUs: 4, MemoryGB: 15, Microarchitecture: "Skylake", MinWatts: 2.6, MaxWatts: 17.04, GPUs: 0},
+	{Cloud: "gcp", InstanceType: "n1-standard-8", VCPUs: 8, MemoryGB: 30, Microarchitecture: "Skylake", MinWatts: 5.2, MaxWatts: 34.08, GPUs: 0},
+	{Cloud: "gcp", InstanceType: "n2-standard-16", VCPUs: 16, MemoryGB: 64, Microarchitecture: "Cascade Lake", MinWatts: 10.24, MaxWatts: 63.52, GPUs: 0},
+	{Cloud: "gcp", InstanceType: "n2-standard-2", VCPUs: 2, MemoryGB: 8, Microarchitecture: "Cascade Lake", MinWatts: 1.28, MaxWatts: 7.94, GPUs: 0},
+	{Cloud: "gcp", InstanceType: "n2-standard-4", VCPUs: 4, MemoryGB: 16, Microarchitecture: "Cascade Lake", MinWatts: 2.56, MaxWatts: 15.88, GPUs: 0},
+	{Cloud: "gcp", InstanceType: "n2-standard-8", VCPUs: 8, MemoryGB: 32, Microarchitecture: "Cascade Lake", MinWatts: 5.12, MaxWatts: 31.76, GPUs: 0},
+	{Cloud: "gcp", InstanceType: "n2d-standard-2", VCPUs: 2, MemoryGB: 8, Microarchitecture: "EPYC 2nd Gen", MinWatts: 0.94, MaxWatts: 3.28, GPUs: 0},
+	{Cloud: "gcp", InstanceType: "n2d-standard-4", VCPUs: 4, MemoryGB: 16, Microarchitecture: "EPYC 2nd Gen", MinWatts: 1.88, MaxWatts: 6.56, GPUs: 0},
+	{Cloud: "gcp", InstanceType: "t2d-standard-2", VCPUs: 2, MemoryGB: 8, Microarchitecture: "EPYC 3rd Gen", MinWatts: 0.9, MaxWatts: 4.04, GPUs: 0},
+}
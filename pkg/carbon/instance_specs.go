@@ -0,0 +1,49 @@
+package carbon
+
+import (
+	"fmt"
+	"strings"
+)
+
+//go:generate go run ./internal/instancegen -in data/instance_types.csv -out instance_catalog_gen.go
+
+// InstanceSpecs describes the hardware of a cloud instance type
+type InstanceSpecs struct {
+	Cloud             string  `json:"cloud"`
+	InstanceType      string  `json:"instanceType"`
+	VCPUs             int     `json:"vcpus"`
+	MemoryGB          float64 `json:"memoryGb"`
+	Microarchitecture string  `json:"microarchitecture"`
+	MinWatts          float64 `json:"minWatts"` // whole instance CPU draw at idle, used by the CCF model
+	MaxWatts          float64 `json:"maxWatts"` // whole instance CPU draw at full utilization
+	GPUs              int     `json:"gpus"`
+}
+
+// InstanceSpecProvider looks up the hardware behind a node's instance type
+type InstanceSpecProvider interface {
+	GetInstanceSpecs(instanceType string) (*InstanceSpecs, error)
+}
+
+// catalogInstanceSpecProvider serves the embedded AWS, Azure and GCP catalog
+type catalogInstanceSpecProvider struct {
+	specs map[string]InstanceSpecs
+}
+
+// NewInstanceSpecProvider returns a provider backed by the embedded instance catalog
+func NewInstanceSpecProvider() InstanceSpecProvider {
+	specs := make(map[string]InstanceSpecs, len(instanceCatalog))
+	for _, s := range instanceCatalog {
+		specs[strings.ToLower(s.InstanceType)] = s
+	}
+	return &catalogInstanceSpecProvider{specs: specs}
+}
+
+// GetInstanceSpecs returns a copy of the catalog entry. Lookups ignore case,
+// Azure node labels do not always match the documented VM size casing.
+func (p *catalogInstanceSpecProvider) GetInstanceSpecs(instanceType string) (*InstanceSpecs, error) {
+	s, ok := p.specs[strings.ToLower(strings.TrimSpace(instanceType))]
+	if !ok {
+		return nil, fmt.Errorf("unknown instance type: %s", instanceType)
+	}
+	return &s, nil
+}
@@ -0,0 +1,97 @@
+package carbon
+
+import (
+	"encoding/csv"
+	"os"
+	"testing"
+)
+
+func TestInstanceSpecProvider(t *testing.T) {
+	provider := NewInstanceSpecProvider()
+
+	t.Run("KnownType", func(t *testing.T) {
+		specs, err := provider.GetInstanceSpecs("m5.xlarge")
+		if err != nil {
+			t.Fatalf("GetInstanceSpecs failed: %v", err)
+		}
+
+		if specs.Cloud != CloudProviderAWS {
+			t.Errorf("Expected cloud %s, got %s", CloudProviderAWS, specs.Cloud)
+		}
+		if specs.VCPUs != 4 {
+			t.Errorf("Expected 4 vCPUs, got %d", specs.VCPUs)
+		}
+		if specs.MemoryGB != 16 {
+			t.Errorf("Expected 16 GB memory, got %f", specs.MemoryGB)
+		}
+		if specs.Microarchitecture != "Skylake" {
+			t.Errorf("Expected Skylake microarchitecture, got %s", specs.Microarchitecture)
+		}
+		if specs.MinWatts <= 0 || specs.MaxWatts <= specs.MinWatts {
+			t.Errorf("Expected 0 < min watts < max watts, got %f and %f", specs.MinWatts, specs.MaxWatts)
+		}
+		if got, want := NewCCFEnergyModel().NodePower(specs, 1), specs.MaxWatts+specs.MemoryGB*ccfMemoryWattsPerGB; abs(got-want) > 1e-9 {
+			t.Errorf("Expected the CCF model to draw the catalog max watts, got %f want %f", got, want)
+		}
+		if specs.GPUs != 0 {
+			t.Errorf("Expected no GPUs, got %d", specs.GPUs)
+		}
+	})
+
+	t.Run("CaseInsensitive", func(t *testing.T) {
+		specs, err := provider.GetInstanceSpecs("standard_d4s_v3")
+		if err != nil {
+			t.Fatalf("GetInstanceSpecs failed: %v", err)
+		}
+		if specs.InstanceType != "Standard_D4s_v3" || specs.Cloud != CloudProviderAzure {
+			t.Errorf("Expected Azure Standard_D4s_v3, got %s %s", specs.Cloud, specs.InstanceType)
+		}
+	})
+
+	t.Run("GPUInstance", func(t *testing.T) {
+		specs, err := provider.GetInstanceSpecs("a2-highgpu-1g")
+		if err != nil {
+			t.Fatalf("GetInstanceSpecs failed: %v", err)
+		}
+		if specs.GPUs != 1 {
+			t.Errorf("Expected 1 GPU, got %d", specs.GPUs)
+		}
+	})
+
+	t.Run("UnknownType", func(t *testing.T) {
+		if _, err := provider.GetInstanceSpecs(unknownInstanceType); err == nil {
+			t.Error("Expected error for unknown instance type, got nil")
+		}
+	})
+
+	t.Run("ReturnsCopy", func(t *testing.T) {
+		specs, _ := provider.GetInstanceSpecs("m5.large")
+		specs.VCPUs = 1000
+
+		again, _ := provider.GetInstanceSpecs("m5.large")
+		if again.VCPUs != 2 {
+			t.Errorf("Expected catalog to be unaffected by callers, got %d vCPUs", again.VCPUs)
+		}
+	})
+}
+
+func TestInstanceCatalogUpToDate(t *testing.T) {
+	f, err := os.Open("data/instance_types.csv")
+	if err != nil {
+		t.Fatalf("Failed to open catalog source: %v", err)
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.Comment = '#'
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read catalog source: %v", err)
+	}
+
+	// One header row plus one row per instance type
+	if len(records)-1 != len(instanceCatalog) {
+		t.Errorf("Catalog source has %d instance types but instance_catalog_gen.go has %d, run go generate",
+			len(records)-1, len(instanceCatalog))
+	}
+}
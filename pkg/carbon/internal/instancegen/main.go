@@ -0,0 +1,160 @@
+// Command instancegen turns the instance catalog CSV into Go source.
+//
+// It is run through go generate from pkg/carbon:
+//
+//	go run ./internal/instancegen -in data/instance_types.csv -out instance_catalog_gen.go
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// columns expected in the catalog header, in order
+var columns = []string{"cloud", "instance_type", "vcpus", "memory_gb", "microarchitecture", "min_watts", "max_watts", "gpus"}
+
+// clouds accepted in the cloud column
+var clouds = map[string]bool{"aws": true, "azure": true, "gcp": true}
+
+type instance struct {
+	cloud             string
+	instanceType      string
+	vcpus             int
+	memoryGB          float64
+	microarchitecture string
+	minWatts          float64
+	maxWatts          float64
+	gpus              int
+}
+
+func main() {
+	in := flag.String("in", "data/instance_types.csv", "catalog CSV to read")
+	out := flag.String("out", "instance_catalog_gen.go", "Go file to write")
+	flag.Parse()
+
+	f, err := os.Open(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	instances, err := parse(f)
+	if err != nil {
+		log.Fatalf("%s: %v", *in, err)
+	}
+
+	src, err := render(instances)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parse reads the catalog, rejecting malformed rows and duplicate instance types
+func parse(r io.Reader) ([]instance, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	if strings.Join(header, ",") != strings.Join(columns, ",") {
+		return nil, fmt.Errorf("header must be %q", strings.Join(columns, ","))
+	}
+
+	var instances []instance
+	seen := make(map[string]bool)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		inst, err := parseRecord(record)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		key := strings.ToLower(inst.instanceType)
+		if seen[key] {
+			return nil, fmt.Errorf("line %d: duplicate instance type %s", line, inst.instanceType)
+		}
+		seen[key] = true
+		instances = append(instances, inst)
+	}
+
+	sort.Slice(instances, func(i, j int) bool {
+		if instances[i].cloud != instances[j].cloud {
+			return instances[i].cloud < instances[j].cloud
+		}
+		return instances[i].instanceType < instances[j].instanceType
+	})
+	return instances, nil
+}
+
+func parseRecord(record []string) (instance, error) {
+	inst := instance{
+		cloud:             record[0],
+		instanceType:      record[1],
+		microarchitecture: record[4],
+	}
+	if !clouds[inst.cloud] {
+		return inst, fmt.Errorf("unknown cloud %q", inst.cloud)
+	}
+	if inst.instanceType == "" || inst.microarchitecture == "" {
+		return inst, fmt.Errorf("instance type and microarchitecture are required")
+	}
+
+	var err error
+	if inst.vcpus, err = strconv.Atoi(record[2]); err != nil || inst.vcpus <= 0 {
+		return inst, fmt.Errorf("invalid vcpus %q", record[2])
+	}
+	if inst.memoryGB, err = strconv.ParseFloat(record[3], 64); err != nil || inst.memoryGB <= 0 {
+		return inst, fmt.Errorf("invalid memory_gb %q", record[3])
+	}
+	if inst.minWatts, err = strconv.ParseFloat(record[5], 64); err != nil || inst.minWatts < 0 {
+		return inst, fmt.Errorf("invalid min_watts %q", record[5])
+	}
+	if inst.maxWatts, err = strconv.ParseFloat(record[6], 64); err != nil || inst.maxWatts < inst.minWatts {
+		return inst, fmt.Errorf("invalid max_watts %q", record[6])
+	}
+	if inst.gpus, err = strconv.Atoi(record[7]); err != nil || inst.gpus < 0 {
+		return inst, fmt.Errorf("invalid gpus %q", record[7])
+	}
+	return inst, nil
+}
+
+// render writes the catalog as a gofmt'ed Go slice literal
+func render(instances []instance) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by instancegen from data/instance_types.csv. DO NOT EDIT.\n\n")
+	buf.WriteString("package carbon\n\n")
+	buf.WriteString("var instanceCatalog = []InstanceSpecs{\n")
+	for _, inst := range instances {
+		fmt.Fprintf(&buf, "{Cloud: %q, InstanceType: %q, VCPUs: %d, MemoryGB: %s, Microarchitecture: %q, MinWatts: %s, MaxWatts: %s, GPUs: %d},\n",
+			inst.cloud, inst.instanceType, inst.vcpus, formatFloat(inst.memoryGB), inst.microarchitecture,
+			formatFloat(inst.minWatts), formatFloat(inst.maxWatts), inst.gpus)
+	}
+	buf.WriteString("}\n")
+	return format.Source(buf.Bytes())
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,124 @@
+package carbon
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Kepler queries. rate() of the joule counters is the power in watts, summed over containers and
+// over the dynamic and idle modes. Kepler also reports system and kernel processes as containers,
+// so the node sum covers the whole machine. Node totals are keyed on the node label that pod scrape
+// configs usually attach, or on the node_name label Kepler can export itself; instance is only the
+// address the exporter was scraped at.
+const (
+	keplerPodPowerQuery  = `sum by (container_namespace, pod_name) (rate(kepler_container_joules_total[%s]))`
+	keplerNodePowerQuery = `sum by (node, node_name) (rate(kepler_container_joules_total[%s]))`
+)
+
+// keplerEnergySource serves energy measured by Kepler from RAPL and eBPF, read through Prometheus.
+// Every pod or node is fetched in one query per window and reused for the rest of it.
+type keplerEnergySource struct {
+	api   promv1.API
+	pods  *rangeQueryCache[map[string][]EnergySample]
+	nodes *rangeQueryCache[map[string][]EnergySample]
+}
+
+// NewKeplerEnergySource creates an energy source reading Kepler metrics from the Prometheus at address.
+// token is sent as a bearer token when not empty.
+func NewKeplerEnergySource(address, token string) (EnergySource, error) {
+	promAPI, err := newPrometheusAPI(address, token)
+	if err != nil {
+		return nil, err
+	}
+	return &keplerEnergySource{
+		api:   promAPI,
+		pods:  newRangeQueryCache[map[string][]EnergySample](),
+		nodes: newRangeQueryCache[map[string][]EnergySample](),
+	}, nil
+}
+
+// PodEnergy returns the energy the containers of pod consumed in each step of window
+func (k *keplerEnergySource) PodEnergy(ctx context.Context, pod *corev1.Pod, window TimeWindow) ([]EnergySample, error) {
+	pods, err := k.pods.get(ctx, window, func(ctx context.Context) (map[string][]EnergySample, error) {
+		return k.queryEnergy(ctx, window, keplerPodPowerQuery, func(m model.Metric) string {
+			return string(m["container_namespace"]) + "/" + string(m["pod_name"])
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	samples, ok := pods[pod.Namespace+"/"+pod.Name]
+	if !ok {
+		return nil, fmt.Errorf("no Kepler metrics for pod %s/%s", pod.Namespace, pod.Name)
+	}
+	return samples, nil
+}
+
+// NodeEnergy returns the energy node consumed in each step of window
+func (k *keplerEnergySource) NodeEnergy(ctx context.Context, node *corev1.Node, window TimeWindow) ([]EnergySample, error) {
+	nodes, err := k.nodes.get(ctx, window, func(ctx context.Context) (map[string][]EnergySample, error) {
+		return k.queryEnergy(ctx, window, keplerNodePowerQuery, keplerNodeName)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	samples, ok := nodes[node.Name]
+	if !ok {
+		return nil, fmt.Errorf("no Kepler metrics for node %s", node.Name)
+	}
+	return samples, nil
+}
+
+// keplerNodeName returns the node a Kepler series belongs to, preferring the scrape's node label
+func keplerNodeName(m model.Metric) string {
+	if name, ok := m["node"]; ok && name != "" {
+		return string(name)
+	}
+	return string(m["node_name"])
+}
+
+// queryEnergy runs a power range query and returns the joules of each step keyed by series
+func (k *keplerEnergySource) queryEnergy(ctx context.Context, window TimeWindow, powerQuery string, key func(model.Metric) string) (map[string][]EnergySample, error) {
+	steps := window.Steps()
+	if len(steps) == 0 {
+		return map[string][]EnergySample{}, nil
+	}
+	r, rateRange := stepQueryRange(window)
+
+	power, err := queryPrometheusRange(ctx, k.api, fmt.Sprintf(powerQuery, rateRange), r)
+	if err != nil {
+		return nil, err
+	}
+
+	energy := make(map[string][]EnergySample, len(power))
+	for _, series := range power {
+		id := key(series.Metric)
+		if id == "" {
+			continue
+		}
+		for _, v := range series.Values {
+			idx := stepEndingAt(window, v.Timestamp)
+			if idx < 0 {
+				continue
+			}
+			// Watts are joules per second
+			start := steps[idx]
+			energy[id] = append(energy[id], EnergySample{
+				Timestamp: start,
+				Joules:    float64(v.Value) * window.stepDuration(start).Seconds(),
+			})
+		}
+	}
+
+	for _, samples := range energy {
+		sort.Slice(samples, func(i, j int) bool { return samples[i].Timestamp.Before(samples[j].Timestamp) })
+	}
+	return energy, nil
+}
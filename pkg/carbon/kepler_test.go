@@ -0,0 +1,176 @@
+package carbon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newKeplerStandIn answers range queries with two points per series, one per step:
+// pod test-pod-1 draws 10 then 20 watts, node test-node-1 draws 100 then 200 watts. Like a real
+// scrape, instance is the exporter's address while the node name is in the node_name label.
+func newKeplerStandIn(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.FormValue("query")
+		if !strings.Contains(query, "kepler_container_joules_total") {
+			t.Errorf("Unexpected query %s", query)
+		}
+
+		start, _ := strconv.ParseFloat(r.FormValue("start"), 64)
+		step, _ := strconv.ParseFloat(r.FormValue("step"), 64)
+
+		metric, first, second := `{"container_namespace":"production","pod_name":"test-pod-1"}`, 10.0, 20.0
+		if strings.Contains(query, "by (instance)") {
+			metric, first, second = `{"instance":"10.0.0.1:9102"}`, 100.0, 200.0
+		} else if strings.Contains(query, "by (node, node_name)") {
+			metric, first, second = `{"node_name":"test-node-1"}`, 100.0, 200.0
+		}
+
+		ts := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"matrix","result":[{"metric":%s,"values":[[%s,"%g"],[%s,"%g"]]}]}}`,
+			metric, ts(start), first, ts(start+step), second)
+	}))
+}
+
+func TestKeplerEnergySource(t *testing.T) {
+	ctx := context.Background()
+
+	server := newKeplerStandIn(t)
+	defer server.Close()
+
+	source, err := NewKeplerEnergySource(server.URL, "")
+	if err != nil {
+		t.Fatalf("NewKeplerEnergySource failed: %v", err)
+	}
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := TimeWindow{From: from, To: from.Add(time.Hour), Step: 30 * time.Minute}
+
+	t.Run("PodJoulesPerStep", func(t *testing.T) {
+		samples, err := source.PodEnergy(ctx, createTestPods()[0], window)
+		if err != nil {
+			t.Fatalf("PodEnergy failed: %v", err)
+		}
+		if len(samples) != 2 {
+			t.Fatalf("Expected 2 samples, got %d", len(samples))
+		}
+		// Watts times the seconds of a half-hour step
+		if samples[0].Joules != 10*1800 || samples[1].Joules != 20*1800 {
+			t.Errorf("Expected 18000 and 36000 J, got %f and %f", samples[0].Joules, samples[1].Joules)
+		}
+		if !samples[1].Timestamp.Equal(from.Add(30 * time.Minute)) {
+			t.Errorf("Expected the second sample at the second step start, got %s", samples[1].Timestamp)
+		}
+	})
+
+	t.Run("MissingPod", func(t *testing.T) {
+		if _, err := source.PodEnergy(ctx, createTestPods()[2], window); err == nil {
+			t.Error("Expected error for pod without Kepler metrics, got nil")
+		}
+	})
+
+	config := &CarbonConfig{DefaultGridIntensity: 400, PUE: 1.0}
+	calculator := NewCarbonCalculator(config, WithEnergySource(source))
+
+	t.Run("PodMeasured", func(t *testing.T) {
+		metrics, err := calculator.CalculatePodCarbon(ctx, createTestPods()[0], nil, nil, window)
+		if err != nil {
+			t.Fatalf("CalculatePodCarbon failed: %v", err)
+		}
+		if metrics[0].Source != SourceMeasured {
+			t.Errorf("Expected measured source, got %s", metrics[0].Source)
+		}
+		if want := 10 * 1800 / joulesPerKWh; abs(metrics[0].EnergyConsumption-want) > 1e-12 {
+			t.Errorf("Expected %f kWh, got %f", want, metrics[0].EnergyConsumption)
+		}
+	})
+
+	t.Run("ModelFallback", func(t *testing.T) {
+		metrics, err := calculator.CalculatePodCarbon(ctx, createTestPods()[2], nil, nil, window)
+		if err != nil {
+			t.Fatalf("CalculatePodCarbon failed: %v", err)
+		}
+		if metrics[0].Source != SourceCalculated || metrics[0].EnergyConsumption == 0 {
+			t.Errorf("Expected modelled energy, got %f from %s", metrics[0].EnergyConsumption, metrics[0].Source)
+		}
+	})
+
+	t.Run("NodeMeasured", func(t *testing.T) {
+		metrics, err := calculator.CalculateNodeCarbon(ctx, createTestNodes()[0], createTestPods(), window)
+		if err != nil {
+			t.Fatalf("CalculateNodeCarbon failed: %v", err)
+		}
+		if want := 200 * 1800 / joulesPerKWh; metrics[1].Source != SourceMeasured || abs(metrics[1].EnergyConsumption-want) > 1e-12 {
+			t.Errorf("Expected %f measured kWh, got %f from %s", want, metrics[1].EnergyConsumption, metrics[1].Source)
+		}
+	})
+
+	t.Run("NamespaceMixed", func(t *testing.T) {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "production"}}
+		// test-pod-2 has no Kepler metrics
+		metrics, err := calculator.CalculateNamespaceCarbon(ctx, namespace, createTestNodes(), createTestPods(), nil, window)
+		if err != nil {
+			t.Fatalf("CalculateNamespaceCarbon failed: %v", err)
+		}
+		if metrics[0].Source != sourceMixed {
+			t.Errorf("Expected mixed source, got %s", metrics[0].Source)
+		}
+	})
+}
+
+func TestKeplerEnergySourceCachesFailures(t *testing.T) {
+	var queries int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&queries, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	source, err := NewKeplerEnergySource(server.URL, "")
+	if err != nil {
+		t.Fatalf("NewKeplerEnergySource failed: %v", err)
+	}
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := TimeWindow{From: from, To: from.Add(time.Hour), Step: 30 * time.Minute}
+	for _, pod := range createTestPods() {
+		if _, err := source.PodEnergy(context.Background(), pod, window); err == nil {
+			t.Fatal("Expected error from failing Prometheus, got nil")
+		}
+	}
+
+	// Pods after the first reuse the failure rather than running the same range query again
+	if queries != 1 {
+		t.Errorf("Expected 1 query for the window, got %d", queries)
+	}
+}
+
+func TestKeplerNodeName(t *testing.T) {
+	tests := []struct {
+		name   string
+		metric model.Metric
+		want   string
+	}{
+		{"NodeLabel", model.Metric{"node": "test-node-1", "node_name": "other", "instance": "10.0.0.1:9102"}, "test-node-1"},
+		{"NodeNameLabel", model.Metric{"node_name": "test-node-1", "instance": "10.0.0.1:9102"}, "test-node-1"},
+		{"InstanceOnly", model.Metric{"instance": "10.0.0.1:9102"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := keplerNodeName(tt.metric); got != tt.want {
+				t.Errorf("Expected node %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
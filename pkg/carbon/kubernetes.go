@@ -0,0 +1,429 @@
+package carbon
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	batchlisters "k8s.io/client-go/listers/batch/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+// Kubernetes authentication modes
+const (
+	KubernetesAuthInCluster  = "in-cluster"
+	KubernetesAuthKubeconfig = "kubeconfig"
+	KubernetesAuthToken      = "token"
+)
+
+const (
+	defaultInformerResync = 10 * time.Minute
+	defaultCacheSyncWait  = 30 * time.Second
+
+	// podNodeIndex indexes pods by spec.nodeName so GetPodsOnNode avoids a full scan
+	podNodeIndex = "spec.nodeName"
+)
+
+// KubernetesClient provides read access to the cluster resources used for carbon calculations.
+// Objects returned are shared with the underlying cache and must not be modified.
+type KubernetesClient interface {
+	GetNodes(ctx context.Context) ([]*corev1.Node, error)
+	GetPods(ctx context.Context, namespace string) ([]*corev1.Pod, error)
+	GetNamespaces(ctx context.Context) ([]*corev1.Namespace, error)
+	GetPodsOnNode(ctx context.Context, nodeName string) ([]*corev1.Pod, error)
+
+	// GetReplicaSets and GetJobs return the intermediate owners linking pods to their
+	// Deployment or CronJob, in the namespace or in all namespaces if namespace is empty.
+	// Their caches start on first use; a Forbidden error tells the account may not list them.
+	GetReplicaSets(ctx context.Context, namespace string) ([]*appsv1.ReplicaSet, error)
+	GetJobs(ctx context.Context, namespace string) ([]*batchv1.Job, error)
+
+	// GetPodMetrics and GetNodeMetrics query the metrics.k8s.io API served by metrics-server.
+	// They are not cached and fail on clusters without metrics-server.
+	GetPodMetrics(ctx context.Context, namespace string) ([]*metricsv1beta1.PodMetrics, error)
+	GetNodeMetrics(ctx context.Context) ([]*metricsv1beta1.NodeMetrics, error)
+
+	TestConnection(ctx context.Context) error
+	Close() error
+}
+
+// KubernetesConfig holds configuration for connecting to a Kubernetes cluster
+type KubernetesConfig struct {
+	AuthMode              string `json:"authMode"`       // "in-cluster", "kubeconfig", "token"
+	KubeconfigPath        string `json:"kubeconfigPath"` // used with "kubeconfig"
+	Context               string `json:"context"`        // optional kubeconfig context
+	APIServerURL          string `json:"apiServerUrl"`   // used with "token"
+	InsecureSkipTLSVerify bool   `json:"insecureSkipTlsVerify"`
+	ResyncPeriodSeconds   int    `json:"resyncPeriodSeconds"`
+
+	// Secrets, only populated from secure JSON data
+	BearerToken string `json:"-"`
+	CACert      string `json:"-"` // PEM encoded
+}
+
+// kubernetesClient implements KubernetesClient on top of shared informers
+type kubernetesClient struct {
+	clientset kubernetes.Interface
+	metrics   metricsclient.Interface
+	factory   informers.SharedInformerFactory
+
+	nodeInformer      cache.SharedIndexInformer
+	podInformer       cache.SharedIndexInformer
+	namespaceInformer cache.SharedIndexInformer
+
+	nodeLister      corelisters.NodeLister
+	podLister       corelisters.PodLister
+	namespaceLister corelisters.NamespaceLister
+
+	// Owners are only needed by workload queries and older RBAC rules don't grant them,
+	// so their informers start on first use and don't hold up the other caches
+	replicaSetInformer *lazyInformer
+	jobInformer        *lazyInformer
+
+	stopCh    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewKubernetesClient creates a Kubernetes client and starts its informers
+func NewKubernetesClient(config *KubernetesConfig) (KubernetesClient, error) {
+	restConfig, err := buildRESTConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes clientset: %w", err)
+	}
+
+	metrics, err := metricsclient.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metrics clientset: %w", err)
+	}
+
+	resync := defaultInformerResync
+	if config.ResyncPeriodSeconds > 0 {
+		resync = time.Duration(config.ResyncPeriodSeconds) * time.Second
+	}
+
+	return newKubernetesClient(clientset, metrics, resync)
+}
+
+// newKubernetesClient wires informers for existing clientsets
+func newKubernetesClient(clientset kubernetes.Interface, metrics metricsclient.Interface, resync time.Duration) (*kubernetesClient, error) {
+	factory := informers.NewSharedInformerFactory(clientset, resync)
+
+	nodes := factory.Core().V1().Nodes()
+	pods := factory.Core().V1().Pods()
+	namespaces := factory.Core().V1().Namespaces()
+	// Must be registered before the informer is started
+	if err := pods.Informer().AddIndexers(cache.Indexers{podNodeIndex: indexPodByNode}); err != nil {
+		return nil, fmt.Errorf("failed to register pod node index: %w", err)
+	}
+
+	k := &kubernetesClient{
+		clientset:          clientset,
+		metrics:            metrics,
+		factory:            factory,
+		nodeInformer:       nodes.Informer(),
+		podInformer:        pods.Informer(),
+		namespaceInformer:  namespaces.Informer(),
+		nodeLister:         nodes.Lister(),
+		podLister:          pods.Lister(),
+		namespaceLister:    namespaces.Lister(),
+		replicaSetInformer: newLazyInformer(factory.Apps().V1().ReplicaSets().Informer),
+		jobInformer:        newLazyInformer(factory.Batch().V1().Jobs().Informer),
+		stopCh:             make(chan struct{}),
+	}
+
+	factory.Start(k.stopCh)
+
+	return k, nil
+}
+
+// buildRESTConfig builds a REST config for the configured authentication mode
+func buildRESTConfig(config *KubernetesConfig) (*rest.Config, error) {
+	if config == nil {
+		return nil, fmt.Errorf("kubernetes configuration is required")
+	}
+
+	var restConfig *rest.Config
+	var err error
+
+	switch config.AuthMode {
+	case KubernetesAuthInCluster, "":
+		restConfig, err = rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load in-cluster config: %w", err)
+		}
+
+	case KubernetesAuthKubeconfig:
+		loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+		if config.KubeconfigPath != "" {
+			loadingRules.ExplicitPath = config.KubeconfigPath
+		}
+		overrides := &clientcmd.ConfigOverrides{CurrentContext: config.Context}
+		restConfig, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+		}
+
+	case KubernetesAuthToken:
+		if config.APIServerURL == "" {
+			return nil, fmt.Errorf("API server URL is required for token authentication")
+		}
+		if config.BearerToken == "" {
+			return nil, fmt.Errorf("bearer token is required for token authentication")
+		}
+		restConfig = &rest.Config{
+			Host:        config.APIServerURL,
+			BearerToken: config.BearerToken,
+			TLSClientConfig: rest.TLSClientConfig{
+				CAData:   []byte(config.CACert),
+				Insecure: config.InsecureSkipTLSVerify,
+			},
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported kubernetes auth mode: %s", config.AuthMode)
+	}
+
+	restConfig.UserAgent = "k8scarbonfootprint"
+
+	return restConfig, nil
+}
+
+// indexPodByNode is the index function for podNodeIndex
+func indexPodByNode(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || pod.Spec.NodeName == "" {
+		return nil, nil
+	}
+	return []string{pod.Spec.NodeName}, nil
+}
+
+// waitForSync blocks until the node, pod and namespace caches are populated or ctx is done
+func (k *kubernetesClient) waitForSync(ctx context.Context) error {
+	synced := []cache.InformerSynced{
+		k.nodeInformer.HasSynced,
+		k.podInformer.HasSynced,
+		k.namespaceInformer.HasSynced,
+	}
+	if allSynced(synced) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultCacheSyncWait)
+	defer cancel()
+
+	if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+		return fmt.Errorf("timed out waiting for Kubernetes caches to sync")
+	}
+	return nil
+}
+
+// lazyInformer is a shared informer registered and started on first use
+type lazyInformer struct {
+	newInformer func() cache.SharedIndexInformer
+
+	once     sync.Once
+	informer cache.SharedIndexInformer
+
+	mu        sync.Mutex
+	forbidden error // last Forbidden error of listing or watching
+}
+
+// newLazyInformer creates a lazy informer, newInformer registers it with the factory
+func newLazyInformer(newInformer func() cache.SharedIndexInformer) *lazyInformer {
+	return &lazyInformer{newInformer: newInformer}
+}
+
+// start registers and starts the informer with factory the first time it is called
+func (l *lazyInformer) start(factory informers.SharedInformerFactory, stopCh <-chan struct{}) cache.SharedIndexInformer {
+	l.once.Do(func() {
+		l.informer = l.newInformer()
+		// Remember Forbidden errors, so that callers fail fast instead of waiting for a sync
+		// that never happens. The reflector keeps retrying and recovers once access is granted.
+		_ = l.informer.SetWatchErrorHandler(func(r *cache.Reflector, err error) {
+			if apierrors.IsForbidden(err) {
+				l.mu.Lock()
+				l.forbidden = err
+				l.mu.Unlock()
+			}
+			cache.DefaultWatchErrorHandler(r, err)
+		})
+		factory.Start(stopCh)
+	})
+	return l.informer
+}
+
+// forbiddenError returns the last Forbidden error, nil when there was none
+func (l *lazyInformer) forbiddenError() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.forbidden
+}
+
+// waitForLazySync starts l on first use and blocks until its cache is populated, access to it
+// turns out to be forbidden or ctx is done
+func (k *kubernetesClient) waitForLazySync(ctx context.Context, l *lazyInformer) (cache.Indexer, error) {
+	informer := l.start(k.factory, k.stopCh)
+	if informer.HasSynced() {
+		return informer.GetIndexer(), nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultCacheSyncWait)
+	defer cancel()
+
+	settled := func() bool { return informer.HasSynced() || l.forbiddenError() != nil }
+	if !cache.WaitForCacheSync(ctx.Done(), settled) {
+		return nil, fmt.Errorf("timed out waiting for Kubernetes caches to sync")
+	}
+	if !informer.HasSynced() {
+		return nil, fmt.Errorf("failed to list owners: %w", l.forbiddenError())
+	}
+	return informer.GetIndexer(), nil
+}
+
+// allSynced reports whether every informer has synced
+func allSynced(synced []cache.InformerSynced) bool {
+	for _, hasSynced := range synced {
+		if !hasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// GetNodes returns all nodes in the cluster
+func (k *kubernetesClient) GetNodes(ctx context.Context) ([]*corev1.Node, error) {
+	if err := k.waitForSync(ctx); err != nil {
+		return nil, err
+	}
+	return k.nodeLister.List(labels.Everything())
+}
+
+// GetPods returns pods in the namespace, or in all namespaces if namespace is empty
+func (k *kubernetesClient) GetPods(ctx context.Context, namespace string) ([]*corev1.Pod, error) {
+	if err := k.waitForSync(ctx); err != nil {
+		return nil, err
+	}
+	if namespace == "" {
+		return k.podLister.List(labels.Everything())
+	}
+	return k.podLister.Pods(namespace).List(labels.Everything())
+}
+
+// GetNamespaces returns all namespaces in the cluster
+func (k *kubernetesClient) GetNamespaces(ctx context.Context) ([]*corev1.Namespace, error) {
+	if err := k.waitForSync(ctx); err != nil {
+		return nil, err
+	}
+	return k.namespaceLister.List(labels.Everything())
+}
+
+// GetPodsOnNode returns pods scheduled to the given node
+func (k *kubernetesClient) GetPodsOnNode(ctx context.Context, nodeName string) ([]*corev1.Pod, error) {
+	if err := k.waitForSync(ctx); err != nil {
+		return nil, err
+	}
+
+	objs, err := k.podInformer.GetIndexer().ByIndex(podNodeIndex, nodeName)
+	if err != nil {
+		return nil, err
+	}
+
+	pods := make([]*corev1.Pod, 0, len(objs))
+	for _, obj := range objs {
+		if pod, ok := obj.(*corev1.Pod); ok {
+			pods = append(pods, pod)
+		}
+	}
+	return pods, nil
+}
+
+// GetReplicaSets returns replica sets in the namespace, or in all namespaces if namespace is empty
+func (k *kubernetesClient) GetReplicaSets(ctx context.Context, namespace string) ([]*appsv1.ReplicaSet, error) {
+	indexer, err := k.waitForLazySync(ctx, k.replicaSetInformer)
+	if err != nil {
+		return nil, err
+	}
+	lister := appslisters.NewReplicaSetLister(indexer)
+	if namespace == "" {
+		return lister.List(labels.Everything())
+	}
+	return lister.ReplicaSets(namespace).List(labels.Everything())
+}
+
+// GetJobs returns jobs in the namespace, or in all namespaces if namespace is empty
+func (k *kubernetesClient) GetJobs(ctx context.Context, namespace string) ([]*batchv1.Job, error) {
+	indexer, err := k.waitForLazySync(ctx, k.jobInformer)
+	if err != nil {
+		return nil, err
+	}
+	lister := batchlisters.NewJobLister(indexer)
+	if namespace == "" {
+		return lister.List(labels.Everything())
+	}
+	return lister.Jobs(namespace).List(labels.Everything())
+}
+
+// GetPodMetrics returns current pod usage in the namespace, or in all namespaces if namespace is empty
+func (k *kubernetesClient) GetPodMetrics(ctx context.Context, namespace string) ([]*metricsv1beta1.PodMetrics, error) {
+	list, err := k.metrics.MetricsV1beta1().PodMetricses(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod metrics: %w", err)
+	}
+
+	podMetrics := make([]*metricsv1beta1.PodMetrics, 0, len(list.Items))
+	for i := range list.Items {
+		podMetrics = append(podMetrics, &list.Items[i])
+	}
+	return podMetrics, nil
+}
+
+// GetNodeMetrics returns current usage of all nodes
+func (k *kubernetesClient) GetNodeMetrics(ctx context.Context) ([]*metricsv1beta1.NodeMetrics, error) {
+	list, err := k.metrics.MetricsV1beta1().NodeMetricses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node metrics: %w", err)
+	}
+
+	nodeMetrics := make([]*metricsv1beta1.NodeMetrics, 0, len(list.Items))
+	for i := range list.Items {
+		nodeMetrics = append(nodeMetrics, &list.Items[i])
+	}
+	return nodeMetrics, nil
+}
+
+// TestConnection verifies the API server is reachable and the caches can sync
+func (k *kubernetesClient) TestConnection(ctx context.Context) error {
+	if _, err := k.clientset.Discovery().ServerVersion(); err != nil {
+		return fmt.Errorf("failed to reach API server: %w", err)
+	}
+	return k.waitForSync(ctx)
+}
+
+// Close stops the informers
+func (k *kubernetesClient) Close() error {
+	k.closeOnce.Do(func() {
+		close(k.stopCh)
+		k.factory.Shutdown()
+	})
+	return nil
+}
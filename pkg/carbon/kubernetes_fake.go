@@ -0,0 +1,141 @@
+package carbon
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+// FakeKubernetesClient is an in-memory KubernetesClient for tests
+type FakeKubernetesClient struct {
+	Nodes      []*corev1.Node
+	Pods       []*corev1.Pod
+	Namespaces []*corev1.Namespace
+
+	ReplicaSets []*appsv1.ReplicaSet
+	Jobs        []*batchv1.Job
+	// OwnersError is returned by GetReplicaSets and GetJobs when set, as without RBAC access to them
+	OwnersError error
+
+	PodMetrics  []*metricsv1beta1.PodMetrics
+	NodeMetrics []*metricsv1beta1.NodeMetrics
+	// MetricsError is returned by GetPodMetrics and GetNodeMetrics when set, as on clusters without metrics-server
+	MetricsError error
+
+	// ConnectionError is returned by TestConnection when set
+	ConnectionError error
+	Closed          bool
+}
+
+// NewFakeKubernetesClient creates a fake client serving the given objects
+func NewFakeKubernetesClient(nodes []*corev1.Node, pods []*corev1.Pod, namespaces []*corev1.Namespace) *FakeKubernetesClient {
+	return &FakeKubernetesClient{
+		Nodes:      nodes,
+		Pods:       pods,
+		Namespaces: namespaces,
+	}
+}
+
+// GetNodes returns all fake nodes
+func (f *FakeKubernetesClient) GetNodes(ctx context.Context) ([]*corev1.Node, error) {
+	return f.Nodes, nil
+}
+
+// GetPods returns fake pods in the namespace, or all pods if namespace is empty
+func (f *FakeKubernetesClient) GetPods(ctx context.Context, namespace string) ([]*corev1.Pod, error) {
+	if namespace == "" {
+		return f.Pods, nil
+	}
+
+	pods := make([]*corev1.Pod, 0)
+	for _, pod := range f.Pods {
+		if pod.Namespace == namespace {
+			pods = append(pods, pod)
+		}
+	}
+	return pods, nil
+}
+
+// GetNamespaces returns all fake namespaces
+func (f *FakeKubernetesClient) GetNamespaces(ctx context.Context) ([]*corev1.Namespace, error) {
+	return f.Namespaces, nil
+}
+
+// GetPodsOnNode returns fake pods scheduled to the node
+func (f *FakeKubernetesClient) GetPodsOnNode(ctx context.Context, nodeName string) ([]*corev1.Pod, error) {
+	pods := make([]*corev1.Pod, 0)
+	for _, pod := range f.Pods {
+		if pod.Spec.NodeName == nodeName {
+			pods = append(pods, pod)
+		}
+	}
+	return pods, nil
+}
+
+// GetReplicaSets returns fake replica sets in the namespace, or all replica sets if namespace is empty
+func (f *FakeKubernetesClient) GetReplicaSets(ctx context.Context, namespace string) ([]*appsv1.ReplicaSet, error) {
+	if f.OwnersError != nil {
+		return nil, f.OwnersError
+	}
+	replicaSets := make([]*appsv1.ReplicaSet, 0)
+	for _, rs := range f.ReplicaSets {
+		if namespace == "" || rs.Namespace == namespace {
+			replicaSets = append(replicaSets, rs)
+		}
+	}
+	return replicaSets, nil
+}
+
+// GetJobs returns fake jobs in the namespace, or all jobs if namespace is empty
+func (f *FakeKubernetesClient) GetJobs(ctx context.Context, namespace string) ([]*batchv1.Job, error) {
+	if f.OwnersError != nil {
+		return nil, f.OwnersError
+	}
+	jobs := make([]*batchv1.Job, 0)
+	for _, job := range f.Jobs {
+		if namespace == "" || job.Namespace == namespace {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+// GetPodMetrics returns fake pod metrics in the namespace, or all pod metrics if namespace is empty
+func (f *FakeKubernetesClient) GetPodMetrics(ctx context.Context, namespace string) ([]*metricsv1beta1.PodMetrics, error) {
+	if f.MetricsError != nil {
+		return nil, f.MetricsError
+	}
+	if namespace == "" {
+		return f.PodMetrics, nil
+	}
+
+	podMetrics := make([]*metricsv1beta1.PodMetrics, 0)
+	for _, m := range f.PodMetrics {
+		if m.Namespace == namespace {
+			podMetrics = append(podMetrics, m)
+		}
+	}
+	return podMetrics, nil
+}
+
+// GetNodeMetrics returns all fake node metrics
+func (f *FakeKubernetesClient) GetNodeMetrics(ctx context.Context) ([]*metricsv1beta1.NodeMetrics, error) {
+	if f.MetricsError != nil {
+		return nil, f.MetricsError
+	}
+	return f.NodeMetrics, nil
+}
+
+// TestConnection returns ConnectionError
+func (f *FakeKubernetesClient) TestConnection(ctx context.Context) error {
+	return f.ConnectionError
+}
+
+// Close marks the client as closed
+func (f *FakeKubernetesClient) Close() error {
+	f.Closed = true
+	return nil
+}
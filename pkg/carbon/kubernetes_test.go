@@ -0,0 +1,197 @@
+package carbon
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	metricsfake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
+)
+
+func TestKubernetesClient(t *testing.T) {
+	ctx := context.Background()
+
+	objects := []runtime.Object{
+		createTestNodes()[0],
+		createTestNodes()[1],
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "production"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "development"}},
+	}
+	for _, pod := range createTestPods() {
+		objects = append(objects, pod)
+	}
+	otherPod := createPodWithResources("test-pod-4", "production", "100m", "128Mi")
+	otherPod.Spec.NodeName = "test-node-2"
+	objects = append(objects, otherPod,
+		&appsv1.ReplicaSet{ObjectMeta: ownedBy("web-7d4b9c", "production", "Deployment", "web")},
+		&batchv1.Job{ObjectMeta: ownedBy("backup-28374650", "development", "CronJob", "backup")},
+	)
+
+	clientset := fake.NewSimpleClientset(objects...)
+	client, err := newKubernetesClient(clientset, metricsfake.NewSimpleClientset(), time.Minute)
+	if err != nil {
+		t.Fatalf("newKubernetesClient failed: %v", err)
+	}
+	defer client.Close()
+
+	t.Run("GetNodes", func(t *testing.T) {
+		nodes, err := client.GetNodes(ctx)
+		if err != nil {
+			t.Fatalf("GetNodes failed: %v", err)
+		}
+		if len(nodes) != 2 {
+			t.Errorf("Expected 2 nodes, got %d", len(nodes))
+		}
+	})
+
+	t.Run("GetPods", func(t *testing.T) {
+		pods, err := client.GetPods(ctx, "")
+		if err != nil {
+			t.Fatalf("GetPods failed: %v", err)
+		}
+		if len(pods) != 4 {
+			t.Errorf("Expected 4 pods, got %d", len(pods))
+		}
+
+		pods, err = client.GetPods(ctx, "production")
+		if err != nil {
+			t.Fatalf("GetPods failed: %v", err)
+		}
+		if len(pods) != 3 {
+			t.Errorf("Expected 3 pods in production, got %d", len(pods))
+		}
+	})
+
+	t.Run("GetNamespaces", func(t *testing.T) {
+		namespaces, err := client.GetNamespaces(ctx)
+		if err != nil {
+			t.Fatalf("GetNamespaces failed: %v", err)
+		}
+		if len(namespaces) != 2 {
+			t.Errorf("Expected 2 namespaces, got %d", len(namespaces))
+		}
+	})
+
+	t.Run("GetPodsOnNode", func(t *testing.T) {
+		pods, err := client.GetPodsOnNode(ctx, "test-node-2")
+		if err != nil {
+			t.Fatalf("GetPodsOnNode failed: %v", err)
+		}
+		if len(pods) != 1 || pods[0].Name != "test-pod-4" {
+			t.Errorf("Expected only test-pod-4 on test-node-2, got %v", pods)
+		}
+	})
+
+	t.Run("GetOwners", func(t *testing.T) {
+		replicaSets, err := client.GetReplicaSets(ctx, "production")
+		if err != nil {
+			t.Fatalf("GetReplicaSets failed: %v", err)
+		}
+		if len(replicaSets) != 1 {
+			t.Errorf("Expected 1 replica set, got %d", len(replicaSets))
+		}
+
+		jobs, err := client.GetJobs(ctx, "production")
+		if err != nil {
+			t.Fatalf("GetJobs failed: %v", err)
+		}
+		if len(jobs) != 0 {
+			t.Errorf("Expected no jobs in production, got %d", len(jobs))
+		}
+	})
+
+	t.Run("TestConnection", func(t *testing.T) {
+		if err := client.TestConnection(ctx); err != nil {
+			t.Errorf("TestConnection failed: %v", err)
+		}
+	})
+}
+
+func TestKubernetesClientOwnersForbidden(t *testing.T) {
+	ctx := context.Background()
+
+	clientset := fake.NewSimpleClientset(createTestNodes()[0])
+	clientset.PrependReactor("list", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Group: "batch", Resource: "jobs"}, "", errors.New("no RBAC rule"))
+	})
+
+	client, err := newKubernetesClient(clientset, metricsfake.NewSimpleClientset(), time.Minute)
+	if err != nil {
+		t.Fatalf("newKubernetesClient failed: %v", err)
+	}
+	defer client.Close()
+
+	t.Run("GetJobsFailsFast", func(t *testing.T) {
+		start := time.Now()
+		_, err := client.GetJobs(ctx, "")
+		if !apierrors.IsForbidden(err) {
+			t.Fatalf("Expected a Forbidden error, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed >= defaultCacheSyncWait {
+			t.Errorf("Expected GetJobs to fail before the sync timeout, took %s", elapsed)
+		}
+	})
+
+	// The job informer keeps failing in the background without holding up the other caches
+	t.Run("OtherCachesSync", func(t *testing.T) {
+		nodes, err := client.GetNodes(ctx)
+		if err != nil {
+			t.Fatalf("GetNodes failed: %v", err)
+		}
+		if len(nodes) != 1 {
+			t.Errorf("Expected 1 node, got %d", len(nodes))
+		}
+	})
+
+	t.Run("ReplicaSetsUnaffected", func(t *testing.T) {
+		if _, err := client.GetReplicaSets(ctx, ""); err != nil {
+			t.Errorf("GetReplicaSets failed: %v", err)
+		}
+	})
+}
+
+func TestBuildRESTConfig(t *testing.T) {
+	t.Run("Token", func(t *testing.T) {
+		config, err := buildRESTConfig(&KubernetesConfig{
+			AuthMode:     KubernetesAuthToken,
+			APIServerURL: "https://kubernetes.example.com",
+			BearerToken:  "secret",
+		})
+		if err != nil {
+			t.Fatalf("buildRESTConfig failed: %v", err)
+		}
+		if config.Host != "https://kubernetes.example.com" {
+			t.Errorf("Expected host to be set, got %s", config.Host)
+		}
+		if config.BearerToken != "secret" {
+			t.Errorf("Expected bearer token to be set")
+		}
+	})
+
+	t.Run("TokenMissing", func(t *testing.T) {
+		_, err := buildRESTConfig(&KubernetesConfig{
+			AuthMode:     KubernetesAuthToken,
+			APIServerURL: "https://kubernetes.example.com",
+		})
+		if err == nil {
+			t.Error("Expected error for missing bearer token, got nil")
+		}
+	})
+
+	t.Run("UnknownMode", func(t *testing.T) {
+		_, err := buildRESTConfig(&KubernetesConfig{AuthMode: "magic"})
+		if err == nil {
+			t.Error("Expected error for unknown auth mode, got nil")
+		}
+	})
+}
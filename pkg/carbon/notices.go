@@ -0,0 +1,59 @@
+package carbon
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// queryNotices collects the warnings raised while answering a query. The datasource attaches them
+// to the query's frames, so that Grafana shows when a result rests on fallbacks or estimates.
+type queryNotices struct {
+	mu      sync.Mutex
+	notices []data.Notice
+}
+
+type queryNoticesKey struct{}
+
+// withQueryNotices returns a context collecting the notices raised under it
+func withQueryNotices(ctx context.Context) (context.Context, *queryNotices) {
+	notices := &queryNotices{}
+	return context.WithValue(ctx, queryNoticesKey{}, notices), notices
+}
+
+// addQueryNotice records a notice with the query of ctx; repeated notices are recorded once.
+// Outside of a query, e.g. in a health check, nothing is recorded.
+func addQueryNotice(ctx context.Context, severity data.NoticeSeverity, format string, args ...interface{}) {
+	notices, ok := ctx.Value(queryNoticesKey{}).(*queryNotices)
+	if !ok {
+		return
+	}
+
+	text := fmt.Sprintf(format, args...)
+	notices.mu.Lock()
+	defer notices.mu.Unlock()
+	for _, notice := range notices.notices {
+		if notice.Text == text {
+			return
+		}
+	}
+	notices.notices = append(notices.notices, data.Notice{Severity: severity, Text: text})
+}
+
+// attach adds the collected notices to the meta data of every frame
+func (n *queryNotices) attach(frames data.Frames) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if len(n.notices) == 0 {
+		return
+	}
+	for _, frame := range frames {
+		if frame.Meta == nil {
+			frame.Meta = &data.FrameMeta{}
+		}
+		frame.Meta.Notices = append(frame.Meta.Notices, n.notices...)
+	}
+}
@@ -0,0 +1,286 @@
+package carbon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/api"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// minRateRange keeps rate() windows wide enough to span several scrapes at small steps
+const minRateRange = 2 * time.Minute
+
+// rangeQueryTTL is how long range query results, failures included, are reused. It covers the
+// queries of one dashboard refresh, which ask for the same window from every panel.
+const rangeQueryTTL = time.Minute
+
+// cAdvisor queries. Pod series are per container, the root cgroup (id="/") is the whole node.
+// Node totals rely on the node label that the usual kubelet scrape configs attach.
+const (
+	promPodCPUQuery     = `sum by (namespace, pod) (rate(container_cpu_usage_seconds_total{container!="", pod!=""}[%s]))`
+	promPodMemoryQuery  = `sum by (namespace, pod) (container_memory_working_set_bytes{container!="", pod!=""})`
+	promNodeCPUQuery    = `sum by (node) (rate(container_cpu_usage_seconds_total{id="/"}[%s]))`
+	promNodeMemoryQuery = `sum by (node) (container_memory_working_set_bytes{id="/"})`
+)
+
+// prometheusUtilizationSource serves usage from PromQL range queries over cAdvisor metrics.
+// Every pod or node is fetched in one query per window and reused for the rest of it.
+type prometheusUtilizationSource struct {
+	api   promv1.API
+	pods  *rangeQueryCache[map[string][]UsageSample]
+	nodes *rangeQueryCache[map[string][]UsageSample]
+}
+
+// NewPrometheusUtilizationSource creates a utilization source querying the Prometheus at address.
+// token is sent as a bearer token when not empty.
+func NewPrometheusUtilizationSource(address, token string) (UtilizationSource, error) {
+	promAPI, err := newPrometheusAPI(address, token)
+	if err != nil {
+		return nil, err
+	}
+	return &prometheusUtilizationSource{
+		api:   promAPI,
+		pods:  newRangeQueryCache[map[string][]UsageSample](),
+		nodes: newRangeQueryCache[map[string][]UsageSample](),
+	}, nil
+}
+
+// newPrometheusAPI creates a client for the Prometheus HTTP API at address.
+// token is sent as a bearer token when not empty.
+func newPrometheusAPI(address, token string) (promv1.API, error) {
+	var roundTripper http.RoundTripper = api.DefaultRoundTripper
+	if token != "" {
+		roundTripper = bearerTokenRoundTripper{token: token, next: roundTripper}
+	}
+
+	client, err := api.NewClient(api.Config{Address: address, RoundTripper: roundTripper})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Prometheus client: %w", err)
+	}
+	return promv1.NewAPI(client), nil
+}
+
+// PodUsage returns the usage of pod at each step of window
+func (p *prometheusUtilizationSource) PodUsage(ctx context.Context, pod *corev1.Pod, window TimeWindow) ([]UsageSample, error) {
+	pods, err := p.pods.get(ctx, window, func(ctx context.Context) (map[string][]UsageSample, error) {
+		return p.queryUsage(ctx, window, promPodCPUQuery, promPodMemoryQuery, func(m model.Metric) string {
+			return string(m["namespace"]) + "/" + string(m["pod"])
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	samples, ok := pods[pod.Namespace+"/"+pod.Name]
+	if !ok {
+		return nil, fmt.Errorf("no Prometheus metrics for pod %s/%s", pod.Namespace, pod.Name)
+	}
+	return samples, nil
+}
+
+// NodeUsage returns the usage of node at each step of window
+func (p *prometheusUtilizationSource) NodeUsage(ctx context.Context, node *corev1.Node, window TimeWindow) ([]UsageSample, error) {
+	nodes, err := p.nodes.get(ctx, window, func(ctx context.Context) (map[string][]UsageSample, error) {
+		return p.queryUsage(ctx, window, promNodeCPUQuery, promNodeMemoryQuery, func(m model.Metric) string {
+			return string(m["node"])
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	samples, ok := nodes[node.Name]
+	if !ok {
+		return nil, fmt.Errorf("no Prometheus metrics for node %s", node.Name)
+	}
+	return samples, nil
+}
+
+// rangeQueryCache holds the results of range queries keyed by window, so that panels asking for
+// different windows don't evict each other. Concurrent callers for a window share one query,
+// which runs without holding the lock. Results, failures included, are kept for rangeQueryTTL.
+type rangeQueryCache[T any] struct {
+	mu      sync.Mutex
+	entries map[rangeQueryKey]*rangeQueryEntry[T]
+}
+
+// rangeQueryKey identifies a window regardless of the location and monotonic clock reading of its times
+type rangeQueryKey struct {
+	from, to int64
+	step     time.Duration
+}
+
+// rangeQueryEntry is a query in flight until done is closed
+type rangeQueryEntry[T any] struct {
+	done    chan struct{}
+	value   T
+	err     error
+	expires time.Time // zero while in flight
+}
+
+func newRangeQueryCache[T any]() *rangeQueryCache[T] {
+	return &rangeQueryCache[T]{entries: make(map[rangeQueryKey]*rangeQueryEntry[T])}
+}
+
+// get returns the result for window, running query when there is none yet
+func (c *rangeQueryCache[T]) get(ctx context.Context, window TimeWindow, query func(context.Context) (T, error)) (T, error) {
+	c.mu.Lock()
+	now := time.Now()
+	for k, entry := range c.entries {
+		if !entry.expires.IsZero() && now.After(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+
+	key := rangeQueryKey{from: window.From.UnixNano(), to: window.To.UnixNano(), step: window.Step}
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &rangeQueryEntry[T]{done: make(chan struct{})}
+		c.entries[key] = entry
+	}
+	c.mu.Unlock()
+
+	if ok {
+		select {
+		case <-entry.done:
+			return entry.value, entry.err
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+	}
+
+	entry.value, entry.err = query(ctx)
+
+	c.mu.Lock()
+	if errors.Is(entry.err, context.Canceled) || errors.Is(entry.err, context.DeadlineExceeded) {
+		// The caller gave up, that says nothing about the next one
+		delete(c.entries, key)
+	} else {
+		entry.expires = time.Now().Add(rangeQueryTTL)
+	}
+	c.mu.Unlock()
+	close(entry.done)
+
+	return entry.value, entry.err
+}
+
+// queryUsage runs the CPU and memory range queries and returns samples keyed by series
+func (p *prometheusUtilizationSource) queryUsage(ctx context.Context, window TimeWindow, cpuQuery, memoryQuery string, key func(model.Metric) string) (map[string][]UsageSample, error) {
+	steps := window.Steps()
+	if len(steps) == 0 {
+		return map[string][]UsageSample{}, nil
+	}
+	r, rateRange := stepQueryRange(window)
+
+	byKey := make(map[string]map[int]*UsageSample)
+	sampleAt := func(k string, ts model.Time) *UsageSample {
+		idx := stepEndingAt(window, ts)
+		if idx < 0 {
+			return nil
+		}
+		if byKey[k] == nil {
+			byKey[k] = make(map[int]*UsageSample)
+		}
+		if byKey[k][idx] == nil {
+			byKey[k][idx] = &UsageSample{Timestamp: steps[idx]}
+		}
+		return byKey[k][idx]
+	}
+
+	cpu, err := queryPrometheusRange(ctx, p.api, fmt.Sprintf(cpuQuery, rateRange), r)
+	if err != nil {
+		return nil, err
+	}
+	for _, series := range cpu {
+		for _, v := range series.Values {
+			if sample := sampleAt(key(series.Metric), v.Timestamp); sample != nil {
+				sample.CPUMillicores = float64(v.Value) * 1000
+			}
+		}
+	}
+
+	memory, err := queryPrometheusRange(ctx, p.api, memoryQuery, r)
+	if err != nil {
+		return nil, err
+	}
+	for _, series := range memory {
+		for _, v := range series.Values {
+			if sample := sampleAt(key(series.Metric), v.Timestamp); sample != nil {
+				sample.MemoryBytes = float64(v.Value)
+			}
+		}
+	}
+
+	usage := make(map[string][]UsageSample, len(byKey))
+	for k, samplesByStep := range byKey {
+		samples := make([]UsageSample, 0, len(samplesByStep))
+		for _, sample := range samplesByStep {
+			samples = append(samples, *sample)
+		}
+		sort.Slice(samples, func(i, j int) bool { return samples[i].Timestamp.Before(samples[j].Timestamp) })
+		usage[k] = samples
+	}
+	return usage, nil
+}
+
+// stepQueryRange returns the range evaluating a query at the end of each step of window, so rate()
+// averages over the step itself, and the rate() range to use. window must have at least one step.
+func stepQueryRange(window TimeWindow) (promv1.Range, model.Duration) {
+	steps := window.Steps()
+	step := window.stepLength()
+	rateRange := step
+	if rateRange < minRateRange {
+		rateRange = minRateRange
+	}
+
+	return promv1.Range{
+		Start: steps[0].Add(step),
+		End:   steps[len(steps)-1].Add(step),
+		Step:  step,
+	}, model.Duration(rateRange)
+}
+
+// stepEndingAt returns the index of the step of window evaluated at ts, -1 when there is none
+func stepEndingAt(window TimeWindow, ts model.Time) int {
+	idx := int(math.Round(float64(ts.Time().Sub(window.From))/float64(window.stepLength()))) - 1
+	if idx < 0 || idx >= len(window.Steps()) {
+		return -1
+	}
+	return idx
+}
+
+// queryPrometheusRange runs a range query that must return a matrix
+func queryPrometheusRange(ctx context.Context, promAPI promv1.API, query string, r promv1.Range) (model.Matrix, error) {
+	value, _, err := promAPI.QueryRange(ctx, query, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Prometheus: %w", err)
+	}
+	matrix, ok := value.(model.Matrix)
+	if !ok {
+		return nil, fmt.Errorf("unexpected Prometheus result type %s", value.Type())
+	}
+	return matrix, nil
+}
+
+// bearerTokenRoundTripper authenticates Prometheus requests
+type bearerTokenRoundTripper struct {
+	token string
+	next  http.RoundTripper
+}
+
+// RoundTrip adds the Authorization header to a copy of req
+func (b bearerTokenRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+b.token)
+	return b.next.RoundTrip(req)
+}
@@ -0,0 +1,198 @@
+package carbon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newPrometheusStandIn answers range queries with two points per series, one per step:
+// pod test-pod-1 uses 0.5 then 1.5 cores, node test-node-1 uses 1 then 2 cores.
+func newPrometheusStandIn(t *testing.T, queries *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(queries, 1)
+		if r.URL.Path != "/api/v1/query_range" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			t.Errorf("Expected bearer token, got %q", r.Header.Get("Authorization"))
+		}
+
+		start, _ := strconv.ParseFloat(r.FormValue("start"), 64)
+		step, _ := strconv.ParseFloat(r.FormValue("step"), 64)
+		query := r.FormValue("query")
+
+		var metric string
+		var first, second float64
+		switch {
+		case strings.Contains(query, "by (namespace, pod)"):
+			metric = `{"namespace":"production","pod":"test-pod-1"}`
+			first, second = 0.5, 1.5
+		default:
+			metric = `{"node":"test-node-1"}`
+			first, second = 1, 2
+		}
+		if strings.Contains(query, "container_memory_working_set_bytes") {
+			first, second = 1<<30, 1<<30
+		}
+
+		ts := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"matrix","result":[{"metric":%s,"values":[[%s,"%g"],[%s,"%g"]]}]}}`,
+			metric, ts(start), first, ts(start+step), second)
+	}))
+}
+
+func TestPrometheusUtilizationSource(t *testing.T) {
+	ctx := context.Background()
+
+	var queries int32
+	server := newPrometheusStandIn(t, &queries)
+	defer server.Close()
+
+	source, err := NewPrometheusUtilizationSource(server.URL, "test-token")
+	if err != nil {
+		t.Fatalf("NewPrometheusUtilizationSource failed: %v", err)
+	}
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := TimeWindow{From: from, To: from.Add(time.Hour), Step: 30 * time.Minute}
+	pod := createPodWithResources("test-pod-1", "production", "100m", "128Mi")
+
+	t.Run("PodUsagePerStep", func(t *testing.T) {
+		samples, err := source.PodUsage(ctx, pod, window)
+		if err != nil {
+			t.Fatalf("PodUsage failed: %v", err)
+		}
+		if len(samples) != 2 {
+			t.Fatalf("Expected 2 samples, got %d", len(samples))
+		}
+		if !samples[0].Timestamp.Equal(from) || !samples[1].Timestamp.Equal(from.Add(30*time.Minute)) {
+			t.Errorf("Expected samples at step starts, got %s and %s", samples[0].Timestamp, samples[1].Timestamp)
+		}
+		if samples[0].CPUMillicores != 500 || samples[1].CPUMillicores != 1500 {
+			t.Errorf("Expected 500 and 1500 millicores, got %f and %f", samples[0].CPUMillicores, samples[1].CPUMillicores)
+		}
+		if samples[0].MemoryBytes != 1<<30 {
+			t.Errorf("Expected 1Gi working set, got %f bytes", samples[0].MemoryBytes)
+		}
+	})
+
+	t.Run("QueriedOncePerWindow", func(t *testing.T) {
+		before := atomic.LoadInt32(&queries)
+		if _, err := source.PodUsage(ctx, createPodWithResources("test-pod-2", "production", "100m", "128Mi"), window); err == nil {
+			t.Error("Expected error for pod without series, got nil")
+		}
+		if atomic.LoadInt32(&queries) != before {
+			t.Error("Expected cached results for the same window")
+		}
+	})
+
+	t.Run("WindowsKeptApart", func(t *testing.T) {
+		other := TimeWindow{From: from.Add(time.Hour), To: from.Add(2 * time.Hour), Step: 30 * time.Minute}
+		if _, err := source.PodUsage(ctx, pod, other); err != nil {
+			t.Fatalf("PodUsage failed: %v", err)
+		}
+
+		// Alternating panels with different ranges don't evict each other
+		before := atomic.LoadInt32(&queries)
+		for _, w := range []TimeWindow{window, other, window} {
+			if _, err := source.PodUsage(ctx, pod, w); err != nil {
+				t.Fatalf("PodUsage failed: %v", err)
+			}
+		}
+		if atomic.LoadInt32(&queries) != before {
+			t.Errorf("Expected both windows to be cached, got %d more queries", atomic.LoadInt32(&queries)-before)
+		}
+	})
+
+	t.Run("NodeUsage", func(t *testing.T) {
+		samples, err := source.NodeUsage(ctx, createTestNodes()[0], window)
+		if err != nil {
+			t.Fatalf("NodeUsage failed: %v", err)
+		}
+		if len(samples) != 2 || samples[1].CPUMillicores != 2000 {
+			t.Errorf("Expected 2000 millicores in the second step, got %v", samples)
+		}
+	})
+
+	t.Run("ConcurrentCallersShareQuery", func(t *testing.T) {
+		concurrent := TimeWindow{From: from.Add(-time.Hour), To: from, Step: 30 * time.Minute}
+		before := atomic.LoadInt32(&queries)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := source.PodUsage(ctx, pod, concurrent); err != nil {
+					t.Errorf("PodUsage failed: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		// One CPU and one memory query
+		if got := atomic.LoadInt32(&queries) - before; got != 2 {
+			t.Errorf("Expected 2 queries for 10 concurrent callers, got %d", got)
+		}
+	})
+
+	t.Run("EnergyPerStep", func(t *testing.T) {
+		config := &CarbonConfig{DefaultGridIntensity: 500, PUE: 1.0, AllocationMode: AllocationUsage}
+		calculator := NewCarbonCalculator(config, WithUtilizationSource(source)).(*carbonCalculator)
+		node := createTestNodes()[0]
+
+		steps, err := calculator.calculatePodEnergyConsumption(ctx, pod, node, window)
+		if err != nil {
+			t.Fatalf("calculatePodEnergyConsumption failed: %v", err)
+		}
+		if len(steps) != 2 {
+			t.Fatalf("Expected 2 steps, got %d", len(steps))
+		}
+
+		specs := calculator.nodeSpecs(node)
+		for i, cores := range []float64{0.5, 1.5} {
+			want := calculator.energyModel.WorkloadPower(specs, cores, 1) * 0.5 / 1000
+			if abs(steps[i].energy-want) > 1e-12 {
+				t.Errorf("Expected %f kWh in half-hour step %d, got %f", want, i, steps[i].energy)
+			}
+			if steps[i].usage == nil || steps[i].usage.CPUMillicores != cores*1000 {
+				t.Errorf("Expected usage of %f millicores in step %d, got %v", cores*1000, i, steps[i].usage)
+			}
+		}
+	})
+}
+
+func TestPrometheusUtilizationSourceCachesFailures(t *testing.T) {
+	var queries int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&queries, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	source, err := NewPrometheusUtilizationSource(server.URL, "")
+	if err != nil {
+		t.Fatalf("NewPrometheusUtilizationSource failed: %v", err)
+	}
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := TimeWindow{From: from, To: from.Add(time.Hour), Step: 30 * time.Minute}
+	for _, name := range []string{"test-pod-1", "test-pod-2", "test-pod-3"} {
+		if _, err := source.PodUsage(context.Background(), createPodWithResources(name, "production", "100m", "128Mi"), window); err == nil {
+			t.Fatal("Expected error from failing Prometheus, got nil")
+		}
+	}
+
+	// Pods after the first reuse the failure rather than running the same range query again
+	if queries != 1 {
+		t.Errorf("Expected 1 query for the window, got %d", queries)
+	}
+}
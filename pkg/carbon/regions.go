@@ -0,0 +1,139 @@
+package carbon
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Well-known node labels
+const (
+	labelTopologyRegion      = "topology.kubernetes.io/region"
+	labelFailureDomainRegion = "failure-domain.beta.kubernetes.io/region"
+	labelTopologyZone        = "topology.kubernetes.io/zone"
+	labelInstanceType        = "node.kubernetes.io/instance-type"
+	labelInstanceTypeBeta    = "beta.kubernetes.io/instance-type"
+	unknownInstanceType      = "unknown"
+)
+
+//go:embed data/grid_regions.json
+var gridRegionsJSON []byte
+
+// gridRegionTable maps cloud regions to the grid zones used by each intensity provider
+type gridRegionTable struct {
+	Version string       `json:"version"`
+	Regions []gridRegion `json:"regions"`
+}
+
+// gridRegion is a single cloud region and its grid zones keyed by provider name
+type gridRegion struct {
+	Cloud    string            `json:"cloud"`
+	Region   string            `json:"region"`
+	Location string            `json:"location"`
+	Zones    map[string]string `json:"zones"`
+}
+
+// defaultGridRegions is the embedded mapping, parsed once at startup
+var defaultGridRegions, gridRegionsVersion = mustLoadGridRegions(gridRegionsJSON)
+
+// zoneFallbackProvider lists providers without their own zone naming, they reuse another provider's zones.
+// The UK provider only names British regions; other regions keep their Electricity Maps zone, which it
+// rejects, rather than falling to the default zone and being charged at British figures.
+var zoneFallbackProvider = map[string]string{
+	GridProviderStatic:            GridProviderElectricityMaps,
+	GridProviderFile:              GridProviderElectricityMaps,
+	GridProviderUKCarbonIntensity: GridProviderElectricityMaps,
+}
+
+func mustLoadGridRegions(raw []byte) (map[string]gridRegion, string) {
+	var table gridRegionTable
+	if err := json.Unmarshal(raw, &table); err != nil {
+		panic(fmt.Sprintf("invalid embedded grid region table: %v", err))
+	}
+
+	regions := make(map[string]gridRegion, len(table.Regions))
+	for _, r := range table.Regions {
+		if _, dup := regions[r.Region]; dup {
+			panic(fmt.Sprintf("duplicate region %s in embedded grid region table", r.Region))
+		}
+		regions[r.Region] = r
+	}
+	return regions, table.Version
+}
+
+// GridRegionTableVersion returns the version of the embedded region to grid zone mapping
+func GridRegionTableVersion() string {
+	return gridRegionsVersion
+}
+
+// gridZoneResolver maps cloud regions to grid zones for one intensity provider
+type gridZoneResolver struct {
+	provider    string
+	overrides   map[string]string
+	regions     map[string]gridRegion
+	defaultZone string
+}
+
+func newGridZoneResolver(config *CarbonConfig, provider string) *gridZoneResolver {
+	overrides := make(map[string]string, len(config.GridZoneOverrides))
+	for region, zone := range config.GridZoneOverrides {
+		overrides[strings.ToLower(strings.TrimSpace(region))] = zone
+	}
+
+	return &gridZoneResolver{
+		provider:    provider,
+		overrides:   overrides,
+		regions:     defaultGridRegions,
+		defaultZone: config.GridZone,
+	}
+}
+
+// Resolve returns the grid zone for region. User overrides win over the embedded table;
+// unknown regions use the configured default zone.
+func (r *gridZoneResolver) Resolve(region string) string {
+	region = strings.ToLower(strings.TrimSpace(region))
+	if region == "" {
+		return r.defaultZone
+	}
+	if zone, ok := r.overrides[region]; ok {
+		return zone
+	}
+
+	if mapped, ok := r.regions[region]; ok {
+		if zone, ok := mapped.Zones[r.provider]; ok {
+			return zone
+		}
+		if fallback, ok := zoneFallbackProvider[r.provider]; ok {
+			if zone, ok := mapped.Zones[fallback]; ok {
+				return zone
+			}
+		}
+	}
+
+	return r.defaultZone
+}
+
+// nodeRegion returns the cloud region a node runs in
+func nodeRegion(node *corev1.Node) string {
+	if node == nil {
+		return ""
+	}
+	if region, ok := node.Labels[labelTopologyRegion]; ok {
+		return region
+	}
+	return node.Labels[labelFailureDomainRegion]
+}
+
+// nodeInstanceType returns the instance type label of a node
+func nodeInstanceType(node *corev1.Node) string {
+	if it, ok := node.Labels[labelInstanceTypeBeta]; ok {
+		return it
+	}
+	if it, ok := node.Labels[labelInstanceType]; ok {
+		return it
+	}
+	return unknownInstanceType
+}
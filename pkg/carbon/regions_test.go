@@ -0,0 +1,153 @@
+package carbon
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGridZoneResolver(t *testing.T) {
+	if GridRegionTableVersion() == "" {
+		t.Error("Expected embedded grid region table to carry a version")
+	}
+
+	config := &CarbonConfig{
+		GridZone:          "DE",
+		GridZoneOverrides: map[string]string{"EU-CENTRAL-1": "AT"},
+	}
+
+	tests := []struct {
+		name     string
+		provider string
+		region   string
+		expected string
+	}{
+		{"AWS", GridProviderElectricityMaps, "us-east-1", "US-MIDA-PJM"},
+		{"Azure", GridProviderElectricityMaps, "westeurope", "NL"},
+		{"GCP", GridProviderElectricityMaps, "europe-west4", "NL"},
+		{"WattTime", GridProviderWattTime, "us-west-2", "BPA"},
+		{"UKRegion", GridProviderUKCarbonIntensity, "uksouth", "13"},
+		{"UKProviderOutsideGreatBritain", GridProviderUKCarbonIntensity, "us-east-1", "US-MIDA-PJM"},
+		{"StaticUsesElectricityMapsZones", GridProviderStatic, "eu-west-3", "FR"},
+		{"Override", GridProviderElectricityMaps, "eu-central-1", "AT"},
+		{"UnknownRegion", GridProviderElectricityMaps, "mars-north-1", "DE"},
+		{"NoRegion", GridProviderElectricityMaps, "", "DE"},
+		{"ProviderWithoutZone", GridProviderWattTime, "eu-west-1", "DE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newGridZoneResolver(config, tt.provider).Resolve(tt.region); got != tt.expected {
+				t.Errorf("Expected zone %s for %s, got %s", tt.expected, tt.region, got)
+			}
+		})
+	}
+}
+
+func TestNodeCarbonUsesRegionalIntensity(t *testing.T) {
+	ctx := context.Background()
+	provider := zoneIntensityProvider{"US-NW-BPAT": 100, "DE": 400}
+	calculator := NewCarbonCalculator(&CarbonConfig{DefaultGridIntensity: 475, PUE: 1.0}, WithGridIntensityProvider(provider))
+
+	nodes := createTestNodes()
+	nodes[0].Labels[labelTopologyRegion] = "us-west-2"
+	nodes[1].Labels[labelTopologyRegion] = "eu-central-1"
+
+	expected := map[string]float64{"test-node-1": 100, "test-node-2": 400}
+	for _, node := range nodes {
+		metrics, err := calculator.CalculateNodeCarbon(ctx, node, createTestPods(), defaultWindow(time.Now()))
+		if err != nil {
+			t.Fatalf("CalculateNodeCarbon failed: %v", err)
+		}
+		if metrics[0].GridIntensity != expected[node.Name] {
+			t.Errorf("Expected intensity %f for %s, got %f", expected[node.Name], node.Name, metrics[0].GridIntensity)
+		}
+		if metrics[0].Labels["region"] != node.Labels[labelTopologyRegion] {
+			t.Errorf("Expected region label %s, got %s", node.Labels[labelTopologyRegion], metrics[0].Labels["region"])
+		}
+	}
+}
+
+// zoneIntensityProvider is a GridIntensityProvider test double keyed by Electricity Maps zone
+type zoneIntensityProvider map[string]float64
+
+func (z zoneIntensityProvider) GetGridIntensity(ctx context.Context, zone string, at time.Time) (*IntensityDataPoint, error) {
+	intensity, ok := z[zone]
+	if !ok {
+		return nil, fmt.Errorf("no intensity for zone %s", zone)
+	}
+	return &IntensityDataPoint{Timestamp: at, Zone: zone, Intensity: intensity, Basis: IntensityBasisAverage, Source: "test"}, nil
+}
+
+func (z zoneIntensityProvider) Name() string {
+	return GridProviderElectricityMaps
+}
+
+func TestRollupsUseRegionalIntensity(t *testing.T) {
+	ctx := context.Background()
+	provider := zoneIntensityProvider{"US-NW-BPAT": 100, "DE": 400}
+	calculator := NewCarbonCalculator(&CarbonConfig{DefaultGridIntensity: 475, PUE: 1.2}, WithGridIntensityProvider(provider))
+
+	nodes := createTestNodes()
+	nodes[0].Labels[labelTopologyRegion] = "us-west-2"
+	nodes[1].Labels[labelTopologyRegion] = "eu-central-1"
+
+	pods := createTestPods()
+	pods[1].Spec.NodeName = "test-node-2"
+
+	t.Run("Cluster", func(t *testing.T) {
+		var expectedCO2, expectedEnergy float64
+		for _, node := range nodes {
+			metrics, err := calculator.CalculateNodeCarbon(ctx, node, pods, defaultWindow(time.Now()))
+			if err != nil {
+				t.Fatalf("CalculateNodeCarbon failed: %v", err)
+			}
+			expectedCO2 += metrics[0].CO2Emissions
+			expectedEnergy += metrics[0].EnergyConsumption
+		}
+
+		metrics, err := calculator.CalculateClusterCarbon(ctx, nodes, pods, defaultWindow(time.Now()))
+		if err != nil {
+			t.Fatalf("CalculateClusterCarbon failed: %v", err)
+		}
+		metric := metrics[0]
+
+		if abs(metric.CO2Emissions-expectedCO2) > 1e-9 {
+			t.Errorf("Expected cluster CO2 to equal the sum of nodes %f, got %f", expectedCO2, metric.CO2Emissions)
+		}
+		if abs(metric.GridIntensity-expectedCO2/expectedEnergy) > 1e-9 {
+			t.Errorf("Expected energy-weighted intensity %f, got %f", expectedCO2/expectedEnergy, metric.GridIntensity)
+		}
+		if metric.GridIntensity <= 100 || metric.GridIntensity >= 400 {
+			t.Errorf("Expected weighted intensity between regions, got %f", metric.GridIntensity)
+		}
+	})
+
+	t.Run("Namespace", func(t *testing.T) {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "production"}}
+
+		var expectedCO2 float64
+		for _, pod := range pods {
+			if pod.Namespace != namespace.Name {
+				continue
+			}
+			metrics, err := calculator.CalculatePodCarbon(ctx, pod, indexNodes(nodes)[pod.Spec.NodeName], nil, defaultWindow(time.Now()))
+			if err != nil {
+				t.Fatalf("CalculatePodCarbon failed: %v", err)
+			}
+			expectedCO2 += metrics[0].CO2Emissions
+		}
+
+		metrics, err := calculator.CalculateNamespaceCarbon(ctx, namespace, nodes, pods, nil, defaultWindow(time.Now()))
+		if err != nil {
+			t.Fatalf("CalculateNamespaceCarbon failed: %v", err)
+		}
+		if abs(metrics[0].CO2Emissions-expectedCO2) > 1e-9 {
+			t.Errorf("Expected namespace CO2 to equal the sum of its pods %f, got %f", expectedCO2, metrics[0].CO2Emissions)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package carbon
+
+import (
+	"context"
+	"strings"
+
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+)
+
+// requestsRangePlaceholder is replaced by the rate() range matching the query step, as in
+// sum(rate(http_requests_total[$__interval]))
+const requestsRangePlaceholder = "$__interval"
+
+// RequestCounter counts the requests served in each step of a window, the functional unit
+// carbon per request divides emissions by
+type RequestCounter interface {
+	// CountRequests returns the number of requests in each step of window. rateQuery is a PromQL
+	// expression of requests per second; every series it returns is added up.
+	CountRequests(ctx context.Context, rateQuery string, window TimeWindow) ([]float64, error)
+}
+
+// prometheusRequestCounter counts requests from request rates in Prometheus
+type prometheusRequestCounter struct {
+	api promv1.API
+}
+
+// NewPrometheusRequestCounter creates a request counter querying the Prometheus at address.
+// token is sent as a bearer token when not empty.
+func NewPrometheusRequestCounter(address, token string) (RequestCounter, error) {
+	promAPI, err := newPrometheusAPI(address, token)
+	if err != nil {
+		return nil, err
+	}
+	return &prometheusRequestCounter{api: promAPI}, nil
+}
+
+// CountRequests integrates the request rate of rateQuery over each step of window
+func (p *prometheusRequestCounter) CountRequests(ctx context.Context, rateQuery string, window TimeWindow) ([]float64, error) {
+	steps := window.Steps()
+	counts := make([]float64, len(steps))
+	if len(steps) == 0 {
+		return counts, nil
+	}
+	r, rateRange := stepQueryRange(window)
+
+	rates, err := queryPrometheusRange(ctx, p.api, strings.ReplaceAll(rateQuery, requestsRangePlaceholder, rateRange.String()), r)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, series := range rates {
+		for _, v := range series.Values {
+			idx := stepEndingAt(window, v.Timestamp)
+			if idx < 0 {
+				continue
+			}
+			counts[idx] += float64(v.Value) * window.stepDuration(steps[idx]).Seconds()
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,54 @@
+package carbon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPrometheusRequestCounter(t *testing.T) {
+	ctx := context.Background()
+
+	// Two services serving 2 and 3 requests per second, then 4 and 6
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if query := r.FormValue("query"); query != `sum by (service) (rate(http_requests_total[30m]))` {
+			t.Errorf("Unexpected query %s", query)
+		}
+
+		start, _ := strconv.ParseFloat(r.FormValue("start"), 64)
+		step, _ := strconv.ParseFloat(r.FormValue("step"), 64)
+		ts := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"matrix","result":[`+
+			`{"metric":{"service":"web"},"values":[[%[1]s,"2"],[%[2]s,"4"]]},`+
+			`{"metric":{"service":"api"},"values":[[%[1]s,"3"],[%[2]s,"6"]]}]}}`,
+			ts(start), ts(start+step))
+	}))
+	defer server.Close()
+
+	counter, err := NewPrometheusRequestCounter(server.URL, "")
+	if err != nil {
+		t.Fatalf("NewPrometheusRequestCounter failed: %v", err)
+	}
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := TimeWindow{From: from, To: from.Add(time.Hour), Step: 30 * time.Minute}
+
+	counts, err := counter.CountRequests(ctx, `sum by (service) (rate(http_requests_total[$__interval]))`, window)
+	if err != nil {
+		t.Fatalf("CountRequests failed: %v", err)
+	}
+
+	want := []float64{5 * 1800, 10 * 1800}
+	if len(counts) != len(want) {
+		t.Fatalf("Expected %d steps, got %d", len(want), len(counts))
+	}
+	for i := range want {
+		if counts[i] != want[i] {
+			t.Errorf("Expected %f requests in step %d, got %f", want[i], i, counts[i])
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package carbon
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Metric fields Query.GroupBy accepts, any other key groups by the label of that name
+const (
+	GroupByNamespace      = "namespace"
+	GroupByNode           = "node"
+	GroupByTeam           = "team"
+	GroupByResourceType   = "resourceType"
+	GroupByResource       = "resource"
+	GroupBySource         = "source"
+	GroupByIntensityBasis = "intensityBasis"
+	GroupByZone           = "zone"
+	GroupByRegion         = "region"
+	GroupByInstanceType   = "instanceType"
+)
+
+// metricGrouper reads group-by values of metrics. Node fields of pod metrics are read from
+// the node the pod runs on.
+type metricGrouper struct {
+	keys        []string
+	nodesByName map[string]*corev1.Node
+}
+
+// newMetricGrouper creates a grouper for keys, looking nodes up in nodes
+func newMetricGrouper(keys []string, nodes []*corev1.Node) *metricGrouper {
+	return &metricGrouper{keys: keys, nodesByName: indexNodes(nodes)}
+}
+
+// value returns the value of key on m
+func (g *metricGrouper) value(m *Metrics, key string) string {
+	switch key {
+	case GroupByNamespace:
+		return m.Namespace
+	case GroupByNode:
+		return m.NodeName
+	case GroupByTeam:
+		return teamOf(m)
+	case GroupByResourceType:
+		return m.ResourceType
+	case GroupByResource:
+		return m.ResourceName
+	case GroupBySource:
+		return m.Source
+	case GroupByIntensityBasis:
+		return m.IntensityBasis
+	case GroupByZone, GroupByRegion, GroupByInstanceType:
+		return g.nodeValue(m, key)
+	default:
+		return m.Labels[key]
+	}
+}
+
+// nodeValue returns a node field of m, from its node when known and from the node labels
+// the calculator sets on node metrics otherwise
+func (g *metricGrouper) nodeValue(m *Metrics, key string) string {
+	if node, ok := g.nodesByName[m.NodeName]; ok {
+		switch key {
+		case GroupByZone:
+			return node.Labels[labelTopologyZone]
+		case GroupByRegion:
+			return nodeRegion(node)
+		case GroupByInstanceType:
+			return nodeInstanceType(node)
+		}
+	}
+	if key == GroupByInstanceType {
+		return m.Labels["instance-type"]
+	}
+	return m.Labels[key]
+}
+
+// group rolls metrics up to one metric per combination of group-by values and timestamp, sorted
+// by name and time. A rollup is named after its values joined by "/" and labelled with each key
+// and value. Its grid intensity is energy-weighted; namespace, node and team are kept when all
+// members agree.
+func (g *metricGrouper) group(metrics []*Metrics) []*Metrics {
+	type groupStep struct {
+		name string
+		at   time.Time
+	}
+
+	byStep := make(map[groupStep]*Metrics)
+	for _, m := range metrics {
+		values := make(map[string]string, len(g.keys))
+		parts := make([]string, 0, len(g.keys))
+		for _, key := range g.keys {
+			values[key] = g.value(m, key)
+			parts = append(parts, values[key])
+		}
+
+		k := groupStep{name: strings.Join(parts, "/"), at: m.Timestamp.UTC()}
+		rollup, ok := byStep[k]
+		if !ok {
+			rollup = &Metrics{
+				Timestamp:      m.Timestamp,
+				ResourceType:   strings.Join(g.keys, ","),
+				ResourceName:   k.name,
+				Namespace:      m.Namespace,
+				NodeName:       m.NodeName,
+				Team:           m.Team,
+				GridIntensity:  m.GridIntensity,
+				IntensityBasis: m.IntensityBasis,
+				Source:         m.Source,
+				Labels:         values,
+			}
+			byStep[k] = rollup
+		} else {
+			if rollup.IntensityBasis != m.IntensityBasis {
+				rollup.IntensityBasis = intensityBasisMixed
+			}
+			if rollup.Namespace != m.Namespace {
+				rollup.Namespace = ""
+			}
+			if rollup.NodeName != m.NodeName {
+				rollup.NodeName = ""
+			}
+			if rollup.Team != m.Team {
+				rollup.Team = ""
+			}
+			rollup.Source = combineSources(rollup.Source, m.Source)
+		}
+
+		rollup.CO2Emissions += m.CO2Emissions
+		rollup.EnergyConsumption += m.EnergyConsumption
+		rollup.Cost += m.Cost
+		rollup.CPUUsage += m.CPUUsage
+		rollup.MemoryUsage += m.MemoryUsage
+		rollup.StorageUsage += m.StorageUsage
+		rollup.NetworkTraffic += m.NetworkTraffic
+	}
+
+	rollups := make([]*Metrics, 0, len(byStep))
+	for _, rollup := range byStep {
+		if rollup.EnergyConsumption > 0 {
+			rollup.GridIntensity = rollup.CO2Emissions / rollup.EnergyConsumption
+		}
+		rollups = append(rollups, rollup)
+	}
+	sort.Slice(rollups, func(i, j int) bool {
+		if rollups[i].ResourceName != rollups[j].ResourceName {
+			return rollups[i].ResourceName < rollups[j].ResourceName
+		}
+		return rollups[i].Timestamp.Before(rollups[j].Timestamp)
+	})
+	return rollups
+}
+
+// teamOf is the group-by value of the team key
+func teamOf(m *Metrics) string {
+	if m.Team == "" {
+		return UnassignedTeam
+	}
+	return m.Team
+}
+
+// summarizeSeries sums each resource over all steps into one metric stamped with its first step.
+// Emissions, energy, cost and traffic add up, the grid intensity is energy-weighted and CPU, memory
+// and storage, being levels rather than amounts, are averaged over the steps.
+func summarizeSeries(metrics []*Metrics) []*Metrics {
+	series := splitSeries(metrics)
+	summaries := make([]*Metrics, 0, len(series))
+	for _, steps := range series {
+		summary := *steps[0]
+		for _, m := range steps[1:] {
+			if summary.IntensityBasis != m.IntensityBasis {
+				summary.IntensityBasis = intensityBasisMixed
+			}
+			summary.Source = combineSources(summary.Source, m.Source)
+			summary.CO2Emissions += m.CO2Emissions
+			summary.EnergyConsumption += m.EnergyConsumption
+			summary.Cost += m.Cost
+			summary.NetworkTraffic += m.NetworkTraffic
+			summary.CPUUsage += m.CPUUsage
+			summary.MemoryUsage += m.MemoryUsage
+			summary.StorageUsage += m.StorageUsage
+		}
+
+		n := float64(len(steps))
+		summary.CPUUsage /= n
+		summary.MemoryUsage /= n
+		summary.StorageUsage /= n
+		if summary.EnergyConsumption > 0 {
+			summary.GridIntensity = summary.CO2Emissions / summary.EnergyConsumption
+		}
+		summaries = append(summaries, &summary)
+	}
+	return summaries
+}
@@ -0,0 +1,182 @@
+package carbon
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Metrics single-value queries select
+const (
+	ValueCO2              = "co2"
+	ValueEnergy           = "energy"
+	ValueIntensity        = "intensity"
+	ValueCPU              = "cpu"
+	ValueMemory           = "memory"
+	ValueCost             = "cost"
+	ValueCarbonPerRequest = "carbon_per_request"
+)
+
+// Aggregations single-value queries apply
+const (
+	AggregationSum   = "sum"
+	AggregationAvg   = "avg"
+	AggregationMax   = "max"
+	AggregationMin   = "min"
+	AggregationP50   = "p50"
+	AggregationP95   = "p95"
+	AggregationCount = "count"
+)
+
+// valueUnits are the field units of each selectable metric. Cost takes the unit of the configured currency.
+var valueUnits = map[string]string{
+	ValueCO2:              "gCO2",
+	ValueEnergy:           "kWh",
+	ValueIntensity:        "gCO2/kWh",
+	ValueCPU:              "suffix: mCPU",
+	ValueMemory:           "bytes",
+	ValueCost:             "",
+	ValueCarbonPerRequest: "suffix: gCO2/request",
+}
+
+// grafanaCurrencies are the currencies Grafana has a built-in unit for
+var grafanaCurrencies = map[string]bool{
+	"USD": true, "GBP": true, "EUR": true, "JPY": true, "RUB": true, "UAH": true, "BRL": true,
+	"DKK": true, "ISK": true, "NOK": true, "SEK": true, "CZK": true, "CHF": true, "PLN": true,
+	"ZAR": true, "INR": true, "KRW": true, "IDR": true, "PHP": true, "VND": true,
+}
+
+// currencyUnit returns the field unit of an ISO 4217 currency, suffixed with the code when
+// Grafana has no unit for it
+func currencyUnit(code string) string {
+	if grafanaCurrencies[code] {
+		return "currency" + code
+	}
+	return "suffix: " + code
+}
+
+// singleValueSamples returns the values a metric aggregates over, with the weights averaging them.
+//
+// Every metric but carbon per request has one value per resource summed over the time range, so
+// "avg" is the average resource and "count" the number of resources. Intensity is weighted by
+// energy, making its average the intensity of all the energy. Carbon per request has one value per
+// step with requests, weighted by them, so its average is the emissions of all requests.
+func singleValueSamples(metric string, metrics []*Metrics, query *Query) ([]float64, []float64, error) {
+	if metric == ValueCarbonPerRequest {
+		return carbonPerRequest(metrics, query.requests)
+	}
+
+	var value func(*Metrics) float64
+	switch metric {
+	case ValueCO2:
+		value = func(m *Metrics) float64 { return m.CO2Emissions }
+	case ValueEnergy:
+		value = func(m *Metrics) float64 { return m.EnergyConsumption }
+	case ValueIntensity:
+		value = func(m *Metrics) float64 { return m.GridIntensity }
+	case ValueCPU:
+		value = func(m *Metrics) float64 { return m.CPUUsage }
+	case ValueMemory:
+		value = func(m *Metrics) float64 { return m.MemoryUsage }
+	case ValueCost:
+		value = func(m *Metrics) float64 { return m.Cost }
+	default:
+		return nil, nil, fmt.Errorf("unknown single-value metric: %s", metric)
+	}
+
+	resources := summarizeSeries(metrics)
+	values := make([]float64, len(resources))
+	var weights []float64
+	if metric == ValueIntensity {
+		weights = make([]float64, len(resources))
+	}
+	for i, r := range resources {
+		values[i] = value(r)
+		if weights != nil {
+			weights[i] = r.EnergyConsumption
+		}
+	}
+	return values, weights, nil
+}
+
+// carbonPerRequest returns the emissions per request of each step with requests, weighted by them.
+// requests holds the requests of each step keyed by the Unix time it starts at.
+func carbonPerRequest(metrics []*Metrics, requests map[int64]float64) ([]float64, []float64, error) {
+	if requests == nil {
+		return nil, nil, fmt.Errorf("carbon per request needs a requests query")
+	}
+
+	emissions := make(map[int64]float64)
+	for _, m := range metrics {
+		emissions[m.Timestamp.Unix()] += m.CO2Emissions
+	}
+
+	steps := make([]int64, 0, len(emissions))
+	for step := range emissions {
+		steps = append(steps, step)
+	}
+	sort.Slice(steps, func(i, j int) bool { return steps[i] < steps[j] })
+
+	var values, weights []float64
+	for _, step := range steps {
+		if n := requests[step]; n > 0 {
+			values = append(values, emissions[step]/n)
+			weights = append(weights, n)
+		}
+	}
+	return values, weights, nil
+}
+
+// aggregate reduces values, averaging with weights when given
+func aggregate(aggregation string, values, weights []float64) (float64, error) {
+	switch aggregation {
+	case AggregationCount:
+		return float64(len(values)), nil
+	case AggregationSum:
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		return sum, nil
+	case AggregationAvg:
+		var sum, total float64
+		for i, v := range values {
+			w := 1.0
+			if weights != nil {
+				w = weights[i]
+			}
+			sum += v * w
+			total += w
+		}
+		if total == 0 {
+			return 0, nil
+		}
+		return sum / total, nil
+	case AggregationMax, AggregationMin, AggregationP50, AggregationP95:
+		if len(values) == 0 {
+			return 0, nil
+		}
+		sorted := append([]float64(nil), values...)
+		sort.Float64s(sorted)
+		switch aggregation {
+		case AggregationMax:
+			return sorted[len(sorted)-1], nil
+		case AggregationMin:
+			return sorted[0], nil
+		case AggregationP50:
+			return percentile(sorted, 0.5), nil
+		default:
+			return percentile(sorted, 0.95), nil
+		}
+	default:
+		return 0, fmt.Errorf("unknown aggregation: %s", aggregation)
+	}
+}
+
+// percentile interpolates linearly between the closest ranks of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := p * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
@@ -0,0 +1,174 @@
+package carbon
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestSingleValueFrames(t *testing.T) {
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	next := at.Add(time.Hour)
+
+	// Four pods over two steps: 10, 20, 30 and 140 g over the range
+	var metrics []*Metrics
+	for i, co2 := range []float64{10, 20, 30, 140} {
+		name := string(rune('a' + i))
+		energy := co2 / 100
+		if name == "d" {
+			energy = co2 / 400
+		}
+		for _, step := range []time.Time{at, next} {
+			metrics = append(metrics, &Metrics{
+				Timestamp:         step,
+				ResourceType:      "pod",
+				ResourceName:      name,
+				CO2Emissions:      co2 / 2,
+				EnergyConsumption: energy / 2,
+				GridIntensity:     co2 / energy,
+				Cost:              energy / 2 * 0.3,
+			})
+		}
+	}
+
+	query := &Query{
+		RefID:        "A",
+		QueryType:    "single-value",
+		Metrics:      []string{ValueCO2, ValueIntensity, ValueCost},
+		Aggregations: []string{AggregationSum, AggregationAvg, AggregationMax, AggregationMin, AggregationP50, AggregationP95, AggregationCount},
+	}
+	frames, err := ConvertToDataFrames(metrics, query)
+	if err != nil {
+		t.Fatalf("ConvertToDataFrames failed: %v", err)
+	}
+
+	values := make(map[string]float64)
+	for _, field := range frames[0].Fields {
+		values[field.Name] = field.At(0).(float64)
+	}
+	if len(values) != 21 {
+		t.Errorf("Expected 21 fields, got %d", len(values))
+	}
+
+	tests := map[string]float64{
+		"co2_sum":   200,
+		"co2_avg":   50,
+		"co2_max":   140,
+		"co2_min":   10,
+		"co2_p50":   25,
+		"co2_p95":   123.5,
+		"co2_count": 4,
+		// 200 g over 0.6 + 0.35 kWh, not the mean of 100, 100, 100 and 400
+		"intensity_avg": 200 / 0.95,
+		"cost_sum":      0.95 * 0.3,
+	}
+	for name, want := range tests {
+		if got, ok := values[name]; !ok || abs(got-want) > 1e-9 {
+			t.Errorf("Expected %s %f, got %f", name, want, got)
+		}
+	}
+
+	t.Run("CarbonPerRequest", func(t *testing.T) {
+		query := &Query{
+			RefID:        "A",
+			QueryType:    "single-value",
+			Metrics:      []string{ValueCarbonPerRequest},
+			Aggregations: []string{AggregationAvg, AggregationMax},
+			requests:     map[int64]float64{at.Unix(): 100, next.Unix(): 300},
+		}
+		frames, err := ConvertToDataFrames(metrics, query)
+		if err != nil {
+			t.Fatalf("ConvertToDataFrames failed: %v", err)
+		}
+
+		// 100 g per step: 1 g per request, then 1/3 g
+		if avg := frames[0].Fields[0].At(0).(float64); abs(avg-0.5) > 1e-9 {
+			t.Errorf("Expected 0.5 g per request over the range, got %f", avg)
+		}
+		if highest := frames[0].Fields[1].At(0).(float64); abs(highest-1) > 1e-9 {
+			t.Errorf("Expected at most 1 g per request, got %f", highest)
+		}
+	})
+
+	t.Run("UnknownAggregation", func(t *testing.T) {
+		query := &Query{RefID: "A", QueryType: "single-value", Metrics: []string{ValueCO2}, Aggregations: []string{"median"}}
+		if _, err := ConvertToDataFrames(metrics, query); err == nil {
+			t.Error("Expected error for unknown aggregation, got nil")
+		}
+
+		legacy := &Query{RefID: "A", QueryType: "single-value", Aggregation: "median"}
+		if _, err := ConvertToDataFrames(metrics, legacy); err == nil {
+			t.Error("Expected error for unknown legacy aggregation, got nil")
+		}
+	})
+}
+
+func TestDatasourceSingleValue(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDatasource()
+
+	t.Run("Cost", func(t *testing.T) {
+		d.energyPrice = 0.25
+		defer func() { d.energyPrice = 0 }()
+
+		res := d.query(ctx, backend.PluginContext{}, backend.DataQuery{
+			RefID: "A",
+			JSON:  []byte(`{"refId":"A","queryType":"single-value","resourceType":"cluster","metrics":["energy","cost"],"aggregations":["sum"]}`),
+		})
+		if res.Error != nil {
+			t.Fatalf("query failed: %v", res.Error)
+		}
+
+		energy := res.Frames[0].Fields[0].At(0).(float64)
+		cost := res.Frames[0].Fields[1].At(0).(float64)
+		if energy == 0 || abs(cost-energy*0.25) > 1e-12 {
+			t.Errorf("Expected cost %f, got %f", energy*0.25, cost)
+		}
+		if unit := res.Frames[0].Fields[1].Config.Unit; unit != "currencyUSD" {
+			t.Errorf("Expected cost in the default currency, got %s", unit)
+		}
+	})
+
+	t.Run("CarbonPerRequestIgnoresQueryPromQL", func(t *testing.T) {
+		counter := &recordingRequestCounter{}
+		d.requests, d.requestsQuery = counter, `sum(rate(http_requests_total[$__rate_interval]))`
+		defer func() { d.requests, d.requestsQuery = nil, "" }()
+
+		res := d.query(ctx, backend.PluginContext{}, backend.DataQuery{
+			RefID: "A",
+			JSON:  []byte(`{"refId":"A","queryType":"single-value","resourceType":"cluster","metrics":["carbon_per_request"],"requestsQuery":"count(secret_metric)"}`),
+		})
+		if res.Error != nil {
+			t.Fatalf("query failed: %v", res.Error)
+		}
+		if len(counter.queries) != 1 || counter.queries[0] != d.requestsQuery {
+			t.Errorf("Expected only the configured requests query to run, got %v", counter.queries)
+		}
+	})
+
+	t.Run("CarbonPerRequestWithoutPrometheus", func(t *testing.T) {
+		res := d.query(ctx, backend.PluginContext{}, backend.DataQuery{
+			RefID: "A",
+			JSON:  []byte(`{"refId":"A","queryType":"single-value","resourceType":"cluster","metrics":["carbon_per_request"]}`),
+		})
+		if res.Error == nil {
+			t.Error("Expected error without a request counter, got nil")
+		}
+	})
+}
+
+// recordingRequestCounter counts one request per step and records the queries it ran
+type recordingRequestCounter struct {
+	queries []string
+}
+
+func (r *recordingRequestCounter) CountRequests(ctx context.Context, rateQuery string, window TimeWindow) ([]float64, error) {
+	r.queries = append(r.queries, rateQuery)
+	counts := make([]float64, len(window.Steps()))
+	for i := range counts {
+		counts[i] = 1
+	}
+	return counts, nil
+}
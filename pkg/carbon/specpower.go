@@ -0,0 +1,141 @@
+package carbon
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// specpowerLoadLevels is the number of points on a SPECpower_ssj2008 curve, active idle then 10% steps to 100%
+const specpowerLoadLevels = 11
+
+//go:embed data/specpower_curves.json
+var specpowerCurvesJSON []byte
+
+// specpowerTable is the embedded set of server power curves
+type specpowerTable struct {
+	Version string           `json:"version"`
+	Curves  []specpowerCurve `json:"curves"`
+}
+
+// specpowerCurve is the measured power of one server at each SPECpower load level
+type specpowerCurve struct {
+	Processor string    `json:"processor"`
+	Families  []string  `json:"families"`
+	Threads   int       `json:"threads"`
+	Watts     []float64 `json:"watts"` // whole server, active idle then 10% ... 100%
+}
+
+// defaultSPECpowerCurves maps instance families to per-vCPU curves, parsed once at startup
+var defaultSPECpowerCurves = mustLoadSPECpowerCurves(specpowerCurvesJSON)
+
+func mustLoadSPECpowerCurves(raw []byte) map[string][]float64 {
+	var table specpowerTable
+	if err := json.Unmarshal(raw, &table); err != nil {
+		panic(fmt.Sprintf("invalid embedded SPECpower curves: %v", err))
+	}
+
+	curves := make(map[string][]float64)
+	for _, c := range table.Curves {
+		if len(c.Watts) != specpowerLoadLevels || c.Threads <= 0 {
+			panic(fmt.Sprintf("invalid SPECpower curve for %s", c.Processor))
+		}
+
+		// Scale the server curve to a single hardware thread, which is what a vCPU is
+		perVCPU := make([]float64, specpowerLoadLevels)
+		for i, w := range c.Watts {
+			perVCPU[i] = w / float64(c.Threads)
+		}
+
+		for _, family := range c.Families {
+			if _, dup := curves[family]; dup {
+				panic(fmt.Sprintf("duplicate family %s in embedded SPECpower curves", family))
+			}
+			curves[family] = perVCPU
+		}
+	}
+	return curves
+}
+
+// specpowerEnergyModel interpolates measured SPECpower_ssj2008 load curves piecewise, which
+// captures the steep rise in power from idle to low load that a linear model misses.
+// The curves cover the whole server including memory, so memory adds no separate draw.
+// Instance families without a curve are estimated by the fallback model.
+type specpowerEnergyModel struct {
+	curves   map[string][]float64
+	fallback EnergyModelProvider
+}
+
+// NewSPECpowerEnergyModel creates an energy model backed by the embedded SPECpower curves
+func NewSPECpowerEnergyModel() EnergyModelProvider {
+	return &specpowerEnergyModel{
+		curves:   defaultSPECpowerCurves,
+		fallback: NewCCFEnergyModel(),
+	}
+}
+
+// NodePower returns the draw of every vCPU at cpuUtilization
+func (m *specpowerEnergyModel) NodePower(specs *InstanceSpecs, cpuUtilization float64) float64 {
+	curve, ok := m.curves[instanceFamily(specs)]
+	if !ok {
+		return m.fallback.NodePower(specs, cpuUtilization)
+	}
+	return float64(specs.VCPUs) * interpolateCurve(curve, cpuUtilization)
+}
+
+// WorkloadPower charges each core a workload uses at full load
+func (m *specpowerEnergyModel) WorkloadPower(specs *InstanceSpecs, cpuCores, memoryGB float64) float64 {
+	curve, ok := m.curves[instanceFamily(specs)]
+	if !ok {
+		return m.fallback.WorkloadPower(specs, cpuCores, memoryGB)
+	}
+	return cpuCores * curve[specpowerLoadLevels-1]
+}
+
+// Name returns the model identifier
+func (m *specpowerEnergyModel) Name() string {
+	return EnergyModelSPECpower
+}
+
+// interpolateCurve returns the value of an 11 point load curve at utilization
+func interpolateCurve(curve []float64, utilization float64) float64 {
+	pos := clampUtilization(utilization) * float64(specpowerLoadLevels-1)
+	i := int(pos)
+	if i >= specpowerLoadLevels-1 {
+		return curve[specpowerLoadLevels-1]
+	}
+	frac := pos - float64(i)
+	return curve[i] + (curve[i+1]-curve[i])*frac
+}
+
+// instanceFamily returns the lowercase family of a catalog instance type:
+// "m5" for AWS m5.xlarge, "n2" for GCP n2-standard-4 and "ds_v3" for Azure Standard_D4s_v3.
+// Instance types outside the catalog have no family.
+func instanceFamily(specs *InstanceSpecs) string {
+	instanceType := strings.ToLower(specs.InstanceType)
+
+	switch specs.Cloud {
+	case CloudProviderAWS:
+		family, _, _ := strings.Cut(instanceType, ".")
+		return family
+	case CloudProviderGCP:
+		family, _, _ := strings.Cut(instanceType, "-")
+		return family
+	case CloudProviderAzure:
+		// Azure sizes put the vCPU count inside the series name, drop it to get the family
+		size, version, hasVersion := strings.Cut(strings.TrimPrefix(instanceType, "standard_"), "_")
+		family := strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return -1
+			}
+			return r
+		}, size)
+		if hasVersion {
+			family += "_" + version
+		}
+		return family
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,78 @@
+package carbon
+
+import (
+	"testing"
+)
+
+func TestSPECpowerEnergyModel(t *testing.T) {
+	model := NewSPECpowerEnergyModel()
+	specs, err := NewInstanceSpecProvider().GetInstanceSpecs("m5.xlarge")
+	if err != nil {
+		t.Fatalf("GetInstanceSpecs failed: %v", err)
+	}
+	curve := defaultSPECpowerCurves["m5"]
+
+	t.Run("LoadLevels", func(t *testing.T) {
+		if got, want := model.NodePower(specs, 0), 4*curve[0]; abs(got-want) > 1e-9 {
+			t.Errorf("Expected idle power %f, got %f", want, got)
+		}
+		if got, want := model.NodePower(specs, 1), 4*curve[10]; abs(got-want) > 1e-9 {
+			t.Errorf("Expected full load power %f, got %f", want, got)
+		}
+	})
+
+	t.Run("PiecewiseInterpolation", func(t *testing.T) {
+		if got, want := model.NodePower(specs, 0.05), 4*(curve[0]+curve[1])/2; abs(got-want) > 1e-9 {
+			t.Errorf("Expected power %f halfway to the 10%% load level, got %f", want, got)
+		}
+		if got, want := model.NodePower(specs, 0.35), 4*(curve[3]+curve[4])/2; abs(got-want) > 1e-9 {
+			t.Errorf("Expected power %f between the 30%% and 40%% load levels, got %f", want, got)
+		}
+	})
+
+	t.Run("NonLinearAtLowLoad", func(t *testing.T) {
+		// A linear model spreads the idle to full load range evenly, real servers jump early
+		idle := model.NodePower(specs, 0)
+		full := model.NodePower(specs, 1)
+		if model.NodePower(specs, 0.1) <= idle+(full-idle)*0.1 {
+			t.Error("Expected power at 10% load to exceed the linear estimate")
+		}
+	})
+
+	t.Run("UnknownFamilyFallsBack", func(t *testing.T) {
+		unknown := &InstanceSpecs{InstanceType: "custom", VCPUs: 2, MemoryGB: 4}
+		if got, want := model.NodePower(unknown, 0.5), NewCCFEnergyModel().NodePower(unknown, 0.5); got != want {
+			t.Errorf("Expected fallback power %f, got %f", want, got)
+		}
+	})
+}
+
+func TestInstanceFamily(t *testing.T) {
+	provider := NewInstanceSpecProvider()
+
+	tests := map[string]string{
+		"m5.xlarge":        "m5",
+		"g4dn.xlarge":      "g4dn",
+		"n2-standard-4":    "n2",
+		"Standard_D4s_v3":  "ds_v3",
+		"Standard_D2as_v5": "das_v5",
+		"Standard_B2ms":    "bms",
+	}
+	for instanceType, want := range tests {
+		specs, err := provider.GetInstanceSpecs(instanceType)
+		if err != nil {
+			t.Fatalf("GetInstanceSpecs(%s) failed: %v", instanceType, err)
+		}
+		if got := instanceFamily(specs); got != want {
+			t.Errorf("instanceFamily(%s) = %q, want %q", instanceType, got, want)
+		}
+	}
+}
+
+func TestSPECpowerCurvesCoverCatalog(t *testing.T) {
+	for _, specs := range instanceCatalog {
+		if _, ok := defaultSPECpowerCurves[instanceFamily(&specs)]; !ok {
+			t.Errorf("No SPECpower curve for %s (family %q)", specs.InstanceType, instanceFamily(&specs))
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package carbon
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Table columns that are not a fixed metric field
+const (
+	// columnLabels holds all labels of a row as "key=value" pairs, or one column per label key
+	// when the query flattens labels
+	columnLabels = "labels"
+	// labelColumnPrefix names the column of a single label, e.g. "labels.app"
+	labelColumnPrefix = "labels."
+)
+
+// tableColumn is a column of table frames, either text or numeric
+type tableColumn struct {
+	name   string
+	unit   string
+	text   func(*Metrics) string
+	number func(*Metrics) float64
+}
+
+// tableColumns lists the metric columns in their default order
+var tableColumns = []tableColumn{
+	{name: "resource", text: func(m *Metrics) string { return m.ResourceName }},
+	{name: "resource_type", text: func(m *Metrics) string { return m.ResourceType }},
+	{name: "namespace", text: func(m *Metrics) string { return m.Namespace }},
+	{name: "node", text: func(m *Metrics) string { return m.NodeName }},
+	{name: "team", text: func(m *Metrics) string { return m.Team }},
+	{name: "co2_emissions", unit: "gCO2", number: func(m *Metrics) float64 { return m.CO2Emissions }},
+	{name: "energy_consumption", unit: "kWh", number: func(m *Metrics) float64 { return m.EnergyConsumption }},
+	{name: ValueCost, number: func(m *Metrics) float64 { return m.Cost }}, // unit of the configured currency
+	{name: "grid_intensity", unit: "gCO2/kWh", number: func(m *Metrics) float64 { return m.GridIntensity }},
+	{name: "intensity_basis", text: func(m *Metrics) string { return m.IntensityBasis }},
+	{name: "source", text: func(m *Metrics) string { return m.Source }},
+	{name: "cpu_usage", unit: "suffix: mCPU", number: func(m *Metrics) float64 { return m.CPUUsage }},
+	{name: "memory_usage", unit: "bytes", number: func(m *Metrics) float64 { return m.MemoryUsage }},
+	{name: "storage_usage", unit: "bytes", number: func(m *Metrics) float64 { return m.StorageUsage }},
+	{name: "network_traffic", unit: "bytes", number: func(m *Metrics) float64 { return m.NetworkTraffic }},
+}
+
+// labelColumn returns the column of the label key
+func labelColumn(key string) tableColumn {
+	return tableColumn{name: labelColumnPrefix + key, text: func(m *Metrics) string { return m.Labels[key] }}
+}
+
+// joinedLabelsColumn holds every label of a row, sorted by key
+var joinedLabelsColumn = tableColumn{name: columnLabels, text: func(m *Metrics) string {
+	pairs := make([]string, 0, len(m.Labels))
+	for key, value := range m.Labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}}
+
+// selectColumns resolves the columns of query, every metric column and the labels by default.
+// With FlattenLabels the labels column expands to one column per label key found in metrics.
+func selectColumns(metrics []*Metrics, query *Query) ([]tableColumn, error) {
+	names := query.Columns
+	if len(names) == 0 {
+		names = make([]string, 0, len(tableColumns)+1)
+		for _, column := range tableColumns {
+			names = append(names, column.name)
+		}
+		names = append(names, columnLabels)
+	}
+
+	byName := make(map[string]tableColumn, len(tableColumns))
+	for _, column := range tableColumns {
+		if column.name == ValueCost {
+			column.unit = query.costUnit
+		}
+		byName[column.name] = column
+	}
+
+	columns := make([]tableColumn, 0, len(names))
+	for _, name := range names {
+		if column, ok := byName[name]; ok {
+			columns = append(columns, column)
+			continue
+		}
+		switch {
+		case name == columnLabels && query.FlattenLabels:
+			for _, key := range labelKeys(metrics) {
+				columns = append(columns, labelColumn(key))
+			}
+		case name == columnLabels:
+			columns = append(columns, joinedLabelsColumn)
+		case strings.HasPrefix(name, labelColumnPrefix) && len(name) > len(labelColumnPrefix):
+			columns = append(columns, labelColumn(strings.TrimPrefix(name, labelColumnPrefix)))
+		default:
+			return nil, fmt.Errorf("unknown table column: %s", name)
+		}
+	}
+	return columns, nil
+}
+
+// labelKeys returns the sorted label keys found in metrics
+func labelKeys(metrics []*Metrics) []string {
+	seen := make(map[string]bool)
+	for _, m := range metrics {
+		for key := range m.Labels {
+			seen[key] = true
+		}
+	}
+
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortRows orders metrics by the named column, which need not be among the selected columns
+func sortRows(metrics []*Metrics, by string, desc bool) error {
+	columns, err := selectColumns(metrics, &Query{Columns: []string{by}})
+	if err != nil {
+		return fmt.Errorf("invalid sort column: %w", err)
+	}
+	column := columns[0]
+
+	sort.SliceStable(metrics, func(i, j int) bool {
+		a, b := metrics[i], metrics[j]
+		if desc {
+			a, b = b, a
+		}
+		if column.number != nil {
+			return column.number(a) < column.number(b)
+		}
+		return column.text(a) < column.text(b)
+	})
+	return nil
+}
+
+// newColumnField creates the frame field of column filled from metrics
+func newColumnField(column tableColumn, metrics []*Metrics) *data.Field {
+	var field *data.Field
+	if column.number != nil {
+		values := make([]float64, len(metrics))
+		for i, m := range metrics {
+			values[i] = column.number(m)
+		}
+		field = data.NewField(column.name, nil, values)
+	} else {
+		values := make([]string, len(metrics))
+		for i, m := range metrics {
+			values[i] = column.text(m)
+		}
+		field = data.NewField(column.name, nil, values)
+	}
+
+	if column.unit != "" {
+		field.Config = &data.FieldConfig{Unit: column.unit}
+	}
+	return field
+}
@@ -0,0 +1,101 @@
+package carbon
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Objects and fields a team source reads from
+const (
+	TeamSourcePod       = "pod"
+	TeamSourceNamespace = "namespace"
+
+	TeamFieldLabel      = "label"
+	TeamFieldAnnotation = "annotation"
+)
+
+// UnassignedTeam collects pods none of the team sources match
+const UnassignedTeam = "__unassigned__"
+
+// TeamSource names a pod or namespace label or annotation holding the owning team,
+// e.g. "team", "app.kubernetes.io/part-of" or "cost-center"
+type TeamSource struct {
+	Object string `json:"object"` // "pod" or "namespace"
+	Field  string `json:"field"`  // "label" or "annotation"
+	Key    string `json:"key"`
+}
+
+// defaultTeamSources reads the team label of the pod, then of its namespace
+var defaultTeamSources = []TeamSource{
+	{Object: TeamSourcePod, Field: TeamFieldLabel, Key: "team"},
+	{Object: TeamSourceNamespace, Field: TeamFieldLabel, Key: "team"},
+}
+
+// lookup returns the value of the source's key on obj
+func (s TeamSource) lookup(obj metav1.Object) string {
+	if s.Field == TeamFieldAnnotation {
+		return obj.GetAnnotations()[s.Key]
+	}
+	return obj.GetLabels()[s.Key]
+}
+
+// teamResolver derives the owning team of pods and namespaces from a fallback chain of sources
+type teamResolver struct {
+	sources    []TeamSource
+	namespaces map[string]*corev1.Namespace
+}
+
+// newTeamResolver creates a resolver trying sources in order, the default sources when empty.
+// namespaces are looked up for namespace sources.
+func newTeamResolver(sources []TeamSource, namespaces []*corev1.Namespace) *teamResolver {
+	if len(sources) == 0 {
+		sources = defaultTeamSources
+	}
+	r := &teamResolver{
+		sources:    sources,
+		namespaces: make(map[string]*corev1.Namespace, len(namespaces)),
+	}
+	for _, ns := range namespaces {
+		r.namespaces[ns.Name] = ns
+	}
+	return r
+}
+
+// podTeam returns the team of pod, UnassignedTeam when no source matches
+func (r *teamResolver) podTeam(pod *corev1.Pod) string {
+	for _, source := range r.sources {
+		var team string
+		switch source.Object {
+		case TeamSourcePod:
+			team = source.lookup(pod)
+		case TeamSourceNamespace:
+			team = r.lookupNamespace(source, pod.Namespace)
+		}
+		if team != "" {
+			return team
+		}
+	}
+	return UnassignedTeam
+}
+
+// namespaceTeam returns the team of the namespace, only namespace sources apply
+func (r *teamResolver) namespaceTeam(namespace string) string {
+	for _, source := range r.sources {
+		if source.Object != TeamSourceNamespace {
+			continue
+		}
+		if team := r.lookupNamespace(source, namespace); team != "" {
+			return team
+		}
+	}
+	return UnassignedTeam
+}
+
+// lookupNamespace returns the value of source on the namespace, empty when it is unknown
+func (r *teamResolver) lookupNamespace(source TeamSource, namespace string) string {
+	ns, ok := r.namespaces[namespace]
+	if !ok {
+		return ""
+	}
+	return source.lookup(ns)
+}
@@ -0,0 +1,134 @@
+package carbon
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTeamResolver(t *testing.T) {
+	namespaces := []*corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "payments", Annotations: map[string]string{"cost-center": "cc-42"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "sandbox"}},
+	}
+	resolver := newTeamResolver([]TeamSource{
+		{Object: TeamSourcePod, Field: TeamFieldLabel, Key: "team"},
+		{Object: TeamSourcePod, Field: TeamFieldLabel, Key: "app.kubernetes.io/part-of"},
+		{Object: TeamSourceNamespace, Field: TeamFieldAnnotation, Key: "cost-center"},
+	}, namespaces)
+
+	labelled := createPodWithResources("api", "payments", "100m", "128Mi")
+	labelled.Labels["team"] = "checkout"
+	partOf := createPodWithResources("worker", "payments", "100m", "128Mi")
+	partOf.Labels["app.kubernetes.io/part-of"] = "billing"
+
+	tests := []struct {
+		name string
+		pod  *corev1.Pod
+		want string
+	}{
+		{"PodLabel", labelled, "checkout"},
+		{"FallbackPodLabel", partOf, "billing"},
+		{"FallbackNamespaceAnnotation", createPodWithResources("cron", "payments", "100m", "128Mi"), "cc-42"},
+		{"Unassigned", createPodWithResources("scratch", "sandbox", "100m", "128Mi"), UnassignedTeam},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolver.podTeam(tt.pod); got != tt.want {
+				t.Errorf("Expected team %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("NamespaceTeam", func(t *testing.T) {
+		if got := resolver.namespaceTeam("payments"); got != "cc-42" {
+			t.Errorf("Expected team cc-42, got %s", got)
+		}
+	})
+}
+
+func TestMetricGrouper(t *testing.T) {
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	metrics := []*Metrics{
+		{Timestamp: at, Team: "checkout", CO2Emissions: 100, EnergyConsumption: 1, GridIntensity: 100, Source: SourceCalculated},
+		{Timestamp: at, Team: "checkout", CO2Emissions: 300, EnergyConsumption: 1, GridIntensity: 300, Source: SourceMeasured},
+		{Timestamp: at.Add(time.Hour), Team: "checkout", CO2Emissions: 50, EnergyConsumption: 1, Source: SourceCalculated},
+		{Timestamp: at, CO2Emissions: 10, EnergyConsumption: 1, Source: SourceCalculated},
+	}
+
+	rollups := newMetricGrouper([]string{GroupByTeam}, nil).group(metrics)
+	if len(rollups) != 3 {
+		t.Fatalf("Expected 3 rollups, got %d", len(rollups))
+	}
+	first := rollups[0]
+	if first.ResourceName != UnassignedTeam {
+		t.Errorf("Expected the unassigned team first, got %s", first.ResourceName)
+	}
+	checkout := rollups[1]
+	if checkout.ResourceName != "checkout" || checkout.CO2Emissions != 400 || checkout.EnergyConsumption != 2 {
+		t.Errorf("Expected checkout with 400 g over 2 kWh, got %s with %f g over %f kWh", checkout.ResourceName, checkout.CO2Emissions, checkout.EnergyConsumption)
+	}
+	if checkout.GridIntensity != 200 {
+		t.Errorf("Expected energy-weighted intensity 200, got %f", checkout.GridIntensity)
+	}
+	if checkout.Source != sourceMixed {
+		t.Errorf("Expected mixed source, got %s", checkout.Source)
+	}
+	if checkout.ResourceType != "team" || checkout.Labels["team"] != "checkout" {
+		t.Errorf("Expected team rollup labelled team=checkout, got %s %v", checkout.ResourceType, checkout.Labels)
+	}
+}
+
+func TestDatasourceTeamQuery(t *testing.T) {
+	ctx := context.Background()
+
+	pods := createTestPods()
+	pods[0].Labels["team"] = "checkout"
+	pods[1].Labels["team"] = "checkout"
+	namespaces := []*corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "production"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "development", Labels: map[string]string{"team": "platform"}}},
+	}
+	calculator := NewCarbonCalculator(&CarbonConfig{DefaultGridIntensity: 400, PUE: 1.0})
+	d := newCarbonFootprintDatasource(calculator, NewFakeKubernetesClient(createTestNodes(), pods, namespaces), nil)
+
+	for name, queryJSON := range map[string]string{
+		"ResourceType": `{"refId":"A","queryType":"table","resourceType":"team"}`,
+		"GroupBy":      `{"refId":"A","queryType":"table","resourceType":"deployment","groupBy":["team"]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			res := d.query(ctx, backend.PluginContext{}, backend.DataQuery{RefID: "A", JSON: []byte(queryJSON)})
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+			if name == "GroupBy" {
+				// The test pods have no owners, so no deployment matches
+				if len(res.Frames) != 0 {
+					t.Errorf("Expected no frames, got %d", len(res.Frames))
+				}
+				return
+			}
+			if rows := res.Frames[0].Rows(); rows != 2 {
+				t.Errorf("Expected checkout and platform, got %d rows", rows)
+			}
+		})
+	}
+
+	t.Run("WorkloadGroupBy", func(t *testing.T) {
+		res := d.query(ctx, backend.PluginContext{}, backend.DataQuery{
+			RefID: "A",
+			JSON:  []byte(`{"refId":"A","queryType":"table","resourceType":"workload","groupBy":["team"]}`),
+		})
+		if res.Error != nil {
+			t.Fatalf("query failed: %v", res.Error)
+		}
+		if rows := res.Frames[0].Rows(); rows != 2 {
+			t.Errorf("Expected a row per team, got %d", rows)
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package carbon
+
+import (
+	"fmt"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// OtherBucket names the row summing the resources a ranking leaves out
+const OtherBucket = "__other__"
+
+// Ranking defaults
+const (
+	defaultTopN   = 10
+	defaultRankBy = "co2_emissions"
+)
+
+// convertToTopKFrames ranks resources by a numeric column summed over the time range and returns
+// the top, or bottom, N as a table, optionally followed by the rest summed into OtherBucket
+func convertToTopKFrames(metrics []*Metrics, query *Query) (data.Frames, error) {
+	rankBy := query.RankBy
+	if rankBy == "" {
+		rankBy = defaultRankBy
+	}
+	columns, err := selectColumns(nil, &Query{Columns: []string{rankBy}})
+	if err != nil || columns[0].number == nil {
+		return nil, fmt.Errorf("rankBy must be a numeric column, got %q", rankBy)
+	}
+	n := query.TopN
+	if n <= 0 {
+		n = defaultTopN
+	}
+
+	rows := summarizeSeries(metrics)
+	if err := sortRows(rows, rankBy, !query.Bottom); err != nil {
+		return nil, err
+	}
+	if len(rows) > n {
+		rest := rows[n:]
+		rows = rows[:n:n]
+		if query.IncludeOther {
+			rows = append(rows, sumOther(rest))
+		}
+	}
+
+	columns, err = selectColumns(rows, query)
+	if err != nil {
+		return nil, err
+	}
+
+	frame := data.NewFrame(query.RefID)
+	for _, column := range columns {
+		frame.Fields = append(frame.Fields, newColumnField(column, rows))
+	}
+
+	return data.Frames{frame.SetMeta(&data.FrameMeta{
+		Type: data.FrameTypeTable,
+	})}, nil
+}
+
+// sumOther sums resources summarized over the time range into the OtherBucket row
+func sumOther(rest []*Metrics) *Metrics {
+	other := &Metrics{
+		Timestamp:      rest[0].Timestamp,
+		ResourceType:   rest[0].ResourceType,
+		ResourceName:   OtherBucket,
+		IntensityBasis: rest[0].IntensityBasis,
+		Source:         rest[0].Source,
+	}
+	for _, m := range rest {
+		if other.IntensityBasis != m.IntensityBasis {
+			other.IntensityBasis = intensityBasisMixed
+		}
+		if other.ResourceType != m.ResourceType {
+			other.ResourceType = ""
+		}
+		other.Source = combineSources(other.Source, m.Source)
+		other.CO2Emissions += m.CO2Emissions
+		other.EnergyConsumption += m.EnergyConsumption
+		other.Cost += m.Cost
+		other.CPUUsage += m.CPUUsage
+		other.MemoryUsage += m.MemoryUsage
+		other.StorageUsage += m.StorageUsage
+		other.NetworkTraffic += m.NetworkTraffic
+	}
+	if other.EnergyConsumption > 0 {
+		other.GridIntensity = other.CO2Emissions / other.EnergyConsumption
+	}
+	return other
+}
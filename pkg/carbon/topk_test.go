@@ -0,0 +1,108 @@
+package carbon
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestTopKFrames(t *testing.T) {
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Pods a to e emit 10 to 50 g, half in each of two steps
+	var metrics []*Metrics
+	for i, name := range []string{"c", "a", "e", "b", "d"} {
+		co2 := float64(map[string]int{"a": 10, "b": 20, "c": 30, "d": 40, "e": 50}[name])
+		for _, step := range []time.Time{at, at.Add(time.Hour)} {
+			metrics = append(metrics, &Metrics{
+				Timestamp:         step,
+				ResourceType:      "pod",
+				ResourceName:      name,
+				CO2Emissions:      co2 / 2,
+				EnergyConsumption: float64(i+1) / 2,
+			})
+		}
+	}
+
+	rank := func(t *testing.T, query *Query) []string {
+		t.Helper()
+		query.RefID = "A"
+		query.QueryType = "topk"
+		query.Columns = []string{"resource", "co2_emissions"}
+		frames, err := ConvertToDataFrames(metrics, query)
+		if err != nil {
+			t.Fatalf("ConvertToDataFrames failed: %v", err)
+		}
+
+		names := make([]string, frames[0].Rows())
+		for i := range names {
+			names[i] = frames[0].Fields[0].At(i).(string)
+		}
+		return names
+	}
+
+	t.Run("Top", func(t *testing.T) {
+		names := rank(t, &Query{TopN: 2})
+		if len(names) != 2 || names[0] != "e" || names[1] != "d" {
+			t.Errorf("Expected [e d], got %v", names)
+		}
+	})
+
+	t.Run("Bottom", func(t *testing.T) {
+		names := rank(t, &Query{TopN: 2, Bottom: true})
+		if len(names) != 2 || names[0] != "a" || names[1] != "b" {
+			t.Errorf("Expected [a b], got %v", names)
+		}
+	})
+
+	t.Run("RankByEnergy", func(t *testing.T) {
+		names := rank(t, &Query{TopN: 1, RankBy: "energy_consumption"})
+		if len(names) != 1 || names[0] != "d" {
+			t.Errorf("Expected [d], got %v", names)
+		}
+	})
+
+	t.Run("Other", func(t *testing.T) {
+		query := &Query{RefID: "A", QueryType: "topk", TopN: 2, IncludeOther: true, Columns: []string{"resource", "co2_emissions"}}
+		frames, err := ConvertToDataFrames(metrics, query)
+		if err != nil {
+			t.Fatalf("ConvertToDataFrames failed: %v", err)
+		}
+
+		frame := frames[0]
+		if frame.Rows() != 3 || frame.Fields[0].At(2) != OtherBucket {
+			t.Fatalf("Expected two pods and the other bucket, got %d rows", frame.Rows())
+		}
+		if other := frame.Fields[1].At(2).(float64); other != 60 {
+			t.Errorf("Expected the other bucket to sum 60 g, got %f", other)
+		}
+	})
+
+	t.Run("InvalidRankBy", func(t *testing.T) {
+		query := &Query{RefID: "A", QueryType: "topk", RankBy: "namespace"}
+		if _, err := ConvertToDataFrames(metrics, query); err == nil {
+			t.Error("Expected error for a text rank column, got nil")
+		}
+	})
+}
+
+func TestDatasourceTopK(t *testing.T) {
+	d := newCarbonFootprintDatasource(NewCarbonCalculator(&CarbonConfig{DefaultGridIntensity: 400, PUE: 1.0}),
+		NewFakeKubernetesClient(createTestNodes(), createTestPods(), nil), nil)
+
+	res := d.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"refId":"A","queryType":"topk","resourceType":"pod","topN":1,"includeOther":true,"columns":["resource"]}`),
+	})
+	if res.Error != nil {
+		t.Fatalf("query failed: %v", res.Error)
+	}
+
+	// test-pod-1 requests the most CPU and memory on the same node
+	frame := res.Frames[0]
+	if frame.Rows() != 2 || frame.Fields[0].At(0) != "test-pod-1" || frame.Fields[0].At(1) != OtherBucket {
+		t.Errorf("Expected test-pod-1 and the other bucket, got %d rows", frame.Rows())
+	}
+}
@@ -0,0 +1,242 @@
+package carbon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ukCarbonIntensityBaseURL = "https://api.carbonintensity.org.uk"
+
+	// The API is free and keyless, keep the load on it modest
+	ukCarbonIntensityRequestsPerSecond = 2
+
+	// Intensity is published per half hour settlement period
+	ukCarbonIntensityPeriod = 30 * time.Minute
+
+	// ukCarbonIntensityTimeFormat is the ISO 8601 form the API expects in paths
+	ukCarbonIntensityTimeFormat = "2006-01-02T15:04Z"
+
+	// ukNationalZone selects the Great Britain wide figures
+	ukNationalZone = "GB"
+
+	// ukRegionCount is the number of regions, numbered from 1, the regional endpoints know
+	ukRegionCount = 17
+)
+
+// ukOutwardPostcode matches the outward part of a postcode such as "SW1A" or "M1"
+var ukOutwardPostcode = regexp.MustCompile(`^[A-Z]{1,2}[0-9][0-9A-Z]?$`)
+
+// ukCarbonIntensityProvider fetches half-hourly intensity from the National Grid ESO Carbon Intensity API.
+// Zones are "GB" for the national figure, a numeric region ID such as "13" (London),
+// or an outward postcode such as "SW1A".
+type ukCarbonIntensityProvider struct {
+	baseURL string
+	client  *http.Client
+	cache   *intensityCache
+	limiter *rateLimiter
+}
+
+// ukIntensityPeriod is one half hour settlement period
+type ukIntensityPeriod struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Intensity struct {
+		Forecast float64  `json:"forecast"`
+		Actual   *float64 `json:"actual"`
+		Index    string   `json:"index"`
+	} `json:"intensity"`
+}
+
+// ukIntensityRegion wraps the periods of a regional response
+type ukIntensityRegion struct {
+	RegionID  int                 `json:"regionid"`
+	ShortName string              `json:"shortname"`
+	Data      []ukIntensityPeriod `json:"data"`
+}
+
+// NewUKCarbonIntensityProvider creates a provider for the keyless UK Carbon Intensity API
+func NewUKCarbonIntensityProvider() GridIntensityProvider {
+	return newUKCarbonIntensityProvider(ukCarbonIntensityBaseURL, &http.Client{Timeout: 10 * time.Second})
+}
+
+func newUKCarbonIntensityProvider(baseURL string, client *http.Client) *ukCarbonIntensityProvider {
+	return &ukCarbonIntensityProvider{
+		baseURL: baseURL,
+		client:  client,
+		cache:   newIntensityCache(),
+		limiter: newRateLimiter(ukCarbonIntensityRequestsPerSecond),
+	}
+}
+
+// GetGridIntensity returns the intensity of the settlement period containing at.
+// One request fetches 48 hours from that period, so neighbouring lookups hit the cache.
+func (u *ukCarbonIntensityProvider) GetGridIntensity(ctx context.Context, zone string, at time.Time) (*IntensityDataPoint, error) {
+	if at.IsZero() {
+		at = time.Now()
+	}
+	zone, err := normalizeUKZone(zone)
+	if err != nil {
+		return nil, err
+	}
+	period := at.UTC().Truncate(ukCarbonIntensityPeriod)
+	key := ukCacheKey(zone, period)
+
+	cached, fresh := u.cache.get(key)
+	if fresh {
+		return cached, nil
+	}
+
+	if _, err := u.GetForecast(ctx, zone, period); err != nil {
+		if cached != nil {
+			return cached, nil
+		}
+		return nil, err
+	}
+
+	if point, _ := u.cache.get(key); point != nil {
+		return point, nil
+	}
+	return nil, fmt.Errorf("uk carbon intensity returned no data for zone %s at %s", zone, period.Format(time.RFC3339))
+}
+
+// GetForecast returns the 48 hour forecast starting at from
+func (u *ukCarbonIntensityProvider) GetForecast(ctx context.Context, zone string, from time.Time) ([]*IntensityDataPoint, error) {
+	zone, err := normalizeUKZone(zone)
+	if err != nil {
+		return nil, err
+	}
+	start := url.PathEscape(from.UTC().Truncate(ukCarbonIntensityPeriod).Format(ukCarbonIntensityTimeFormat))
+
+	var path string
+	switch {
+	case zone == ukNationalZone:
+		path = "/intensity/" + start + "/fw48h"
+	case isUKRegionID(zone):
+		path = "/regional/intensity/" + start + "/fw48h/regionid/" + zone
+	default:
+		path = "/regional/intensity/" + start + "/fw48h/postcode/" + url.PathEscape(zone)
+	}
+
+	periods, err := u.fetch(ctx, path, zone != ukNationalZone)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]*IntensityDataPoint, 0, len(periods))
+	for _, p := range periods {
+		periodStart, err := time.Parse(ukCarbonIntensityTimeFormat, p.From)
+		if err != nil {
+			continue
+		}
+
+		// Prefer the measured figure, only published nationally and for past periods
+		intensity := p.Intensity.Forecast
+		if p.Intensity.Actual != nil {
+			intensity = *p.Intensity.Actual
+		}
+
+		point := &IntensityDataPoint{
+			Timestamp: periodStart,
+			Zone:      zone,
+			Intensity: intensity,
+			Basis:     IntensityBasisAverage,
+			Source:    GridProviderUKCarbonIntensity,
+		}
+		u.cache.set(ukCacheKey(zone, periodStart), point, ukCarbonIntensityPeriod)
+		points = append(points, point)
+	}
+
+	return points, nil
+}
+
+// Name returns the provider identifier
+func (u *ukCarbonIntensityProvider) Name() string {
+	return GridProviderUKCarbonIntensity
+}
+
+// fetch requests path and returns its settlement periods
+func (u *ukCarbonIntensityProvider) fetch(ctx context.Context, path string, regional bool) ([]ukIntensityPeriod, error) {
+	if err := u.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("uk carbon intensity request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		u.limiter.backoff(parseRetryAfter(resp.Header.Get("Retry-After")))
+		return nil, errRateLimited
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("uk carbon intensity returned status %d for %s", resp.StatusCode, path)
+	}
+
+	var body struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode uk carbon intensity response: %w", err)
+	}
+
+	if !regional {
+		var periods []ukIntensityPeriod
+		if err := json.Unmarshal(body.Data, &periods); err != nil {
+			return nil, fmt.Errorf("failed to decode uk carbon intensity periods: %w", err)
+		}
+		return periods, nil
+	}
+
+	// Regional endpoints return either a single region object or a list of one
+	var region ukIntensityRegion
+	if strings.HasPrefix(strings.TrimSpace(string(body.Data)), "[") {
+		var regions []ukIntensityRegion
+		if err := json.Unmarshal(body.Data, &regions); err != nil {
+			return nil, fmt.Errorf("failed to decode uk carbon intensity regions: %w", err)
+		}
+		if len(regions) == 0 {
+			return nil, nil
+		}
+		region = regions[0]
+	} else if err := json.Unmarshal(body.Data, &region); err != nil {
+		return nil, fmt.Errorf("failed to decode uk carbon intensity region: %w", err)
+	}
+
+	return region.Data, nil
+}
+
+// normalizeUKZone upper-cases zone and rejects zones outside Great Britain, so that nodes
+// elsewhere fall back to the default intensity rather than being charged at GB figures
+func normalizeUKZone(zone string) (string, error) {
+	zone = strings.ToUpper(strings.TrimSpace(zone))
+	if zone == ukNationalZone || isUKRegionID(zone) || ukOutwardPostcode.MatchString(zone) {
+		return zone, nil
+	}
+	return "", fmt.Errorf("uk carbon intensity only covers Great Britain, zone %q is not %s, a region ID or an outward postcode", zone, ukNationalZone)
+}
+
+// isUKRegionID reports whether zone is one of the API's numeric region IDs
+func isUKRegionID(zone string) bool {
+	id, err := strconv.Atoi(zone)
+	return err == nil && id >= 1 && id <= ukRegionCount
+}
+
+func ukCacheKey(zone string, period time.Time) string {
+	return zone + "|" + period.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,169 @@
+package carbon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUKCarbonIntensityProvider(t *testing.T) {
+	ctx := context.Background()
+	at := time.Date(2023, 1, 1, 10, 45, 0, 0, time.UTC)
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch r.URL.Path {
+		case "/intensity/2023-01-01T10:30Z/fw48h":
+			w.Write([]byte(`{"data":[
+				{"from":"2023-01-01T10:30Z","to":"2023-01-01T11:00Z","intensity":{"forecast":200,"actual":210,"index":"moderate"}},
+				{"from":"2023-01-01T11:00Z","to":"2023-01-01T11:30Z","intensity":{"forecast":190,"actual":null,"index":"moderate"}}
+			]}`))
+		case "/regional/intensity/2023-01-01T10:30Z/fw48h/regionid/13":
+			w.Write([]byte(`{"data":{"regionid":13,"shortname":"London","data":[
+				{"from":"2023-01-01T10:30Z","to":"2023-01-01T11:00Z","intensity":{"forecast":150,"index":"low"}}
+			]}}`))
+		case "/regional/intensity/2023-01-01T10:30Z/fw48h/postcode/SW1A":
+			w.Write([]byte(`{"data":[{"regionid":13,"shortname":"London","postcode":"SW1A","data":[
+				{"from":"2023-01-01T10:30Z","to":"2023-01-01T11:00Z","intensity":{"forecast":155,"index":"low"}}
+			]}]}`))
+		default:
+			t.Errorf("Unexpected path %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	provider := newUKCarbonIntensityProvider(server.URL, server.Client())
+
+	t.Run("National", func(t *testing.T) {
+		point, err := provider.GetGridIntensity(ctx, "gb", at)
+		if err != nil {
+			t.Fatalf("GetGridIntensity failed: %v", err)
+		}
+		if point.Intensity != 210 {
+			t.Errorf("Expected actual intensity 210, got %f", point.Intensity)
+		}
+		if point.Zone != "GB" {
+			t.Errorf("Expected zone GB, got %s", point.Zone)
+		}
+
+		// The next period comes from the same 48 hour response
+		before := atomic.LoadInt32(&requests)
+		point, err = provider.GetGridIntensity(ctx, "GB", at.Add(30*time.Minute))
+		if err != nil {
+			t.Fatalf("GetGridIntensity failed: %v", err)
+		}
+		if point.Intensity != 190 {
+			t.Errorf("Expected forecast intensity 190, got %f", point.Intensity)
+		}
+		if atomic.LoadInt32(&requests) != before {
+			t.Errorf("Expected forecast period to be served from cache")
+		}
+	})
+
+	t.Run("RegionID", func(t *testing.T) {
+		point, err := provider.GetGridIntensity(ctx, "13", at)
+		if err != nil {
+			t.Fatalf("GetGridIntensity failed: %v", err)
+		}
+		if point.Intensity != 150 {
+			t.Errorf("Expected intensity 150, got %f", point.Intensity)
+		}
+	})
+
+	t.Run("Postcode", func(t *testing.T) {
+		point, err := provider.GetGridIntensity(ctx, "sw1a", at)
+		if err != nil {
+			t.Fatalf("GetGridIntensity failed: %v", err)
+		}
+		if point.Intensity != 155 {
+			t.Errorf("Expected intensity 155, got %f", point.Intensity)
+		}
+	})
+
+	t.Run("OutsideGreatBritain", func(t *testing.T) {
+		for _, zone := range []string{"", "DE", "US-MIDA-PJM", "GB-NIR", "18"} {
+			if _, err := provider.GetGridIntensity(ctx, zone, at); err == nil {
+				t.Errorf("Expected error for zone %q, got nil", zone)
+			}
+		}
+	})
+
+	t.Run("Forecast", func(t *testing.T) {
+		points, err := provider.GetForecast(ctx, "GB", at)
+		if err != nil {
+			t.Fatalf("GetForecast failed: %v", err)
+		}
+		if len(points) != 2 {
+			t.Errorf("Expected 2 forecast points, got %d", len(points))
+		}
+	})
+}
+
+func TestUKCarbonIntensityMixedRegionCluster(t *testing.T) {
+	ctx := context.Background()
+
+	// Only the London region is served; requests for anything else fail the test
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) != 7 || parts[1] != "regional" || parts[6] != "13" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"data":{"regionid":13,"shortname":"London","data":[`+
+			`{"from":"%s","to":"%s","intensity":{"forecast":150,"index":"low"}}]}}`, parts[3], parts[3])
+	}))
+	defer server.Close()
+
+	// A GB default zone must not leak to nodes outside Great Britain
+	calculator := NewCarbonCalculator(
+		&CarbonConfig{DefaultGridIntensity: 400, PUE: 1.0, GridIntensityProvider: GridProviderUKCarbonIntensity, GridZone: "GB"},
+		WithGridIntensityProvider(newUKCarbonIntensityProvider(server.URL, server.Client())),
+	)
+
+	nodes := createTestNodes()
+	nodes[0].Labels[labelTopologyRegion] = "eu-west-2"
+	nodes[1].Labels[labelTopologyRegion] = "us-east-1"
+
+	expected := map[string]float64{"test-node-1": 150, "test-node-2": 400}
+	for _, node := range nodes {
+		metrics, err := calculator.CalculateNodeCarbon(ctx, node, createTestPods(), defaultWindow(time.Now()))
+		if err != nil {
+			t.Fatalf("CalculateNodeCarbon failed: %v", err)
+		}
+		if metrics[0].GridIntensity != expected[node.Name] {
+			t.Errorf("Expected intensity %f for %s, got %f", expected[node.Name], node.Name, metrics[0].GridIntensity)
+		}
+	}
+}
+
+func TestNewGridIntensityProviderSelection(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *CarbonConfig
+		expected string
+	}{
+		{"Default", &CarbonConfig{}, GridProviderStatic},
+		{"ElectricityMapsKey", &CarbonConfig{ElectricityMapsAPIKey: "key"}, GridProviderElectricityMaps},
+		{"GenericKey", &CarbonConfig{GridIntensityAPIKey: "key"}, GridProviderElectricityMaps},
+		{"UKCarbonIntensity", &CarbonConfig{GridIntensityProvider: GridProviderUKCarbonIntensity}, GridProviderUKCarbonIntensity},
+		{"Marginal", &CarbonConfig{IntensityBasis: IntensityBasisMarginal}, GridProviderWattTime},
+		{"ExplicitWattTime", &CarbonConfig{GridIntensityProvider: GridProviderWattTime, IntensityBasis: IntensityBasisMarginal}, GridProviderWattTime},
+		{"ExplicitProviderKeptForMarginal", &CarbonConfig{GridIntensityProvider: GridProviderUKCarbonIntensity, IntensityBasis: IntensityBasisMarginal}, GridProviderUKCarbonIntensity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewGridIntensityProvider(tt.config).Name(); got != tt.expected {
+				t.Errorf("Expected provider %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
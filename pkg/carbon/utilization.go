@@ -0,0 +1,253 @@
+package carbon
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Utilization sources
+const (
+	UtilizationSourceMetricsServer = "metrics-server"
+	UtilizationSourcePrometheus    = "prometheus"
+)
+
+// Allocation modes decide which CPU and memory figures energy is attributed by
+const (
+	// AllocationRequests attributes energy by container resource requests
+	AllocationRequests = "requests"
+	// AllocationUsage attributes energy by measured usage
+	AllocationUsage = "usage"
+	// AllocationMax attributes energy by the larger of requests and usage, per resource
+	AllocationMax = "max"
+)
+
+// metricsServerResolution is how often metrics-server scrapes, there is no point fetching more often
+const metricsServerResolution = 15 * time.Second
+
+// ResourceUsage is the CPU and memory a pod or node uses or requests
+type ResourceUsage struct {
+	CPUMillicores float64
+	MemoryBytes   float64
+}
+
+// UsageSample is the usage measured during the step starting at Timestamp
+type UsageSample struct {
+	Timestamp time.Time
+	ResourceUsage
+}
+
+// UtilizationSource reports measured resource usage of pods and nodes
+type UtilizationSource interface {
+	// PodUsage returns the usage of pod for the steps of window, steps without data are left out
+	PodUsage(ctx context.Context, pod *corev1.Pod, window TimeWindow) ([]UsageSample, error)
+	// NodeUsage returns the usage of node for the steps of window, steps without data are left out
+	NodeUsage(ctx context.Context, node *corev1.Node, window TimeWindow) ([]UsageSample, error)
+}
+
+// NewUtilizationSource creates the utilization source selected in the carbon configuration
+func NewUtilizationSource(config *CarbonConfig, client KubernetesClient) (UtilizationSource, error) {
+	switch config.UtilizationSource {
+	case UtilizationSourcePrometheus:
+		return NewPrometheusUtilizationSource(config.PrometheusURL, config.PrometheusToken)
+	default:
+		return NewMetricsServerUtilizationSource(client), nil
+	}
+}
+
+// metricsServerUtilizationSource serves usage from the metrics.k8s.io API. Every pod and node
+// is fetched in one call and reused until metrics-server has new samples.
+// metrics-server only knows current usage, which is assumed for every step of a window.
+type metricsServerUtilizationSource struct {
+	client KubernetesClient
+
+	mu        sync.Mutex
+	fetchedAt time.Time
+	pods      map[string]ResourceUsage
+	nodes     map[string]ResourceUsage
+	now       func() time.Time
+}
+
+// NewMetricsServerUtilizationSource creates a utilization source backed by metrics-server
+func NewMetricsServerUtilizationSource(client KubernetesClient) UtilizationSource {
+	return &metricsServerUtilizationSource{
+		client: client,
+		now:    time.Now,
+	}
+}
+
+// PodUsage returns the summed current usage of the pod's containers
+func (m *metricsServerUtilizationSource) PodUsage(ctx context.Context, pod *corev1.Pod, window TimeWindow) ([]UsageSample, error) {
+	if err := m.refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	usage, ok := m.pods[pod.Namespace+"/"+pod.Name]
+	if !ok {
+		return nil, fmt.Errorf("no metrics for pod %s/%s", pod.Namespace, pod.Name)
+	}
+	return constantSamples(window, usage), nil
+}
+
+// NodeUsage returns the current usage of the node, including system daemons outside pods
+func (m *metricsServerUtilizationSource) NodeUsage(ctx context.Context, node *corev1.Node, window TimeWindow) ([]UsageSample, error) {
+	if err := m.refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	usage, ok := m.nodes[node.Name]
+	if !ok {
+		return nil, fmt.Errorf("no metrics for node %s", node.Name)
+	}
+	return constantSamples(window, usage), nil
+}
+
+// refresh fetches pod and node metrics unless the cached ones are still current
+func (m *metricsServerUtilizationSource) refresh(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.pods != nil && m.now().Sub(m.fetchedAt) < metricsServerResolution {
+		return nil
+	}
+
+	podMetrics, err := m.client.GetPodMetrics(ctx, "")
+	if err != nil {
+		return err
+	}
+	nodeMetrics, err := m.client.GetNodeMetrics(ctx)
+	if err != nil {
+		return err
+	}
+
+	pods := make(map[string]ResourceUsage, len(podMetrics))
+	for _, pm := range podMetrics {
+		var usage ResourceUsage
+		for _, container := range pm.Containers {
+			usage.CPUMillicores += float64(container.Usage.Cpu().MilliValue())
+			usage.MemoryBytes += float64(container.Usage.Memory().Value())
+		}
+		pods[pm.Namespace+"/"+pm.Name] = usage
+	}
+
+	nodes := make(map[string]ResourceUsage, len(nodeMetrics))
+	for _, nm := range nodeMetrics {
+		nodes[nm.Name] = ResourceUsage{
+			CPUMillicores: float64(nm.Usage.Cpu().MilliValue()),
+			MemoryBytes:   float64(nm.Usage.Memory().Value()),
+		}
+	}
+
+	m.pods, m.nodes, m.fetchedAt = pods, nodes, m.now()
+	return nil
+}
+
+// constantSamples repeats usage for every step of window
+func constantSamples(window TimeWindow, usage ResourceUsage) []UsageSample {
+	steps := window.Steps()
+	samples := make([]UsageSample, 0, len(steps))
+	for _, at := range steps {
+		samples = append(samples, UsageSample{Timestamp: at, ResourceUsage: usage})
+	}
+	return samples
+}
+
+// add accumulates other, which may be nil
+func (u *ResourceUsage) add(other *ResourceUsage) {
+	if other == nil {
+		return
+	}
+	u.CPUMillicores += other.CPUMillicores
+	u.MemoryBytes += other.MemoryBytes
+}
+
+// podRequests sums the resource requests of a pod's containers
+func podRequests(pod *corev1.Pod) ResourceUsage {
+	var requests ResourceUsage
+	for _, container := range pod.Spec.Containers {
+		if cpu := container.Resources.Requests.Cpu(); cpu != nil {
+			requests.CPUMillicores += float64(cpu.MilliValue())
+		}
+		if memory := container.Resources.Requests.Memory(); memory != nil {
+			requests.MemoryBytes += float64(memory.Value())
+		}
+	}
+	return requests
+}
+
+// allocate picks the figures energy is attributed by. Without a measurement requests are used,
+// so clusters without metrics-server keep working in every mode.
+func allocate(mode string, requests ResourceUsage, measured *ResourceUsage) ResourceUsage {
+	if measured == nil {
+		return requests
+	}
+
+	switch mode {
+	case AllocationUsage:
+		return *measured
+	case AllocationMax:
+		allocated := requests
+		if measured.CPUMillicores > allocated.CPUMillicores {
+			allocated.CPUMillicores = measured.CPUMillicores
+		}
+		if measured.MemoryBytes > allocated.MemoryBytes {
+			allocated.MemoryBytes = measured.MemoryBytes
+		}
+		return allocated
+	default:
+		return requests
+	}
+}
+
+// measurePod returns the measured usage of pod for each step of window, nil when no utilization
+// source is set or it has no data, and with nil entries for steps without data. Usage is reported
+// in every allocation mode; the mode only decides whether it drives energy.
+func (c *carbonCalculator) measurePod(ctx context.Context, pod *corev1.Pod, window TimeWindow) []*ResourceUsage {
+	if c.utilization == nil {
+		return nil
+	}
+	samples, err := c.utilization.PodUsage(ctx, pod, window)
+	if err != nil {
+		return nil
+	}
+	return bucketSamples(samples, window)
+}
+
+// measureNode returns the measured usage of node for each step of window, nil when no utilization
+// source is set or it has no data, and with nil entries for steps without data
+func (c *carbonCalculator) measureNode(ctx context.Context, node *corev1.Node, window TimeWindow) []*ResourceUsage {
+	if c.utilization == nil {
+		return nil
+	}
+	samples, err := c.utilization.NodeUsage(ctx, node, window)
+	if err != nil {
+		return nil
+	}
+	return bucketSamples(samples, window)
+}
+
+// bucketSamples places samples at the index of their step
+func bucketSamples(samples []UsageSample, window TimeWindow) []*ResourceUsage {
+	steps := make([]*ResourceUsage, len(window.Steps()))
+	for i := range samples {
+		if idx := window.stepIndex(samples[i].Timestamp); idx >= 0 && idx < len(steps) {
+			steps[idx] = &samples[i].ResourceUsage
+		}
+	}
+	return steps
+}
+
+// stepUsage returns the measurement of step i, nil when there is none
+func stepUsage(measured []*ResourceUsage, i int) *ResourceUsage {
+	if i >= len(measured) {
+		return nil
+	}
+	return measured[i]
+}
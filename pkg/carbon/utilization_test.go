@@ -0,0 +1,180 @@
+package carbon
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+func TestMetricsServerUtilizationSource(t *testing.T) {
+	ctx := context.Background()
+
+	pm := createPodMetrics("production", "test-pod-1", "300m", "512Mi")
+	pm.Containers = append(pm.Containers, metricsv1beta1.ContainerMetrics{
+		Name:  "sidecar",
+		Usage: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("50m"), corev1.ResourceMemory: resource.MustParse("64Mi")},
+	})
+	client := NewFakeKubernetesClient(createTestNodes(), createTestPods(), nil)
+	client.PodMetrics = []*metricsv1beta1.PodMetrics{pm}
+	client.NodeMetrics = []*metricsv1beta1.NodeMetrics{createNodeMetrics("test-node-1", "1200m", "6Gi")}
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	source := NewMetricsServerUtilizationSource(client).(*metricsServerUtilizationSource)
+	source.now = func() time.Time { return now }
+	window := TimeWindow{From: now.Add(-time.Hour), To: now, Step: 30 * time.Minute}
+
+	t.Run("PodUsageSumsContainers", func(t *testing.T) {
+		samples, err := source.PodUsage(ctx, createTestPods()[0], window)
+		if err != nil {
+			t.Fatalf("PodUsage failed: %v", err)
+		}
+		// Current usage is repeated for every step
+		if len(samples) != 2 {
+			t.Fatalf("Expected a sample per step, got %d", len(samples))
+		}
+		usage := samples[1]
+		if usage.CPUMillicores != 350 {
+			t.Errorf("Expected 350 millicores, got %f", usage.CPUMillicores)
+		}
+		if usage.MemoryBytes != 576*1024*1024 {
+			t.Errorf("Expected 576Mi, got %f bytes", usage.MemoryBytes)
+		}
+	})
+
+	t.Run("NodeUsage", func(t *testing.T) {
+		samples, err := source.NodeUsage(ctx, createTestNodes()[0], window)
+		if err != nil {
+			t.Fatalf("NodeUsage failed: %v", err)
+		}
+		if samples[0].CPUMillicores != 1200 {
+			t.Errorf("Expected 1200 millicores, got %f", samples[0].CPUMillicores)
+		}
+	})
+
+	t.Run("MissingPod", func(t *testing.T) {
+		if _, err := source.PodUsage(ctx, createTestPods()[2], window); err == nil {
+			t.Error("Expected error for pod without metrics, got nil")
+		}
+	})
+
+	t.Run("CachedUntilNewSamples", func(t *testing.T) {
+		client.MetricsError = errors.New("metrics-server unavailable")
+		defer func() { client.MetricsError = nil }()
+
+		if _, err := source.PodUsage(ctx, createTestPods()[0], window); err != nil {
+			t.Errorf("Expected cached metrics within the scrape interval, got %v", err)
+		}
+
+		now = now.Add(metricsServerResolution)
+		if _, err := source.PodUsage(ctx, createTestPods()[0], window); err == nil {
+			t.Error("Expected refetch after the scrape interval to fail, got nil")
+		}
+	})
+}
+
+func TestAllocationModes(t *testing.T) {
+	ctx := context.Background()
+	node := createTestNodes()[0]
+	pod := createPodWithResources("test-pod-1", "production", "500m", "1Gi")
+
+	client := NewFakeKubernetesClient([]*corev1.Node{node}, []*corev1.Pod{pod}, nil)
+	client.PodMetrics = []*metricsv1beta1.PodMetrics{createPodMetrics("production", "test-pod-1", "1000m", "512Mi")}
+	client.NodeMetrics = []*metricsv1beta1.NodeMetrics{createNodeMetrics("test-node-1", "1500m", "4Gi")}
+
+	podEnergy := func(mode string) *Metrics {
+		config := &CarbonConfig{DefaultGridIntensity: 500, PUE: 1.0, AllocationMode: mode}
+		calculator := NewCarbonCalculator(config, WithUtilizationSource(NewMetricsServerUtilizationSource(client)))
+		metrics, err := calculator.CalculatePodCarbon(ctx, pod, node, nil, defaultWindow(time.Now()))
+		if err != nil {
+			t.Fatalf("CalculatePodCarbon failed: %v", err)
+		}
+		return metrics[0]
+	}
+
+	requests := podEnergy(AllocationRequests)
+	usage := podEnergy(AllocationUsage)
+	maxAlloc := podEnergy(AllocationMax)
+
+	if usage.EnergyConsumption <= requests.EnergyConsumption {
+		t.Errorf("Expected usage above requests to cost more energy: %f vs %f", usage.EnergyConsumption, requests.EnergyConsumption)
+	}
+	// CPU from usage, memory from requests
+	if maxAlloc.EnergyConsumption <= usage.EnergyConsumption {
+		t.Errorf("Expected max allocation to exceed usage allocation: %f vs %f", maxAlloc.EnergyConsumption, usage.EnergyConsumption)
+	}
+
+	t.Run("MeasuredUsageReported", func(t *testing.T) {
+		if usage.CPUUsage != 1000 {
+			t.Errorf("Expected CPU usage 1000 millicores, got %f", usage.CPUUsage)
+		}
+		if usage.MemoryUsage != 512*1024*1024 {
+			t.Errorf("Expected memory usage 512Mi, got %f bytes", usage.MemoryUsage)
+		}
+		// Requests drive energy, yet measured usage is still reported
+		if requests.CPUUsage != 1000 || requests.MemoryUsage != 512*1024*1024 {
+			t.Errorf("Expected measured usage in requests mode, got %f millicores and %f bytes", requests.CPUUsage, requests.MemoryUsage)
+		}
+	})
+
+	t.Run("NodeReportsUsageInRequestsMode", func(t *testing.T) {
+		config := &CarbonConfig{DefaultGridIntensity: 500, PUE: 1.0, AllocationMode: AllocationRequests}
+		calculator := NewCarbonCalculator(config, WithUtilizationSource(NewMetricsServerUtilizationSource(client)))
+		metrics, err := calculator.CalculateNodeCarbon(ctx, node, []*corev1.Pod{pod}, defaultWindow(time.Now()))
+		if err != nil {
+			t.Fatalf("CalculateNodeCarbon failed: %v", err)
+		}
+		if metrics[0].CPUUsage != 1500 || metrics[0].MemoryUsage != 4*1024*1024*1024 {
+			t.Errorf("Expected node usage of 1500 millicores and 4Gi, got %f and %f", metrics[0].CPUUsage, metrics[0].MemoryUsage)
+		}
+	})
+
+	t.Run("NodeUsesMeasuredUtilization", func(t *testing.T) {
+		config := &CarbonConfig{DefaultGridIntensity: 500, PUE: 1.0, AllocationMode: AllocationUsage}
+		calculator := NewCarbonCalculator(config, WithUtilizationSource(NewMetricsServerUtilizationSource(client)))
+		metrics, err := calculator.CalculateNodeCarbon(ctx, node, []*corev1.Pod{pod}, defaultWindow(time.Now()))
+		if err != nil {
+			t.Fatalf("CalculateNodeCarbon failed: %v", err)
+		}
+		if metrics[0].CPUUsage != 1500 {
+			t.Errorf("Expected node CPU usage 1500 millicores, got %f", metrics[0].CPUUsage)
+		}
+	})
+
+	t.Run("FallsBackToRequestsWithoutMetricsServer", func(t *testing.T) {
+		client.MetricsError = errors.New("the server could not find the requested resource")
+		defer func() { client.MetricsError = nil }()
+
+		if got := podEnergy(AllocationUsage); got.EnergyConsumption != requests.EnergyConsumption {
+			t.Errorf("Expected request-based energy %f, got %f", requests.EnergyConsumption, got.EnergyConsumption)
+		}
+	})
+}
+
+func createPodMetrics(namespace, name, cpu, memory string) *metricsv1beta1.PodMetrics {
+	return &metricsv1beta1.PodMetrics{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Containers: []metricsv1beta1.ContainerMetrics{{
+			Name: "test-container",
+			Usage: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse(cpu),
+				corev1.ResourceMemory: resource.MustParse(memory),
+			},
+		}},
+	}
+}
+
+func createNodeMetrics(name, cpu, memory string) *metricsv1beta1.NodeMetrics {
+	return &metricsv1beta1.NodeMetrics{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Usage: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpu),
+			corev1.ResourceMemory: resource.MustParse(memory),
+		},
+	}
+}
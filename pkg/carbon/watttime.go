@@ -0,0 +1,301 @@
+package carbon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	wattTimeBaseURL = "https://api.watttime.org"
+
+	// WattTime allows 10 requests per second per account
+	wattTimeRequestsPerSecond = 10
+
+	// MOER is published every five minutes, tokens are valid for thirty
+	wattTimeInterval = 5 * time.Minute
+	wattTimeTokenTTL = 25 * time.Minute
+	wattTimePastTTL  = 24 * time.Hour
+
+	// The historical endpoint serves at most 32 days per request
+	wattTimeMaxRange = 32 * 24 * time.Hour
+
+	wattTimeSignalMOER = "co2_moer"
+
+	// lbsPerMWhToGramsPerKWh converts WattTime's lbs CO2/MWh to gCO2/kWh
+	lbsPerMWhToGramsPerKWh = 0.453592
+)
+
+// wattTimeProvider fetches marginal operating emissions rates (MOER) from the WattTime API
+type wattTimeProvider struct {
+	baseURL  string
+	username string
+	password string
+	client   *http.Client
+	cache    *intensityCache
+	limiter  *rateLimiter
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// wattTimeSignalResponse is the payload of the forecast and historical endpoints
+type wattTimeSignalResponse struct {
+	Data []struct {
+		PointTime time.Time `json:"point_time"`
+		Value     float64   `json:"value"`
+	} `json:"data"`
+}
+
+// NewWattTimeProvider creates a marginal intensity provider for the WattTime API
+func NewWattTimeProvider(username, password string) GridIntensityProvider {
+	return newWattTimeProvider(wattTimeBaseURL, username, password, &http.Client{Timeout: 10 * time.Second})
+}
+
+func newWattTimeProvider(baseURL, username, password string, client *http.Client) *wattTimeProvider {
+	return &wattTimeProvider{
+		baseURL:  baseURL,
+		username: username,
+		password: password,
+		client:   client,
+		cache:    newIntensityCache(),
+		limiter:  newRateLimiter(wattTimeRequestsPerSecond),
+	}
+}
+
+// GetGridIntensity returns the MOER for a WattTime balancing authority region, e.g. "CAISO_NORTH"
+func (w *wattTimeProvider) GetGridIntensity(ctx context.Context, region string, at time.Time) (*IntensityDataPoint, error) {
+	if region == "" {
+		return nil, fmt.Errorf("watttime: region is required")
+	}
+	if at.IsZero() || time.Since(at) < wattTimeInterval {
+		return w.latest(ctx, region)
+	}
+	return w.past(ctx, region, at)
+}
+
+// latest returns the current MOER of region
+func (w *wattTimeProvider) latest(ctx context.Context, region string) (*IntensityDataPoint, error) {
+	key := region + "|latest"
+	cached, fresh := w.cache.get(key)
+	if fresh {
+		return cached, nil
+	}
+
+	params := url.Values{"region": {region}, "signal_type": {wattTimeSignalMOER}, "horizon_hours": {"0"}}
+	var body wattTimeSignalResponse
+	if err := w.get(ctx, "/v3/forecast", params, &body); err != nil {
+		if cached != nil {
+			return cached, nil
+		}
+		return nil, err
+	}
+	if len(body.Data) == 0 {
+		return nil, fmt.Errorf("watttime returned no data for region %s", region)
+	}
+
+	point := newWattTimePoint(region, body.Data[0].PointTime, body.Data[0].Value)
+	w.cache.set(key, point, wattTimeInterval)
+	return point, nil
+}
+
+// past returns the MOER of the five minutes holding at. Times outside a loaded range
+// load the whole day around them, so a query never falls back to one request per interval.
+func (w *wattTimeProvider) past(ctx context.Context, region string, at time.Time) (*IntensityDataPoint, error) {
+	interval := at.UTC().Truncate(wattTimeInterval)
+	key := wattTimePastKey(region, interval)
+	cached, fresh := w.cache.get(key)
+	if fresh {
+		return cached, nil
+	}
+
+	day := interval.Truncate(24 * time.Hour)
+	if err := w.LoadRange(ctx, region, day, day.Add(24*time.Hour)); err != nil {
+		if cached != nil {
+			return cached, nil
+		}
+		return nil, err
+	}
+
+	if point, _ := w.cache.get(key); point != nil {
+		return point, nil
+	}
+	return nil, fmt.Errorf("watttime has no data for region %s at %s", region, interval.Format(time.RFC3339))
+}
+
+// LoadRange caches the MOER history of region from from to to, bucketed into five minute intervals.
+// Intervals recent enough to be served by the forecast endpoint are left out.
+func (w *wattTimeProvider) LoadRange(ctx context.Context, region string, from, to time.Time) error {
+	if region == "" {
+		return fmt.Errorf("watttime: region is required")
+	}
+
+	from = from.UTC().Truncate(wattTimeInterval)
+	to = to.UTC().Add(wattTimeInterval - 1).Truncate(wattTimeInterval)
+	if end := time.Now().UTC().Add(-wattTimeInterval).Truncate(wattTimeInterval).Add(wattTimeInterval); to.After(end) {
+		to = end
+	}
+	if !from.Before(to) {
+		return nil
+	}
+
+	key := region + "|" + from.Format(time.RFC3339) + "|" + to.Format(time.RFC3339)
+	if loaded, err := w.cache.rangeLoaded(key); loaded {
+		return err
+	}
+
+	var err error
+	for start := from; start.Before(to) && err == nil; start = start.Add(wattTimeMaxRange) {
+		end := start.Add(wattTimeMaxRange)
+		if end.After(to) {
+			end = to
+		}
+		err = w.loadHistorical(ctx, region, start, end)
+	}
+	w.cache.setRangeLoaded(key, err, wattTimePastTTL)
+	return err
+}
+
+// loadHistorical caches the MOER of region from start up to end in a single request
+func (w *wattTimeProvider) loadHistorical(ctx context.Context, region string, start, end time.Time) error {
+	params := url.Values{
+		"region":      {region},
+		"signal_type": {wattTimeSignalMOER},
+		"start":       {start.Format(time.RFC3339)},
+		"end":         {end.Format(time.RFC3339)},
+	}
+
+	var body wattTimeSignalResponse
+	if err := w.get(ctx, "/v3/historical", params, &body); err != nil {
+		return err
+	}
+
+	for _, value := range body.Data {
+		interval := value.PointTime.UTC().Truncate(wattTimeInterval)
+		if interval.Before(start) || !interval.Before(end) {
+			continue
+		}
+		w.cache.set(wattTimePastKey(region, interval), newWattTimePoint(region, value.PointTime, value.Value), wattTimePastTTL)
+	}
+	return nil
+}
+
+// newWattTimePoint converts a MOER value in lbs/MWh
+func newWattTimePoint(region string, at time.Time, value float64) *IntensityDataPoint {
+	return &IntensityDataPoint{
+		Timestamp: at,
+		Zone:      region,
+		Intensity: value * lbsPerMWhToGramsPerKWh,
+		Basis:     IntensityBasisMarginal,
+		Source:    GridProviderWattTime,
+	}
+}
+
+// wattTimePastKey is the cache key of a five minute interval of region's history
+func wattTimePastKey(region string, interval time.Time) string {
+	return region + "|" + interval.Format(time.RFC3339)
+}
+
+// Name returns the provider identifier
+func (w *wattTimeProvider) Name() string {
+	return GridProviderWattTime
+}
+
+// get performs an authenticated request, logging in again once if the token was rejected
+func (w *wattTimeProvider) get(ctx context.Context, endpoint string, params url.Values, out interface{}) error {
+	for attempt := 0; attempt < 2; attempt++ {
+		token, err := w.getToken(ctx, attempt > 0)
+		if err != nil {
+			return err
+		}
+
+		status, err := w.do(ctx, endpoint+"?"+params.Encode(), "Bearer "+token, out)
+		if err != nil {
+			return err
+		}
+		if status != http.StatusUnauthorized {
+			return nil
+		}
+	}
+	return fmt.Errorf("watttime rejected the access token")
+}
+
+// getToken returns a cached token or logs in for a new one
+func (w *wattTimeProvider) getToken(ctx context.Context, refresh bool) (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !refresh && w.token != "" && time.Now().Before(w.tokenExpiry) {
+		return w.token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.baseURL+"/login", nil)
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(w.username, w.password)
+
+	var body struct {
+		Token string `json:"token"`
+	}
+	status, err := w.send(ctx, req, &body)
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusOK || body.Token == "" {
+		return "", fmt.Errorf("watttime login failed with status %d", status)
+	}
+
+	w.token = body.Token
+	w.tokenExpiry = time.Now().Add(wattTimeTokenTTL)
+	return w.token, nil
+}
+
+// do sends a GET request with the given authorization; 401 is reported through the status
+func (w *wattTimeProvider) do(ctx context.Context, path, authorization string, out interface{}) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.baseURL+path, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", authorization)
+
+	status, err := w.send(ctx, req, out)
+	if err != nil {
+		return status, err
+	}
+	if status != http.StatusOK && status != http.StatusUnauthorized {
+		return status, fmt.Errorf("watttime returned status %d for %s", status, path)
+	}
+	return status, nil
+}
+
+// send applies rate limiting and decodes successful responses into out
+func (w *wattTimeProvider) send(ctx context.Context, req *http.Request, out interface{}) (int, error) {
+	if err := w.limiter.wait(ctx); err != nil {
+		return 0, err
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("watttime request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		w.limiter.backoff(parseRetryAfter(resp.Header.Get("Retry-After")))
+		return resp.StatusCode, errRateLimited
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return resp.StatusCode, fmt.Errorf("failed to decode watttime response: %w", err)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,200 @@
+package carbon
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWattTimeProvider(t *testing.T) {
+	ctx := context.Background()
+
+	newServer := func(logins *int32, expireFirstToken bool) *httptest.Server {
+		var rejected int32
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/login":
+				user, pass, ok := r.BasicAuth()
+				if !ok || user != "grid" || pass != "secret" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				atomic.AddInt32(logins, 1)
+				w.Write([]byte(`{"token":"abc"}`))
+			case "/v3/forecast", "/v3/historical":
+				if r.Header.Get("Authorization") != "Bearer abc" {
+					t.Errorf("Expected bearer token, got %q", r.Header.Get("Authorization"))
+				}
+				if expireFirstToken && atomic.CompareAndSwapInt32(&rejected, 0, 1) {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				if r.URL.Query().Get("signal_type") != "co2_moer" {
+					t.Errorf("Expected co2_moer signal, got %s", r.URL.Query().Get("signal_type"))
+				}
+				w.Write([]byte(`{"data":[{"point_time":"2023-01-01T10:00:00Z","value":1000}],"meta":{"units":"lbs_co2_per_mwh"}}`))
+			default:
+				t.Errorf("Unexpected path %s", r.URL.Path)
+			}
+		}))
+	}
+
+	t.Run("Latest", func(t *testing.T) {
+		var logins int32
+		server := newServer(&logins, false)
+		defer server.Close()
+
+		provider := newWattTimeProvider(server.URL, "grid", "secret", server.Client())
+
+		for i := 0; i < 2; i++ {
+			point, err := provider.GetGridIntensity(ctx, "CAISO_NORTH", time.Time{})
+			if err != nil {
+				t.Fatalf("GetGridIntensity failed: %v", err)
+			}
+			// 1000 lbs/MWh is 453.592 gCO2/kWh
+			if math.Abs(point.Intensity-453.592) > 0.001 {
+				t.Errorf("Expected 453.592 gCO2/kWh, got %f", point.Intensity)
+			}
+			if point.Basis != IntensityBasisMarginal {
+				t.Errorf("Expected marginal basis, got %s", point.Basis)
+			}
+		}
+
+		if logins != 1 {
+			t.Errorf("Expected a single login, got %d", logins)
+		}
+	})
+
+	t.Run("Historical", func(t *testing.T) {
+		var logins int32
+		server := newServer(&logins, false)
+		defer server.Close()
+
+		provider := newWattTimeProvider(server.URL, "grid", "secret", server.Client())
+
+		point, err := provider.GetGridIntensity(ctx, "PJM_DC", time.Date(2023, 1, 1, 10, 2, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatalf("GetGridIntensity failed: %v", err)
+		}
+		if point.Zone != "PJM_DC" {
+			t.Errorf("Expected zone PJM_DC, got %s", point.Zone)
+		}
+	})
+
+	t.Run("HistoricalRange", func(t *testing.T) {
+		var requests int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/login" {
+				w.Write([]byte(`{"token":"abc"}`))
+				return
+			}
+			atomic.AddInt32(&requests, 1)
+			if r.URL.Path != "/v3/historical" {
+				t.Errorf("Unexpected path %s", r.URL.Path)
+			}
+
+			start, _ := time.Parse(time.RFC3339, r.URL.Query().Get("start"))
+			end, _ := time.Parse(time.RFC3339, r.URL.Query().Get("end"))
+			var values []string
+			for at := start; at.Before(end); at = at.Add(wattTimeInterval) {
+				values = append(values, fmt.Sprintf(`{"point_time":"%s","value":%d}`, at.Format(time.RFC3339), at.Day()))
+			}
+			fmt.Fprintf(w, `{"data":[%s]}`, strings.Join(values, ","))
+		}))
+		defer server.Close()
+
+		provider := newWattTimeProvider(server.URL, "grid", "secret", server.Client())
+
+		// Thirty days of five minute values take a single request
+		from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		if err := provider.LoadRange(ctx, "PJM_DC", from, from.AddDate(0, 0, 30)); err != nil {
+			t.Fatalf("LoadRange failed: %v", err)
+		}
+		for at := from.Add(2 * time.Minute); at.Before(from.AddDate(0, 0, 30)); at = at.Add(13 * time.Hour) {
+			point, err := provider.GetGridIntensity(ctx, "PJM_DC", at)
+			if err != nil {
+				t.Fatalf("GetGridIntensity failed: %v", err)
+			}
+			if want := float64(at.Day()) * lbsPerMWhToGramsPerKWh; math.Abs(point.Intensity-want) > 1e-9 {
+				t.Errorf("Expected %f gCO2/kWh at %s, got %f", want, at, point.Intensity)
+			}
+		}
+		if requests != 1 {
+			t.Errorf("Expected 1 upstream request, got %d", requests)
+		}
+	})
+
+	t.Run("ExpiredToken", func(t *testing.T) {
+		var logins int32
+		server := newServer(&logins, true)
+		defer server.Close()
+
+		provider := newWattTimeProvider(server.URL, "grid", "secret", server.Client())
+
+		if _, err := provider.GetGridIntensity(ctx, "CAISO_NORTH", time.Time{}); err != nil {
+			t.Fatalf("GetGridIntensity failed: %v", err)
+		}
+		if logins != 2 {
+			t.Errorf("Expected a second login after the token was rejected, got %d", logins)
+		}
+	})
+
+	t.Run("BadCredentials", func(t *testing.T) {
+		var logins int32
+		server := newServer(&logins, false)
+		defer server.Close()
+
+		provider := newWattTimeProvider(server.URL, "grid", "wrong", server.Client())
+
+		if _, err := provider.GetGridIntensity(ctx, "CAISO_NORTH", time.Time{}); err == nil {
+			t.Error("Expected error for bad credentials, got nil")
+		}
+	})
+}
+
+func TestMetricsReportIntensityBasis(t *testing.T) {
+	ctx := context.Background()
+	pod := createTestPods()[0]
+
+	calculator := NewCarbonCalculator(&CarbonConfig{DefaultGridIntensity: 400, PUE: 1.0})
+	metrics, err := calculator.CalculatePodCarbon(ctx, pod, nil, nil, defaultWindow(time.Now()))
+	if err != nil {
+		t.Fatalf("CalculatePodCarbon failed: %v", err)
+	}
+	if metrics[0].IntensityBasis != IntensityBasisAverage {
+		t.Errorf("Expected average basis, got %s", metrics[0].IntensityBasis)
+	}
+
+	var logins int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			atomic.AddInt32(&logins, 1)
+			w.Write([]byte(`{"token":"abc"}`))
+			return
+		}
+		start := r.URL.Query().Get("start")
+		if start == "" {
+			start = time.Now().UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, `{"data":[{"point_time":"%s","value":1000}]}`, start)
+	}))
+	defer server.Close()
+
+	calculator = NewCarbonCalculator(
+		&CarbonConfig{DefaultGridIntensity: 400, PUE: 1.0, IntensityBasis: IntensityBasisMarginal, GridZone: "CAISO_NORTH"},
+		WithGridIntensityProvider(newWattTimeProvider(server.URL, "grid", "secret", server.Client())),
+	)
+	metrics, err = calculator.CalculatePodCarbon(ctx, pod, nil, nil, defaultWindow(time.Now()))
+	if err != nil {
+		t.Fatalf("CalculatePodCarbon failed: %v", err)
+	}
+	if metrics[0].IntensityBasis != IntensityBasisMarginal {
+		t.Errorf("Expected marginal basis, got %s", metrics[0].IntensityBasis)
+	}
+}
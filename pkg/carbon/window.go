@@ -0,0 +1,150 @@
+package carbon
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// defaultWindowLength is the period calculated when no time range is given
+const defaultWindowLength = time.Hour
+
+// maxStepsPerQuery bounds the points per series when Grafana does not ask for fewer
+const maxStepsPerQuery = 1000
+
+// TimeWindow is the period energy is calculated over, divided into steps
+type TimeWindow struct {
+	From time.Time
+	To   time.Time
+	Step time.Duration // zero means a single step covering the whole window
+}
+
+// defaultWindow returns the hour up to now as a single step
+func defaultWindow(now time.Time) TimeWindow {
+	return TimeWindow{From: now.Add(-defaultWindowLength), To: now, Step: defaultWindowLength}
+}
+
+// Duration returns the length of the window
+func (w TimeWindow) Duration() time.Duration {
+	return w.To.Sub(w.From)
+}
+
+// stepLength returns the step size, the whole window when no step is set
+func (w TimeWindow) stepLength() time.Duration {
+	if w.Step <= 0 || w.Step > w.Duration() {
+		return w.Duration()
+	}
+	return w.Step
+}
+
+// Steps returns the start of every step. The last step is cut short when the
+// window is not a multiple of the step.
+func (w TimeWindow) Steps() []time.Time {
+	step := w.stepLength()
+	if step <= 0 {
+		return nil
+	}
+
+	var starts []time.Time
+	for at := w.From; at.Before(w.To); at = at.Add(step) {
+		starts = append(starts, at)
+	}
+	return starts
+}
+
+// stepDuration returns the length of the step starting at start
+func (w TimeWindow) stepDuration(start time.Time) time.Duration {
+	end := start.Add(w.stepLength())
+	if end.After(w.To) {
+		end = w.To
+	}
+	return end.Sub(start)
+}
+
+// stepIndex returns the index of the step containing at, or -1 when at is outside the window
+func (w TimeWindow) stepIndex(at time.Time) int {
+	if at.Before(w.From) || !at.Before(w.To) {
+		return -1
+	}
+	return int(at.Sub(w.From) / w.stepLength())
+}
+
+// Periods a comparison query compares the selected range to
+const (
+	CompareToPrevious = "previous" // the period of the same length just before, the default
+	CompareToWeek     = "week"     // the same period one week earlier
+	CompareToMonth    = "month"    // the same period one calendar month earlier
+)
+
+// comparisonWindow returns window moved back to the period it is compared to, keeping its step.
+// Month shifts follow time.AddDate, which normalizes days the month before lacks: March 31 moves to March 3.
+func comparisonWindow(window TimeWindow, compareTo string) (TimeWindow, error) {
+	var shift func(time.Time) time.Time
+	switch compareTo {
+	case "", CompareToPrevious:
+		length := window.Duration()
+		shift = func(t time.Time) time.Time { return t.Add(-length) }
+	case CompareToWeek:
+		shift = func(t time.Time) time.Time { return t.AddDate(0, 0, -7) }
+	case CompareToMonth:
+		shift = func(t time.Time) time.Time { return t.AddDate(0, -1, 0) }
+	default:
+		return TimeWindow{}, fmt.Errorf("compareTo must be %q, %q or %q, got %q", CompareToPrevious, CompareToWeek, CompareToMonth, compareTo)
+	}
+	return TimeWindow{From: shift(window.From), To: shift(window.To), Step: window.Step}, nil
+}
+
+// queryWindow returns the window a query covers. The time range set in the query itself wins over
+// the dashboard range; steps follow the panel interval, widened to stay within MaxDataPoints.
+// Only a query without any time range falls back to the default window.
+func queryWindow(dq backend.DataQuery, query *Query, now time.Time) (TimeWindow, error) {
+	from, to := dq.TimeRange.From, dq.TimeRange.To
+	if query.TimeRange.From != "" {
+		t, err := parseTimeRangeBound(query.TimeRange.From)
+		if err != nil {
+			return TimeWindow{}, fmt.Errorf("invalid timeRange.from: %w", err)
+		}
+		from = t
+	}
+	if query.TimeRange.To != "" {
+		t, err := parseTimeRangeBound(query.TimeRange.To)
+		if err != nil {
+			return TimeWindow{}, fmt.Errorf("invalid timeRange.to: %w", err)
+		}
+		to = t
+	}
+	if !to.After(from) {
+		switch {
+		case query.TimeRange.From != "" || query.TimeRange.To != "":
+			return TimeWindow{}, fmt.Errorf("timeRange.to must be after timeRange.from")
+		case from.IsZero() && to.IsZero():
+			// Queries sent outside a dashboard carry no range
+			return defaultWindow(now), nil
+		default:
+			return TimeWindow{}, fmt.Errorf("time range end %s must be after its start %s", to, from)
+		}
+	}
+
+	window := TimeWindow{From: from, To: to, Step: dq.Interval}
+
+	maxSteps := int64(maxStepsPerQuery)
+	if dq.MaxDataPoints > 0 && dq.MaxDataPoints < maxSteps {
+		maxSteps = dq.MaxDataPoints
+	}
+	// Round up so the window never needs more than maxSteps steps
+	minStep := (window.Duration() + time.Duration(maxSteps) - 1) / time.Duration(maxSteps)
+	if window.Step < minStep {
+		window.Step = minStep
+	}
+	return window, nil
+}
+
+// parseTimeRangeBound parses an RFC 3339 time or milliseconds since the epoch
+func parseTimeRangeBound(value string) (time.Time, error) {
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.UnixMilli(ms).UTC(), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
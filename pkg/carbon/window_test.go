@@ -0,0 +1,30 @@
+package carbon
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeWindowSteps(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := TimeWindow{From: from, To: from.Add(150 * time.Minute), Step: time.Hour}
+
+	steps := window.Steps()
+	if len(steps) != 3 {
+		t.Fatalf("Expected 3 steps, got %d", len(steps))
+	}
+	if got := window.stepDuration(steps[2]); got != 30*time.Minute {
+		t.Errorf("Expected the last step to be cut to 30m, got %s", got)
+	}
+	if idx := window.stepIndex(from.Add(90 * time.Minute)); idx != 1 {
+		t.Errorf("Expected step index 1, got %d", idx)
+	}
+	if idx := window.stepIndex(window.To); idx != -1 {
+		t.Errorf("Expected the window end to be outside the window, got %d", idx)
+	}
+
+	single := TimeWindow{From: from, To: from.Add(time.Hour)}
+	if len(single.Steps()) != 1 || single.stepDuration(from) != time.Hour {
+		t.Error("Expected a window without step to be a single step")
+	}
+}
@@ -0,0 +1,127 @@
+package carbon
+
+import (
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Workload kinds pods are rolled up to
+const (
+	WorkloadKindDeployment  = "Deployment"
+	WorkloadKindStatefulSet = "StatefulSet"
+	WorkloadKindDaemonSet   = "DaemonSet"
+	WorkloadKindReplicaSet  = "ReplicaSet"
+	WorkloadKindJob         = "Job"
+	WorkloadKindCronJob     = "CronJob"
+	// WorkloadKindPod is the kind of pods without a controller, they are their own workload
+	WorkloadKindPod = "Pod"
+)
+
+// workloadResourceTypes maps Query.ResourceType to the workload kind it selects.
+// "workload" selects every kind and is not listed.
+var workloadResourceTypes = map[string]string{
+	"deployment":  WorkloadKindDeployment,
+	"statefulset": WorkloadKindStatefulSet,
+	"daemonset":   WorkloadKindDaemonSet,
+	"job":         WorkloadKindJob,
+	"cronjob":     WorkloadKindCronJob,
+}
+
+// Workload is the top-level controller owning a set of pods, e.g. the Deployment behind
+// the pods of its current and past ReplicaSets
+type Workload struct {
+	Kind      string
+	Name      string
+	Namespace string
+	Pods      []*corev1.Pod
+}
+
+// ownerResolver walks pod owner references up to the top-level controller
+type ownerResolver struct {
+	replicaSets map[string]*appsv1.ReplicaSet // namespace/name
+	jobs        map[string]*batchv1.Job       // namespace/name
+}
+
+// newOwnerResolver creates a resolver looking up intermediate owners in replicaSets and jobs
+func newOwnerResolver(replicaSets []*appsv1.ReplicaSet, jobs []*batchv1.Job) *ownerResolver {
+	r := &ownerResolver{
+		replicaSets: make(map[string]*appsv1.ReplicaSet, len(replicaSets)),
+		jobs:        make(map[string]*batchv1.Job, len(jobs)),
+	}
+	for _, rs := range replicaSets {
+		r.replicaSets[rs.Namespace+"/"+rs.Name] = rs
+	}
+	for _, job := range jobs {
+		r.jobs[job.Namespace+"/"+job.Name] = job
+	}
+	return r
+}
+
+// resolve returns the kind and name of the workload owning pod.
+// ReplicaSets resolve to their Deployment and Jobs to their CronJob.
+func (r *ownerResolver) resolve(pod *corev1.Pod) (string, string) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return WorkloadKindPod, pod.Name
+	}
+
+	switch owner.Kind {
+	case WorkloadKindReplicaSet:
+		if rs, ok := r.replicaSets[pod.Namespace+"/"+owner.Name]; ok {
+			if rsOwner := metav1.GetControllerOf(rs); rsOwner != nil && rsOwner.Kind == WorkloadKindDeployment {
+				return WorkloadKindDeployment, rsOwner.Name
+			}
+			return WorkloadKindReplicaSet, owner.Name
+		}
+		// Not cached yet: Deployments name their ReplicaSets <deployment>-<pod-template-hash>
+		if hash := pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey]; hash != "" && strings.HasSuffix(owner.Name, "-"+hash) {
+			return WorkloadKindDeployment, strings.TrimSuffix(owner.Name, "-"+hash)
+		}
+		return WorkloadKindReplicaSet, owner.Name
+	case WorkloadKindJob:
+		if job, ok := r.jobs[pod.Namespace+"/"+owner.Name]; ok {
+			if jobOwner := metav1.GetControllerOf(job); jobOwner != nil && jobOwner.Kind == WorkloadKindCronJob {
+				return WorkloadKindCronJob, jobOwner.Name
+			}
+		}
+		return WorkloadKindJob, owner.Name
+	default:
+		return owner.Kind, owner.Name
+	}
+}
+
+// groupPodsByWorkload rolls pods up to their workloads, sorted by namespace, kind and name
+func (r *ownerResolver) groupPodsByWorkload(pods []*corev1.Pod) []*Workload {
+	byKey := make(map[string]*Workload)
+	for _, pod := range pods {
+		kind, name := r.resolve(pod)
+		key := pod.Namespace + "/" + kind + "/" + name
+		workload, ok := byKey[key]
+		if !ok {
+			workload = &Workload{Kind: kind, Name: name, Namespace: pod.Namespace}
+			byKey[key] = workload
+		}
+		workload.Pods = append(workload.Pods, pod)
+	}
+
+	workloads := make([]*Workload, 0, len(byKey))
+	for _, workload := range byKey {
+		workloads = append(workloads, workload)
+	}
+	sort.Slice(workloads, func(i, j int) bool {
+		a, b := workloads[i], workloads[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	return workloads
+}
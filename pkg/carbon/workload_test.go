@@ -0,0 +1,173 @@
+package carbon
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestOwnerResolver(t *testing.T) {
+	replicaSets := []*appsv1.ReplicaSet{
+		{ObjectMeta: ownedBy("web-7d4b9c", "production", "Deployment", "web")},
+		{ObjectMeta: ownedBy("standalone", "production", "", "")},
+	}
+	jobs := []*batchv1.Job{
+		{ObjectMeta: ownedBy("backup-28374650", "production", "CronJob", "backup")},
+		{ObjectMeta: ownedBy("migrate", "production", "", "")},
+	}
+	resolver := newOwnerResolver(replicaSets, jobs)
+
+	uncached := createOwnedPod("api-5f6d8-x2x9z", "production", "ReplicaSet", "api-5f6d8")
+	uncached.Labels[appsv1.DefaultDeploymentUniqueLabelKey] = "5f6d8"
+
+	tests := []struct {
+		name     string
+		pod      *corev1.Pod
+		wantKind string
+		wantName string
+	}{
+		{"Deployment", createOwnedPod("web-7d4b9c-abcde", "production", "ReplicaSet", "web-7d4b9c"), WorkloadKindDeployment, "web"},
+		{"UncachedReplicaSet", uncached, WorkloadKindDeployment, "api"},
+		{"BareReplicaSet", createOwnedPod("standalone-abcde", "production", "ReplicaSet", "standalone"), WorkloadKindReplicaSet, "standalone"},
+		{"StatefulSet", createOwnedPod("db-0", "production", "StatefulSet", "db"), WorkloadKindStatefulSet, "db"},
+		{"DaemonSet", createOwnedPod("agent-abcde", "production", "DaemonSet", "agent"), WorkloadKindDaemonSet, "agent"},
+		{"CronJob", createOwnedPod("backup-28374650-abcde", "production", "Job", "backup-28374650"), WorkloadKindCronJob, "backup"},
+		{"Job", createOwnedPod("migrate-abcde", "production", "Job", "migrate"), WorkloadKindJob, "migrate"},
+		{"BarePod", createPodWithResources("debug", "production", "100m", "128Mi"), WorkloadKindPod, "debug"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, name := resolver.resolve(tt.pod)
+			if kind != tt.wantKind || name != tt.wantName {
+				t.Errorf("Expected %s/%s, got %s/%s", tt.wantKind, tt.wantName, kind, name)
+			}
+		})
+	}
+
+	t.Run("GroupPodsByWorkload", func(t *testing.T) {
+		pods := []*corev1.Pod{
+			createOwnedPod("web-7d4b9c-abcde", "production", "ReplicaSet", "web-7d4b9c"),
+			createOwnedPod("web-7d4b9c-fghij", "production", "ReplicaSet", "web-7d4b9c"),
+			createOwnedPod("db-0", "production", "StatefulSet", "db"),
+		}
+		workloads := resolver.groupPodsByWorkload(pods)
+		if len(workloads) != 2 {
+			t.Fatalf("Expected 2 workloads, got %d", len(workloads))
+		}
+		if workloads[0].Kind != WorkloadKindDeployment || len(workloads[0].Pods) != 2 {
+			t.Errorf("Expected the Deployment with 2 pods first, got %s with %d", workloads[0].Kind, len(workloads[0].Pods))
+		}
+	})
+}
+
+func TestDatasourceWorkloadQuery(t *testing.T) {
+	ctx := context.Background()
+
+	nodes := createTestNodes()
+	pods := []*corev1.Pod{
+		createOwnedPod("web-7d4b9c-abcde", "production", "ReplicaSet", "web-7d4b9c"),
+		createOwnedPod("web-7d4b9c-fghij", "production", "ReplicaSet", "web-7d4b9c"),
+		createOwnedPod("backup-28374650-abcde", "production", "Job", "backup-28374650"),
+	}
+	client := NewFakeKubernetesClient(nodes, pods, nil)
+	client.ReplicaSets = []*appsv1.ReplicaSet{{ObjectMeta: ownedBy("web-7d4b9c", "production", "Deployment", "web")}}
+	client.Jobs = []*batchv1.Job{{ObjectMeta: ownedBy("backup-28374650", "production", "CronJob", "backup")}}
+
+	calculator := NewCarbonCalculator(&CarbonConfig{DefaultGridIntensity: 400, PUE: 1.0})
+	d := newCarbonFootprintDatasource(calculator, client, nil)
+
+	for resourceType, wantRows := range map[string]int{"deployment": 1, "cronjob": 1, "statefulset": 0, "workload": 2} {
+		t.Run(resourceType, func(t *testing.T) {
+			res := d.query(ctx, backend.PluginContext{}, backend.DataQuery{
+				RefID: "A",
+				JSON:  []byte(`{"refId":"A","queryType":"table","resourceType":"` + resourceType + `"}`),
+			})
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+			rows := 0
+			if len(res.Frames) > 0 {
+				rows = res.Frames[0].Rows()
+			}
+			if rows != wantRows {
+				t.Errorf("Expected %d rows, got %d", wantRows, rows)
+			}
+		})
+	}
+
+	t.Run("OwnersForbidden", func(t *testing.T) {
+		hashed := []*corev1.Pod{
+			createOwnedPod("web-7d4b9c-abcde", "production", "ReplicaSet", "web-7d4b9c"),
+			createOwnedPod("web-7d4b9c-fghij", "production", "ReplicaSet", "web-7d4b9c"),
+		}
+		for _, pod := range hashed {
+			pod.Labels = map[string]string{appsv1.DefaultDeploymentUniqueLabelKey: "7d4b9c"}
+		}
+		forbidden := NewFakeKubernetesClient(nodes, hashed, nil)
+		forbidden.OwnersError = apierrors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "replicasets"}, "", errors.New("no RBAC rule"))
+		d := newCarbonFootprintDatasource(calculator, forbidden, nil)
+
+		res := d.query(ctx, backend.PluginContext{}, backend.DataQuery{
+			RefID: "A",
+			JSON:  []byte(`{"refId":"A","queryType":"table","resourceType":"deployment"}`),
+		})
+		if res.Error != nil {
+			t.Fatalf("query failed: %v", res.Error)
+		}
+		if len(res.Frames) == 0 || res.Frames[0].Rows() != 1 || res.Frames[0].Fields[0].At(0) != "web" {
+			t.Fatalf("Expected deployment web from the pod-template-hash label, got %v", res.Frames)
+		}
+		if meta := res.Frames[0].Meta; meta == nil || len(meta.Notices) == 0 {
+			t.Error("Expected a notice about the missing owners")
+		}
+	})
+
+	t.Run("DeploymentSumsItsPods", func(t *testing.T) {
+		window := defaultWindow(time.Now())
+		workload := &Workload{Kind: WorkloadKindDeployment, Name: "web", Namespace: "production", Pods: pods[:2]}
+		metrics, err := calculator.CalculateWorkloadCarbon(ctx, workload, nodes, nil, window)
+		if err != nil {
+			t.Fatalf("CalculateWorkloadCarbon failed: %v", err)
+		}
+
+		var want float64
+		for _, pod := range pods[:2] {
+			podMetrics, err := calculator.CalculatePodCarbon(ctx, pod, indexNodes(nodes)[pod.Spec.NodeName], nil, window)
+			if err != nil {
+				t.Fatalf("CalculatePodCarbon failed: %v", err)
+			}
+			want += podMetrics[0].EnergyConsumption
+		}
+		if abs(metrics[0].EnergyConsumption-want) > 1e-12 {
+			t.Errorf("Expected %f kWh, got %f", want, metrics[0].EnergyConsumption)
+		}
+		if metrics[0].ResourceType != "deployment" || metrics[0].ResourceName != "web" {
+			t.Errorf("Expected deployment web, got %s %s", metrics[0].ResourceType, metrics[0].ResourceName)
+		}
+	})
+}
+
+func ownedBy(name, namespace, ownerKind, ownerName string) metav1.ObjectMeta {
+	meta := metav1.ObjectMeta{Name: name, Namespace: namespace}
+	if ownerKind != "" {
+		controller := true
+		meta.OwnerReferences = []metav1.OwnerReference{{Kind: ownerKind, Name: ownerName, Controller: &controller}}
+	}
+	return meta
+}
+
+func createOwnedPod(name, namespace, ownerKind, ownerName string) *corev1.Pod {
+	pod := createPodWithResources(name, namespace, "100m", "128Mi")
+	pod.OwnerReferences = ownedBy(name, namespace, ownerKind, ownerName).OwnerReferences
+	return pod
+}
@@ -101,18 +101,47 @@ export interface OptimizationRecommendation {
 // Query types for data source
 export interface CarbonQuery {
   refId: string;
-  queryType: 'timeseries' | 'table' | 'single-value';
-  resourceType: 'pod' | 'node' | 'namespace' | 'cluster';
-  aggregation: 'sum' | 'avg' | 'max' | 'min';
+  queryType: 'timeseries' | 'table' | 'single-value' | 'topk' | 'comparison';
+  resourceType:
+    | 'pod'
+    | 'node'
+    | 'namespace'
+    | 'cluster'
+    | 'deployment'
+    | 'statefulset'
+    | 'daemonset'
+    | 'job'
+    | 'cronjob'
+    | 'workload'
+    | 'team';
+  aggregation: 'sum' | 'avg' | 'max' | 'min' | 'p50' | 'p95' | 'count';
   groupBy: string[];
   filters: {
     namespace?: string;
+    namespaceRegex?: string;
+    nodeRegex?: string;
+    labelSelector?: string;
     labels?: KeyValue<string>;
+    zone?: string;
+    instanceType?: string;
+    minEmissions?: number;
   };
   timeRange: {
     from: string;
     to: string;
   };
+  columns?: string[];
+  flattenLabels?: boolean;
+  sortBy?: string;
+  sortDesc?: boolean;
+  limit?: number;
+  metrics?: Array<'co2' | 'energy' | 'intensity' | 'cpu' | 'memory' | 'cost' | 'carbon_per_request'>;
+  aggregations?: Array<'sum' | 'avg' | 'max' | 'min' | 'p50' | 'p95' | 'count'>;
+  rankBy?: string;
+  topN?: number;
+  bottom?: boolean;
+  includeOther?: boolean;
+  compareTo?: 'previous' | 'week' | 'month';
 }
 
 // Panel configuration types